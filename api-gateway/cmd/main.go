@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ennemli/apigateway/configs"
 	"github.com/ennemli/apigateway/internal/middlewares"
 	"github.com/ennemli/apigateway/internal/proxy"
+	"github.com/ennemli/apigateway/internal/revocations"
 	"github.com/ennemli/apigateway/internal/server"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -14,7 +21,24 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.With(middlewares.WithAuth).Mount("/api/todos", proxy.TodoAPIProxy())
+	r.Handle("/api/users/internal/*", http.NotFoundHandler())
 	r.With(middlewares.WithAuth).Mount("/api/users", proxy.UsersAPIProxy())
+	r.Handle("/auth/internal/*", http.NotFoundHandler())
 	r.Mount("/auth", proxy.AuthAPIProxy())
+	go syncRevocations()
 	s.ListenAndServe()
 }
+
+func syncRevocations() {
+	config := configs.GetConfig().Service
+	interval := config.REVOCATION_SYNC
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	maxTokenAge := config.ACCESS_TOKEN_TTL
+	if maxTokenAge <= 0 {
+		maxTokenAge = 3 * time.Hour
+	}
+	endpoint := fmt.Sprintf("%s/auth/internal/revocations", config.AUTH_ENDPOINT)
+	revocations.Default.Run(context.Background(), endpoint, interval, maxTokenAge)
+}
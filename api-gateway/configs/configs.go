@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -9,11 +11,21 @@ type Config struct {
 }
 
 type serviceConfig struct {
-	USERS_ENDPOINT string
-	TODO_ENDPOINT  string
-	AUTH_ENDPOINT  string
-	APP_PORT       int
-	APP_DEBUG      bool
+	USERS_ENDPOINT       string
+	TODO_ENDPOINT        string
+	AUTH_ENDPOINT        string
+	APP_PORT             int
+	APP_DEBUG            bool
+	JWT_SK               string
+	JWKS_URL             string
+	JWT_ACCEPT_HS256     bool
+	AUTH_REMOTE_FALLBACK bool
+	TOKEN_CACHE_SIZE     int
+	ACCESS_TOKEN_TTL     time.Duration
+	REVOCATION_SYNC      time.Duration
+	JWT_ISSUER           string
+	JWT_AUDIENCE         string
+	JWT_LEEWAY           time.Duration
 }
 
 func Initialize(filename string, filepath string, filetype string) {
@@ -28,11 +40,21 @@ func Initialize(filename string, filepath string, filetype string) {
 
 func GetConfig() *Config {
 	APP := serviceConfig{
-		APP_DEBUG:      viper.GetBool("APP_DEBUG"),
-		APP_PORT:       viper.GetInt("APP_PORT"),
-		USERS_ENDPOINT: viper.GetString("USERS_ENDPOINT"),
-		TODO_ENDPOINT:  viper.GetString("TODO_ENDPOINT"),
-		AUTH_ENDPOINT:  viper.GetString("AUTH_ENDPOINT"),
+		APP_DEBUG:            viper.GetBool("APP_DEBUG"),
+		APP_PORT:             viper.GetInt("APP_PORT"),
+		USERS_ENDPOINT:       viper.GetString("USERS_ENDPOINT"),
+		TODO_ENDPOINT:        viper.GetString("TODO_ENDPOINT"),
+		AUTH_ENDPOINT:        viper.GetString("AUTH_ENDPOINT"),
+		JWT_SK:               viper.GetString("JWT_SK"),
+		JWKS_URL:             viper.GetString("JWKS_URL"),
+		JWT_ACCEPT_HS256:     viper.GetBool("JWT_ACCEPT_HS256"),
+		AUTH_REMOTE_FALLBACK: viper.GetBool("AUTH_REMOTE_FALLBACK"),
+		TOKEN_CACHE_SIZE:     viper.GetInt("TOKEN_CACHE_SIZE"),
+		ACCESS_TOKEN_TTL:     viper.GetDuration("ACCESS_TOKEN_TTL"),
+		REVOCATION_SYNC:      viper.GetDuration("REVOCATION_SYNC"),
+		JWT_ISSUER:           viper.GetString("JWT_ISSUER"),
+		JWT_AUDIENCE:         viper.GetString("JWT_AUDIENCE"),
+		JWT_LEEWAY:           viper.GetDuration("JWT_LEEWAY"),
 	}
 
 	return &Config{
@@ -1,34 +1,192 @@
 package middlewares
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ennemli/apigateway/configs"
+	"github.com/ennemli/apigateway/internal/revocations"
+	"github.com/ennemli/apigateway/pkg/jwt"
+	"github.com/ennemli/apigateway/pkg/lru"
+	gojwt "github.com/golang-jwt/jwt/v5"
 )
 
+// Identity headers injected for downstream services. Any header with the
+// HeaderUserPrefix sent by a client is dropped before the request is proxied.
+const (
+	HeaderUserPrefix    = "X-User-"
+	HeaderUserID        = "X-User-Id"
+	HeaderUserName      = "X-User-Name"
+	HeaderUserExpiresAt = "X-User-Expires"
+	HeaderUserRoles     = "X-User-Roles"
+)
+
+const defaultTokenCacheSize = 1024
+
+var (
+	errUnauthorized    = errors.New("unauthorized")
+	errAuthUnavailable = errors.New("auth service unavailable")
+)
+
+var (
+	authClient     = &http.Client{Timeout: 5 * time.Second}
+	tokenCache     *lru.Cache[string, Identity]
+	tokenCacheOnce sync.Once
+)
+
+type Identity struct {
+	ID        uint               `json:"id"`
+	Name      string             `json:"name"`
+	ExpiresAt int64              `json:"exp"`
+	IssuedAt  *gojwt.NumericDate `json:"iat,omitempty"`
+	JTI       string             `json:"jti,omitempty"`
+	Roles     []string           `json:"roles,omitempty"`
+}
+
+// issuedAt is the zero time for tokens without an iat.
+func (i *Identity) issuedAt() time.Time {
+	if i.IssuedAt == nil {
+		return time.Time{}
+	}
+	return i.IssuedAt.Time
+}
+
 func WithAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		client := &http.Client{}
-		authURL := configs.GetConfig().Service.AUTH_ENDPOINT
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s/auth/valid", authURL), nil)
-		req.Header.Set("Authorization", r.Header.Get("Authorization"))
-		if err != nil {
-			http.Error(w, "Error creating request", http.StatusInternalServerError)
+		StripIdentity(r.Header)
+
+		token, ok := bearerToken(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		res, err := client.Do(req)
-		if err != nil {
-
-			http.Error(w, "Error sending request", http.StatusInternalServerError)
+		cache := getTokenCache()
+		key := hashToken(token)
+		identity, ok := cache.Get(key)
+		if !ok {
+			verified, err := verify(r.Context(), token)
+			if errors.Is(err, errAuthUnavailable) {
+				http.Error(w, "Error sending request", http.StatusInternalServerError)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			identity = *verified
+			cache.Add(key, identity, time.Unix(identity.ExpiresAt, 0))
+		}
+		if revocations.Default.IsRevoked(identity.JTI, identity.ID, identity.issuedAt()) {
+			cache.Remove(key)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		defer res.Body.Close()
+		InjectIdentity(r.Header, &identity)
+		next.ServeHTTP(w, r)
+	})
+}
 
-		if res.StatusCode == http.StatusOK {
-			next.ServeHTTP(w, r)
-		} else {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+func StripIdentity(h http.Header) {
+	for key := range h {
+		if strings.HasPrefix(http.CanonicalHeaderKey(key), HeaderUserPrefix) {
+			h.Del(key)
 		}
+	}
+}
+
+func InjectIdentity(h http.Header, identity *Identity) {
+	h.Set(HeaderUserID, strconv.FormatUint(uint64(identity.ID), 10))
+	h.Set(HeaderUserName, url.QueryEscape(identity.Name))
+	h.Set(HeaderUserExpiresAt, strconv.FormatInt(identity.ExpiresAt, 10))
+	if len(identity.Roles) > 0 {
+		h.Set(HeaderUserRoles, strings.Join(identity.Roles, ","))
+	}
+}
+
+func getTokenCache() *lru.Cache[string, Identity] {
+	tokenCacheOnce.Do(func() {
+		size := configs.GetConfig().Service.TOKEN_CACHE_SIZE
+		if size <= 0 {
+			size = defaultTokenCacheSize
+		}
+		tokenCache = lru.New[string, Identity](size)
 	})
+	return tokenCache
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify checks the token locally and only asks the auth service when the
+// gateway has no key to check it with and AUTH_REMOTE_FALLBACK is enabled. A
+// token that is malformed, badly signed or expired is rejected without a
+// round trip, so it gets a 401 even while the auth service is down.
+func verify(ctx context.Context, token string) (*Identity, error) {
+	claims, err := jwt.Validate(token)
+	if err == nil {
+		return identityFromClaims(claims)
+	}
+	if !errors.Is(err, gojwt.ErrTokenUnverifiable) || !configs.GetConfig().Service.AUTH_REMOTE_FALLBACK {
+		return nil, errUnauthorized
+	}
+	return verifyRemote(ctx, token)
+}
+
+func verifyRemote(ctx context.Context, token string) (*Identity, error) {
+	authURL := configs.GetConfig().Service.AUTH_ENDPOINT
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/auth/valid", authURL), nil)
+	if err != nil {
+		return nil, errAuthUnavailable
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := authClient.Do(req)
+	if err != nil {
+		return nil, errAuthUnavailable
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errUnauthorized
+	}
+	identity := new(Identity)
+	if err := json.NewDecoder(res.Body).Decode(identity); err != nil || identity.ID == 0 {
+		return nil, errUnauthorized
+	}
+	return identity, nil
+}
+
+func identityFromClaims(claims *jwt.Claims) (*Identity, error) {
+	userID, err := claims.UserID()
+	if err != nil || claims.ExpiresAt == nil {
+		return nil, errUnauthorized
+	}
+	identity := &Identity{
+		ID:        userID,
+		Name:      claims.Name,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt,
+		JTI:       claims.ID,
+		Roles:     claims.Roles,
+	}
+	return identity, nil
 }
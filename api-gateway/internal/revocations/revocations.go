@@ -0,0 +1,143 @@
+package revocations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// List is the gateway's copy of the auth service's revocation list. It is
+// kept up to date by polling /auth/internal/revocations.
+type List struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	users    map[uint]time.Time
+	syncedAt time.Time
+	client   *http.Client
+}
+
+type revokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+type userRevocation struct {
+	UserID    uint      `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type revocations struct {
+	Tokens []revokedToken   `json:"tokens"`
+	Users  []userRevocation `json:"users"`
+	Now    time.Time        `json:"now"`
+}
+
+var Default = New()
+
+func New() *List {
+	return &List{
+		tokens: make(map[string]time.Time),
+		users:  make(map[uint]time.Time),
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// IsRevoked reports whether the token with this jti, issued to userID at
+// issuedAt, was revoked.
+func (l *List) IsRevoked(jti string, userID uint, issuedAt time.Time) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, ok := l.tokens[jti]; ok && jti != "" {
+		return true
+	}
+	revokedAt, ok := l.users[userID]
+	return ok && revokedAt.After(issuedAt)
+}
+
+func (l *List) RevokeToken(jti string, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[jti] = expiresAt
+}
+
+func (l *List) RevokeUser(userID uint, revokedAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if revokedAt.After(l.users[userID]) {
+		l.users[userID] = revokedAt
+	}
+}
+
+// Sync fetches the revocations made since the last successful sync.
+func (l *List) Sync(ctx context.Context, endpoint string) error {
+	l.mu.RLock()
+	since := l.syncedAt
+	l.mu.RUnlock()
+
+	u := endpoint
+	if !since.IsZero() {
+		u = fmt.Sprintf("%s?since=%s", endpoint, url.QueryEscape(since.Format(time.RFC3339Nano)))
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("revocations: unexpected status %d", res.StatusCode)
+	}
+	list := new(revocations)
+	if err := json.NewDecoder(res.Body).Decode(list); err != nil {
+		return err
+	}
+	for _, t := range list.Tokens {
+		l.RevokeToken(t.JTI, t.ExpiresAt)
+	}
+	for _, u := range list.Users {
+		l.RevokeUser(u.UserID, u.RevokedAt)
+	}
+	l.mu.Lock()
+	l.syncedAt = list.Now
+	l.mu.Unlock()
+	return nil
+}
+
+// Prune forgets revoked tokens that have expired and user revocations older
+// than the longest lived token.
+func (l *List) Prune(now time.Time, maxTokenAge time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for jti, exp := range l.tokens {
+		if !now.Before(exp) {
+			delete(l.tokens, jti)
+		}
+	}
+	for userID, revokedAt := range l.users {
+		if revokedAt.Before(now.Add(-maxTokenAge)) {
+			delete(l.users, userID)
+		}
+	}
+}
+
+// Run syncs the list every interval until ctx is done.
+func (l *List) Run(ctx context.Context, endpoint string, interval time.Duration, maxTokenAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		l.Sync(ctx, endpoint)
+		l.Prune(time.Now(), maxTokenAge)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ennemli/apigateway/internal/middlewares"
+	"github.com/ennemli/apigateway/pkg/jwt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+const testSecret = "aaa123456789"
+
+func newAuthServer(T *testing.T, status int, identity *middlewares.Identity) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/valid" {
+			T.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		if identity != nil {
+			json.NewEncoder(w).Encode(identity)
+		}
+	}))
+	viper.Set("AUTH_ENDPOINT", srv.URL)
+	T.Cleanup(srv.Close)
+	return srv
+}
+
+func newFailingAuthServer(T *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		T.Errorf("auth service should not be called")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	viper.Set("AUTH_ENDPOINT", srv.URL)
+	T.Cleanup(srv.Close)
+}
+
+func newClaims(id uint, exp time.Time) *jwt.Claims {
+	return &jwt.Claims{
+		Name: "Guts",
+		RegisteredClaims: gojwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(id), 10),
+			Issuer:    jwt.DefaultIssuer,
+			Audience:  gojwt.ClaimStrings{jwt.DefaultAudience},
+			ExpiresAt: gojwt.NewNumericDate(exp),
+		},
+	}
+}
+
+func signClaims(T *testing.T, secret string, claims *jwt.Claims) string {
+	tokenStr, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		T.Fatal(err)
+	}
+	return tokenStr
+}
+
+func signToken(T *testing.T, secret string, id uint, exp time.Time) string {
+	return signClaims(T, secret, newClaims(id, exp))
+}
+
+// remoteToken is a token signed with a secret the gateway does not have, so
+// only the auth service can check it.
+func remoteToken(T *testing.T, id uint) string {
+	return signToken(T, "auth-only-secret", id, time.Now().Add(time.Hour))
+}
+
+func serve(token string, header http.Header) (*httptest.ResponseRecorder, http.Header) {
+	var got http.Header
+	h := middlewares.WithAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res, got
+}
+
+func TestWithAuthInjectsIdentity(T *testing.T) {
+	viper.Set("JWT_SK", "")
+	viper.Set("AUTH_REMOTE_FALLBACK", true)
+	newAuthServer(T, http.StatusOK, &middlewares.Identity{ID: 7, Name: "Guts Berserk", ExpiresAt: time.Now().Add(time.Hour).Unix(), Roles: []string{"user"}})
+
+	res, got := serve(remoteToken(T, 7), http.Header{
+		"X-User-Id":   {"1"},
+		"X-User-Role": {"admin"},
+	})
+
+	if res.Code != http.StatusOK {
+		T.Fatalf("expected 200, got %d", res.Code)
+	}
+	if v := got.Get(middlewares.HeaderUserID); v != "7" {
+		T.Errorf("X-User-Id=%q, expected 7", v)
+	}
+	if v := got.Get(middlewares.HeaderUserName); v != "Guts+Berserk" {
+		T.Errorf("X-User-Name=%q, expected Guts+Berserk", v)
+	}
+	if v := got.Get(middlewares.HeaderUserExpiresAt); v == "" {
+		T.Errorf("X-User-Expires not set")
+	}
+	if v := got.Get("X-User-Role"); v != "" {
+		T.Errorf("client supplied X-User-Role was forwarded: %q", v)
+	}
+	if v := got.Get(middlewares.HeaderUserRoles); v != "user" {
+		T.Errorf("X-User-Roles=%q, expected user", v)
+	}
+}
+
+func TestWithAuthRejectsInvalidToken(T *testing.T) {
+	viper.Set("JWT_SK", "")
+	viper.Set("AUTH_REMOTE_FALLBACK", true)
+	newAuthServer(T, http.StatusUnauthorized, nil)
+
+	res, got := serve(remoteToken(T, 1), http.Header{"X-User-Id": {"1"}})
+
+	if res.Code != http.StatusUnauthorized {
+		T.Errorf("expected 401, got %d", res.Code)
+	}
+	if got != nil {
+		T.Errorf("next handler called for an invalid token")
+	}
+}
+
+func TestWithAuthVerifiesLocally(T *testing.T) {
+	viper.Set("JWT_SK", testSecret)
+	viper.Set("AUTH_REMOTE_FALLBACK", false)
+	newFailingAuthServer(T)
+
+	claims := newClaims(3, time.Now().Add(time.Hour))
+	claims.Roles = []string{"admin"}
+	res, got := serve(signClaims(T, testSecret, claims), http.Header{"X-User-Roles": {"user"}})
+
+	if res.Code != http.StatusOK {
+		T.Fatalf("expected 200, got %d", res.Code)
+	}
+	if v := got.Get(middlewares.HeaderUserID); v != "3" {
+		T.Errorf("X-User-Id=%q, expected 3", v)
+	}
+	if v := got.Get(middlewares.HeaderUserRoles); v != "admin" {
+		T.Errorf("X-User-Roles=%q, expected admin", v)
+	}
+}
+
+func TestWithAuthLocalFailures(T *testing.T) {
+	viper.Set("JWT_SK", testSecret)
+	viper.Set("AUTH_REMOTE_FALLBACK", false)
+	newFailingAuthServer(T)
+
+	claims := func(modify func(c *jwt.Claims)) string {
+		c := newClaims(3, time.Now().Add(time.Hour))
+		modify(c)
+		return signClaims(T, testSecret, c)
+	}
+	tt := []struct {
+		name  string
+		token string
+	}{
+		{"Missing", ""},
+		{"WrongIssuer", claims(func(c *jwt.Claims) { c.Issuer = "someone-else" })},
+		{"WrongAudience", claims(func(c *jwt.Claims) { c.Audience = gojwt.ClaimStrings{"another-api"} })},
+		{"NoSubject", claims(func(c *jwt.Claims) { c.Subject = "" })},
+		{"NotYetValid", claims(func(c *jwt.Claims) { c.NotBefore = gojwt.NewNumericDate(time.Now().Add(time.Hour)) })},
+		{"Expired", signToken(T, testSecret, 3, time.Now().Add(-time.Minute))},
+		{"WrongKey", signToken(T, "another-secret", 3, time.Now().Add(time.Hour))},
+		{"Garbage", "abc.def.ghi"},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			res, _ := serve(tc.token, nil)
+			if res.Code != http.StatusUnauthorized {
+				T.Errorf("expected 401, got %d", res.Code)
+			}
+		})
+	}
+}
+
+func TestWithAuthCachesWhileAuthIsDown(T *testing.T) {
+	viper.Set("JWT_SK", "")
+	viper.Set("AUTH_REMOTE_FALLBACK", true)
+	srv := newAuthServer(T, http.StatusOK, &middlewares.Identity{ID: 9, Name: "Casca", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	cached := remoteToken(T, 9)
+	res, _ := serve(cached, nil)
+	if res.Code != http.StatusOK {
+		T.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	srv.Close()
+	res, got := serve(cached, nil)
+	if res.Code != http.StatusOK {
+		T.Fatalf("expected cached 200, got %d", res.Code)
+	}
+	if v := got.Get(middlewares.HeaderUserID); v != "9" {
+		T.Errorf("X-User-Id=%q, expected 9", v)
+	}
+
+	res, _ = serve(remoteToken(T, 10), nil)
+	if res.Code != http.StatusInternalServerError {
+		T.Errorf("expected 500 for an unreachable auth service, got %d", res.Code)
+	}
+
+	for _, token := range []string{"garbage-token", "abc.def.ghi"} {
+		if res, _ := serve(token, nil); res.Code != http.StatusUnauthorized {
+			T.Errorf("expected 401 for an invalid token while auth is down, got %d", res.Code)
+		}
+	}
+}
+
+func TestWithAuthVerifiesWithPublishedKeys(T *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		T.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwt.JWKS{Keys: []jwt.JWK{{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Kid: "ed-1",
+			Alg: "EdDSA",
+			X:   base64.RawURLEncoding.EncodeToString(public),
+		}}})
+	}))
+	T.Cleanup(jwks.Close)
+	viper.Set("JWT_SK", testSecret)
+	viper.Set("JWKS_URL", jwks.URL)
+	viper.Set("AUTH_REMOTE_FALLBACK", false)
+	T.Cleanup(func() {
+		viper.Set("JWKS_URL", "")
+		viper.Set("JWT_ACCEPT_HS256", false)
+	})
+	newFailingAuthServer(T)
+
+	sign := func(kid string, key ed25519.PrivateKey) string {
+		token := gojwt.NewWithClaims(gojwt.SigningMethodEdDSA, newClaims(5, time.Now().Add(time.Hour)))
+		token.Header["kid"] = kid
+		tokenStr, err := token.SignedString(key)
+		if err != nil {
+			T.Fatal(err)
+		}
+		return tokenStr
+	}
+
+	res, got := serve(sign("ed-1", private), nil)
+	if res.Code != http.StatusOK {
+		T.Fatalf("expected 200, got %d", res.Code)
+	}
+	if v := got.Get(middlewares.HeaderUserID); v != "5" {
+		T.Errorf("X-User-Id=%q, expected 5", v)
+	}
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	if res, _ := serve(sign("ed-1", other), nil); res.Code != http.StatusUnauthorized {
+		T.Errorf("token signed with an unpublished key: expected 401, got %d", res.Code)
+	}
+	if res, _ := serve(sign("unknown", private), nil); res.Code != http.StatusUnauthorized {
+		T.Errorf("token with an unknown kid: expected 401, got %d", res.Code)
+	}
+
+	hs256 := signToken(T, testSecret, 5, time.Now().Add(time.Hour))
+	if res, _ := serve(hs256, nil); res.Code != http.StatusUnauthorized {
+		T.Errorf("HS256 token next to published keys: expected 401, got %d", res.Code)
+	}
+	viper.Set("JWT_ACCEPT_HS256", true)
+	if res, _ := serve(hs256, nil); res.Code != http.StatusOK {
+		T.Errorf("HS256 token with JWT_ACCEPT_HS256: expected 200, got %d", res.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ennemli/apigateway/internal/revocations"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+func signTokenWithJTI(T *testing.T, id uint, jti string, iat time.Time) string {
+	claims := newClaims(id, iat.Add(time.Hour))
+	claims.IssuedAt = gojwt.NewNumericDate(iat)
+	claims.ID = jti
+	return signClaims(T, testSecret, claims)
+}
+
+func TestWithAuthRejectsRevokedTokens(T *testing.T) {
+	viper.Set("JWT_SK", testSecret)
+	viper.Set("AUTH_REMOTE_FALLBACK", false)
+	newFailingAuthServer(T)
+
+	issuedAt := time.Now().Add(-2 * time.Minute)
+	byToken := signTokenWithJTI(T, 11, "revoked-jti", issuedAt)
+	byUser := signTokenWithJTI(T, 12, "other-jti", issuedAt)
+	for _, token := range []string{byToken, byUser} {
+		if res, _ := serve(token, nil); res.Code != http.StatusOK {
+			T.Fatalf("expected 200 before revocation, got %d", res.Code)
+		}
+	}
+
+	revocations.Default.RevokeToken("revoked-jti", issuedAt.Add(time.Hour))
+	revocations.Default.RevokeUser(12, time.Now().Add(-time.Minute))
+
+	for _, token := range []string{byToken, byUser} {
+		if res, _ := serve(token, nil); res.Code != http.StatusUnauthorized {
+			T.Errorf("expected 401 for a revoked cached token, got %d", res.Code)
+		}
+	}
+	if res, _ := serve(signTokenWithJTI(T, 12, "new-jti", time.Now()), nil); res.Code != http.StatusOK {
+		T.Errorf("expected 200 for a token issued after the revocation, got %d", res.Code)
+	}
+}
+
+func TestWithAuthRevocationUsesTheExactIssueTime(T *testing.T) {
+	viper.Set("JWT_SK", testSecret)
+	viper.Set("AUTH_REMOTE_FALLBACK", false)
+	newFailingAuthServer(T)
+
+	revokedAt := time.Now().Add(-time.Second)
+	revocations.Default.RevokeUser(13, revokedAt)
+
+	before := signTokenWithJTI(T, 13, "just-before-jti", revokedAt.Add(-10*time.Millisecond))
+	if res, _ := serve(before, nil); res.Code != http.StatusUnauthorized {
+		T.Errorf("expected 401 for a token issued in the second before the revocation, got %d", res.Code)
+	}
+	after := signTokenWithJTI(T, 13, "just-after-jti", revokedAt.Add(10*time.Millisecond))
+	if res, _ := serve(after, nil); res.Code != http.StatusOK {
+		T.Errorf("expected 200 for a token issued right after the revocation, got %d", res.Code)
+	}
+}
+
+func TestRevocationsSync(T *testing.T) {
+	now := time.Now().UTC()
+	var since []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since = append(since, r.URL.Query().Get("since"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tokens": []map[string]interface{}{{"jti": "abc", "exp": now.Add(time.Hour)}},
+			"users":  []map[string]interface{}{{"user_id": 4, "revoked_at": now}},
+			"now":    now,
+		})
+	}))
+	T.Cleanup(srv.Close)
+
+	list := revocations.New()
+	for i := 0; i < 2; i++ {
+		if err := list.Sync(context.Background(), srv.URL); err != nil {
+			T.Fatal(err)
+		}
+	}
+	if since[0] != "" || since[1] != now.Format(time.RFC3339Nano) {
+		T.Errorf("unexpected since parameters %q", since)
+	}
+	if !list.IsRevoked("abc", 1, now) {
+		T.Errorf("token abc should be revoked")
+	}
+	if !list.IsRevoked("", 4, now.Add(-time.Minute)) {
+		T.Errorf("tokens issued to user 4 before the revocation should be revoked")
+	}
+	if !list.IsRevoked("", 4, now.Add(-time.Millisecond)) {
+		T.Errorf("tokens issued to user 4 in the second before the revocation should be revoked")
+	}
+	if list.IsRevoked("", 4, now.Add(time.Minute)) {
+		T.Errorf("tokens issued to user 4 after the revocation should be valid")
+	}
+
+	list.Prune(now.Add(2*time.Hour), time.Hour)
+	if list.IsRevoked("abc", 1, now) || list.IsRevoked("", 4, now.Add(-time.Minute)) {
+		T.Errorf("expired revocations should be pruned")
+	}
+}
@@ -0,0 +1,131 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwksMaxAge          = 10 * time.Minute
+	jwksRefreshInterval = 10 * time.Second
+)
+
+type Key struct {
+	ID     string
+	Method gojwt.SigningMethod
+	public interface{}
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (jwk JWK) Key() (*Key, error) {
+	switch {
+	case jwk.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{
+			ID:     jwk.Kid,
+			Method: gojwt.SigningMethodRS256,
+			public: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())},
+		}, nil
+	case jwk.Kty == "OKP" && jwk.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %s", jwk.Kid)
+		}
+		return &Key{ID: jwk.Kid, Method: gojwt.SigningMethodEdDSA, public: ed25519.PublicKey(x)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+// keyCache keeps the keys published by the auth service. A failed refresh
+// keeps the previous keys so tokens can still be verified while auth is down.
+type keyCache struct {
+	mu        sync.Mutex
+	url       string
+	keys      map[string]*Key
+	fetchedAt time.Time
+	triedAt   time.Time
+	client    *http.Client
+}
+
+var publishedKeys = &keyCache{client: &http.Client{Timeout: 5 * time.Second}}
+
+func (c *keyCache) Get(url string, kid string) (*Key, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if url != c.url {
+		c.url = url
+		c.keys = nil
+		c.fetchedAt = time.Time{}
+		c.triedAt = time.Time{}
+	}
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > jwksMaxAge
+	if (!ok || stale) && time.Since(c.triedAt) > jwksRefreshInterval {
+		c.triedAt = time.Now()
+		if keys, err := c.fetch(); err == nil {
+			c.keys = keys
+			c.fetchedAt = c.triedAt
+			key, ok = c.keys[kid]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("Unknown key id: %v", kid)
+	}
+	return key, nil
+}
+
+func (c *keyCache) fetch() (map[string]*Key, error) {
+	res, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status %d", res.StatusCode)
+	}
+	jwks := new(JWKS)
+	if err := json.NewDecoder(res.Body).Decode(jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*Key, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+	return keys, nil
+}
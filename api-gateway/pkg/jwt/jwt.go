@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"fmt"
+
+	"github.com/ennemli/apigateway/configs"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// HMACKeyID is the kid the auth service uses for the shared JWT_SK secret.
+const HMACKeyID = "hs256"
+
+// Validate checks a token issued by the auth service, mirroring
+// auth-service/pkg/jwt. RS256/EdDSA tokens are checked with the keys published
+// at JWKS_URL and HS256 tokens with the shared JWT_SK, which is only accepted
+// alongside JWKS_URL when JWT_ACCEPT_HS256 is set.
+func Validate(tokenString string) (*Claims, error) {
+	token, err := gojwt.ParseWithClaims(tokenString, new(Claims), keyFunc, ParserOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("token invalid")
+	}
+	if claims, ok := token.Claims.(*Claims); ok {
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("token invalid")
+}
+
+func keyFunc(token *gojwt.Token) (interface{}, error) {
+	config := configs.GetConfig().Service
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" || kid == HMACKeyID {
+		if _, ok := token.Method.(*gojwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		if config.JWT_SK == "" || (config.JWKS_URL != "" && !config.JWT_ACCEPT_HS256) {
+			return nil, fmt.Errorf("no signing key configured")
+		}
+		return []byte(config.JWT_SK), nil
+	}
+	if config.JWKS_URL == "" {
+		return nil, fmt.Errorf("no jwks url configured")
+	}
+	key, err := publishedKeys.Get(config.JWKS_URL, kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
@@ -0,0 +1,102 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(T *testing.T) {
+	now := time.Date(2024, 5, 24, 9, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	testCases := []struct {
+		name     string
+		size     int
+		run      func(c *Cache[string, int])
+		key      string
+		expected int
+		found    bool
+	}{
+		{
+			name:     "hit",
+			size:     2,
+			run:      func(c *Cache[string, int]) { c.Add("a", 1, later) },
+			key:      "a",
+			expected: 1,
+			found:    true,
+		},
+		{
+			name:  "miss",
+			size:  2,
+			run:   func(c *Cache[string, int]) { c.Add("a", 1, later) },
+			key:   "b",
+			found: false,
+		},
+		{
+			name:  "expired",
+			size:  2,
+			run:   func(c *Cache[string, int]) { c.Add("a", 1, now) },
+			key:   "a",
+			found: false,
+		},
+		{
+			name: "evicts least recently used",
+			size: 2,
+			run: func(c *Cache[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Add("c", 3, later)
+			},
+			key:   "a",
+			found: false,
+		},
+		{
+			name: "get refreshes recency",
+			size: 2,
+			run: func(c *Cache[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Get("a")
+				c.Add("c", 3, later)
+			},
+			key:      "a",
+			expected: 1,
+			found:    true,
+		},
+		{
+			name: "add replaces value",
+			size: 2,
+			run: func(c *Cache[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("a", 2, later)
+			},
+			key:      "a",
+			expected: 2,
+			found:    true,
+		},
+		{
+			name: "removed",
+			size: 2,
+			run: func(c *Cache[string, int]) {
+				c.Add("a", 1, later)
+				c.Remove("a")
+			},
+			key:   "a",
+			found: false,
+		},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			c := New[string, int](tc.size)
+			c.now = func() time.Time { return now }
+			tc.run(c)
+			actual, found := c.Get(tc.key)
+			if found != tc.found || actual != tc.expected {
+				T.Errorf("Get(%q)=(%v,%v), expected (%v,%v)", tc.key, actual, found, tc.expected, tc.found)
+			}
+			if c.Len() > tc.size {
+				T.Errorf("Len()=%d exceeds size %d", c.Len(), tc.size)
+			}
+		})
+	}
+}
@@ -1,23 +1,48 @@
 package main
 
 import (
+	"context"
 	"time"
 
+	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/internal/db"
+	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
 	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/reset"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/notifier"
 	"github.com/ennemli/todo/todo/internal/routing"
 	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/jwt"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 )
 
 func main() {
 	server.InitServerEnv()
+	if err := jwt.Load(); err != nil {
+		panic(err)
+	}
+	config := configs.GetConfig().Service
+	if err := crypto.SetHashParams(crypto.HashParams{Algorithm: config.PASSWORD_HASH, BcryptCost: config.BCRYPT_COST}); err != nil {
+		panic(err)
+	}
 	s := server.NewServer()
 	r := server.GetRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middlewares.SetTimeOut(time.Second * 2))
 	r.Use(render.SetContentType(render.ContentTypeJSON))
-	routing.InitRouting()
+	db.GetDB().AutoMigrate(&token.RefreshToken{}, &revocation.RevokedToken{}, &revocation.UserRevocation{}, &reset.ResetToken{})
+	revocations := revocation.NewStore()
+	jwt.SetRevocationList(revocation.Checker{Store: revocations})
+	go revocation.PruneEvery(context.Background(), revocations, time.Hour, handlers.AccessTokenTTL())
+	n, err := notifier.New(config.NOTIFIER, config.NOTIFIER_FILE)
+	if err != nil {
+		panic(err)
+	}
+	routing.InitRouting(revocations, n)
 	s.ListenAndServe()
 }
@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -17,11 +19,28 @@ type dbConfig struct {
 	DB_PORT     int
 }
 type serviceConfig struct {
-	APP_PORT     int
-	APP_DEBUG    bool
-	API_ENDPOINT string
-	USER_URI     string
-	JWT_SK       string
+	APP_PORT               int
+	APP_DEBUG              bool
+	API_ENDPOINT           string
+	USER_URI               string
+	JWT_SK                 string
+	JWT_SIGNING_KEY_FILE   string
+	JWT_VERIFY_KEY_FILES   string
+	JWT_ACCEPT_HS256       bool
+	ACCESS_TOKEN_TTL       time.Duration
+	REFRESH_TOKEN_TTL      time.Duration
+	JWT_ISSUER             string
+	JWT_AUDIENCE           string
+	JWT_LEEWAY             time.Duration
+	LOGIN_LOCKOUT_ATTEMPTS int
+	LOGIN_LOCKOUT          time.Duration
+	PASSWORD_HASH          string
+	BCRYPT_COST            int
+	PASSWORD_MIN_LENGTH    int
+	PASSWORD_MIN_CLASSES   int
+	RESET_TOKEN_TTL        time.Duration
+	NOTIFIER               string
+	NOTIFIER_FILE          string
 }
 
 func Initialize(filename string, filepath string, filetype string) {
@@ -43,11 +62,28 @@ func GetConfig() *Config {
 		DB_USER:     viper.GetString("DB_USER"),
 	}
 	APP := serviceConfig{
-		APP_DEBUG:    viper.GetBool("APP_DEBUG"),
-		APP_PORT:     viper.GetInt("APP_PORT"),
-		API_ENDPOINT: viper.GetString("API_ENDPOINT"),
-		USER_URI:     viper.GetString("USER_URI"),
-		JWT_SK:       viper.GetString("JWT_SK"),
+		APP_DEBUG:              viper.GetBool("APP_DEBUG"),
+		APP_PORT:               viper.GetInt("APP_PORT"),
+		API_ENDPOINT:           viper.GetString("API_ENDPOINT"),
+		USER_URI:               viper.GetString("USER_URI"),
+		JWT_SK:                 viper.GetString("JWT_SK"),
+		JWT_SIGNING_KEY_FILE:   viper.GetString("JWT_SIGNING_KEY_FILE"),
+		JWT_VERIFY_KEY_FILES:   viper.GetString("JWT_VERIFY_KEY_FILES"),
+		JWT_ACCEPT_HS256:       viper.GetBool("JWT_ACCEPT_HS256"),
+		ACCESS_TOKEN_TTL:       viper.GetDuration("ACCESS_TOKEN_TTL"),
+		REFRESH_TOKEN_TTL:      viper.GetDuration("REFRESH_TOKEN_TTL"),
+		JWT_ISSUER:             viper.GetString("JWT_ISSUER"),
+		JWT_AUDIENCE:           viper.GetString("JWT_AUDIENCE"),
+		JWT_LEEWAY:             viper.GetDuration("JWT_LEEWAY"),
+		LOGIN_LOCKOUT_ATTEMPTS: viper.GetInt("LOGIN_LOCKOUT_ATTEMPTS"),
+		LOGIN_LOCKOUT:          viper.GetDuration("LOGIN_LOCKOUT"),
+		PASSWORD_HASH:          viper.GetString("PASSWORD_HASH"),
+		BCRYPT_COST:            viper.GetInt("BCRYPT_COST"),
+		PASSWORD_MIN_LENGTH:    viper.GetInt("PASSWORD_MIN_LENGTH"),
+		PASSWORD_MIN_CLASSES:   viper.GetInt("PASSWORD_MIN_CLASSES"),
+		RESET_TOKEN_TTL:        viper.GetDuration("RESET_TOKEN_TTL"),
+		NOTIFIER:               viper.GetString("NOTIFIER"),
+		NOTIFIER_FILE:          viper.GetString("NOTIFIER_FILE"),
 	}
 
 	return &Config{
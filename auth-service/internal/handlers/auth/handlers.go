@@ -3,33 +3,52 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ennemli/todo/todo/internal/db"
 	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/pkg/crypto"
 	"github.com/ennemli/todo/todo/pkg/jwt"
+	"github.com/ennemli/todo/todo/pkg/throttle"
 	"github.com/go-chi/render"
-	"golang.org/x/crypto/bcrypt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 type Handlers interface {
 	LoginHandler(w http.ResponseWriter, r *http.Request)
 	ValidateHandler(w http.ResponseWriter, r *http.Request)
+	JWKSHandler(w http.ResponseWriter, r *http.Request)
+	RefreshHandler(w http.ResponseWriter, r *http.Request)
+	LogoutHandler(w http.ResponseWriter, r *http.Request)
+	RevocationsHandler(w http.ResponseWriter, r *http.Request)
 }
 type UserSerivce interface {
 	GetUser(ctx context.Context, name string) (*User, error)
+	GetUserById(ctx context.Context, id uint) (*User, error)
+	UpdatePassword(ctx context.Context, id uint, hash string) error
 }
 
 type AuthHandler struct {
-	userService UserSerivce
+	userService  UserSerivce
+	tokenStore   token.Store
+	revocations  revocation.Store
+	loginTracker throttle.Tracker
 }
 type UserServiceClient struct{}
 
-func NewAuthHandler(u UserSerivce) Handlers {
+func NewAuthHandler(u UserSerivce, t token.Store, rv revocation.Store, lt throttle.Tracker) Handlers {
 	return &AuthHandler{
-		userService: u,
+		userService:  u,
+		tokenStore:   t,
+		revocations:  rv,
+		loginTracker: lt,
 	}
 }
 
@@ -37,24 +56,59 @@ type User struct {
 	Name     string `json:"name"`
 	Password string `json:"-"`
 	ID       uint   `json:"id"`
+	Role     string `json:"role"`
 }
 
+// GetUser and GetUserById skip the users in the user service's trash.
 func (u *UserServiceClient) GetUser(ctx context.Context, name string) (*User, error) {
 	user := new(User)
-	if err := db.GetDB().Where(" name = ? ", name).First(user).Error; err != nil {
+	if err := db.GetDB().Where(" name = ? AND deleted_at IS NULL", name).First(user).Error; err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+func (u *UserServiceClient) GetUserById(ctx context.Context, id uint) (*User, error) {
+	user := new(User)
+	if err := db.GetDB().Where("deleted_at IS NULL").First(user, id).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdatePassword returns gorm.ErrRecordNotFound when the user does not exist
+// or is in the trash.
+func (u *UserServiceClient) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	result := db.GetDB().WithContext(ctx).Model(&User{ID: id}).Where("deleted_at IS NULL").Update("password", hash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 type Credential struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
 }
 
 type ResponseMessage struct {
-	User  *User  `json:"user"`
-	Token string `json:"token"`
+	User         *User  `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Identity is the caller identity decoded from a valid token. IssuedAt keeps
+// the microseconds of the token's iat.
+type Identity struct {
+	ID        uint               `json:"id"`
+	Name      string             `json:"name"`
+	ExpiresAt int64              `json:"exp"`
+	IssuedAt  *gojwt.NumericDate `json:"iat,omitempty"`
+	JTI       string             `json:"jti,omitempty"`
+	Roles     []string           `json:"roles,omitempty"`
 }
 
 func (a *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -63,52 +117,106 @@ func (a *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		renderError(w, r, http.StatusBadRequest, "Bad Request")
 		return
 	}
+	keys := loginKeys(r, credential.Name)
+	// The attempt counts as failed until the password is verified.
+	if wait := a.loginTracker.Attempt(keys...); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		renderError(w, r, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return
+	}
 	user, err := a.userService.GetUser(r.Context(), credential.Name)
 	if err != nil {
-		renderError(w, r, http.StatusNotFound, "There is no user with this name")
+		// Spend the same time as for a wrong password so response times do
+		// not tell which names exist.
+		compareDummyPassword(credential.Password)
+		renderError(w, r, http.StatusUnauthorized, invalidCredentials)
 		return
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credential.Password)); err != nil {
-
-		renderError(w, r, http.StatusUnauthorized, "Please check if your name and password are correct")
+	ok, needsRehash, err := crypto.VerifyPassword(user.Password, credential.Password)
+	if err != nil || !ok {
+		renderError(w, r, http.StatusUnauthorized, invalidCredentials)
+		return
+	}
+	a.loginSucceeded(keys)
+	if needsRehash {
+		a.rehashPassword(r.Context(), user, credential.Password)
+	}
+	familyID, err := crypto.NewOpaqueToken()
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
 		return
 	}
-	exp := time.Now().Add(time.Hour * 3)
-	token, err := jwt.Create(user, exp.Unix())
+	refreshToken, stored, err := newRefreshToken(user.ID, familyID)
 	if err != nil {
 		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
 		return
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   token,
-		Expires: exp,
-	})
-	render.JSON(w, r, ResponseMessage{
-		User:  user,
-		Token: token,
-	})
+	if _, err := a.tokenStore.CreateRefreshToken(r.Context(), stored); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	a.renderTokens(w, r, user, refreshToken, stored.ExpiresAt)
 }
 
 func (a *AuthHandler) ValidateHandler(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
+	tokenStr, ok := bearerToken(r)
+	if !ok {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
-	token := strings.Split(authHeader, " ")
-	if len(token) != 2 || token[0] != "Bearer" {
-		http.Error(w, "", http.StatusBadRequest)
+	claims, err := jwt.Validate(tokenStr)
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
-	tokenStr := token[1]
-	_, err := jwt.Validate(tokenStr)
+	identity, err := identityFromClaims(claims)
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
-	http.Error(w, "", http.StatusOK)
+	render.JSON(w, r, identity)
+}
+
+func identityFromClaims(claims *jwt.Claims) (*Identity, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("token has no expiration")
+	}
+	identity := &Identity{
+		ID:        userID,
+		Name:      claims.Name,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt,
+		JTI:       claims.ID,
+		Roles:     claims.Roles,
+	}
+	return identity, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", false
+	}
+	token := strings.Split(authHeader, " ")
+	if len(token) != 2 || token[0] != "Bearer" {
+		return "", false
+	}
+	return token[1], true
+}
+
+func (a *AuthHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := jwt.Keys()
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	render.JSON(w, r, keys.JWKS())
 }
 
 func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/throttle"
+)
+
+const invalidCredentials = "Invalid name or password"
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// LoginPolicy is the throttling applied to failed logins, per name and per
+// client IP.
+func LoginPolicy() throttle.Policy {
+	config := configs.GetConfig().Service
+	policy := throttle.DefaultPolicy
+	if config.LOGIN_LOCKOUT_ATTEMPTS > 0 {
+		policy.LockoutAttempts = config.LOGIN_LOCKOUT_ATTEMPTS
+	}
+	if config.LOGIN_LOCKOUT > 0 {
+		policy.Lockout = config.LOGIN_LOCKOUT
+	}
+	return policy
+}
+
+// loginKeys returns the tracker keys of a login attempt, the name first.
+func loginKeys(r *http.Request, name string) []string {
+	return []string{
+		"name:" + strings.ToLower(name),
+		"ip:" + clientIP(r),
+	}
+}
+
+// loginSucceeded clears the failures of the name and gives back the attempt
+// reserved for the other keys.
+func (a *AuthHandler) loginSucceeded(keys []string) {
+	a.loginTracker.Reset(keys[0])
+	a.loginTracker.Release(keys[1:]...)
+}
+
+// clientIP trusts the last X-Forwarded-For entry, the one added by the
+// gateway, and falls back to the peer address.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		return strings.TrimSpace(parts[len(parts)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = crypto.HashPassword("not a real password")
+	})
+	crypto.VerifyPassword(dummyHash, password)
+}
+
+// rehashPassword upgrades a hash made with an outdated algorithm or cost.
+// The login goes ahead even when this fails.
+func (a *AuthHandler) rehashPassword(ctx context.Context, user *User, password string) {
+	hash, err := crypto.HashPassword(password)
+	if err == nil {
+		err = a.userService.UpdatePassword(ctx, user.ID, hash)
+	}
+	if err != nil {
+		log.Printf("rehash password of user %d: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/jwt"
+	"github.com/go-chi/render"
+)
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+// LogoutHandler revokes the access token it is called with, along with the
+// refresh token family when one is given. With "all" every token issued to
+// the user so far is revoked.
+func (a *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	tokenStr, ok := bearerToken(r)
+	if !ok {
+		renderError(w, r, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	claims, err := jwt.Validate(tokenStr)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	identity, err := identityFromClaims(claims)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	request := new(LogoutRequest)
+	json.NewDecoder(r.Body).Decode(request)
+	if request.RefreshToken == "" {
+		if cookie, err := r.Cookie(refreshTokenCookie); err == nil {
+			request.RefreshToken = cookie.Value
+		}
+	}
+
+	if identity.JTI != "" {
+		if err := a.revocations.RevokeToken(r.Context(), identity.JTI, identity.ID, time.Unix(identity.ExpiresAt, 0)); err != nil {
+			renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+			return
+		}
+	}
+	if request.All {
+		if err := a.revocations.RevokeUser(r.Context(), identity.ID); err != nil {
+			renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+			return
+		}
+		if err := a.tokenStore.RevokeUserTokens(r.Context(), identity.ID); err != nil {
+			renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+			return
+		}
+	} else if request.RefreshToken != "" {
+		stored, err := a.tokenStore.GetRefreshToken(r.Context(), crypto.HashToken(request.RefreshToken))
+		if err == nil && stored.UserID == identity.ID {
+			if err := a.tokenStore.RevokeFamily(r.Context(), stored.FamilyID); err != nil {
+				renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+				return
+			}
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: "", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{
+		Name:   refreshTokenCookie,
+		Value:  "",
+		Path:   configs.GetConfig().Service.API_ENDPOINT + "/refresh",
+		MaxAge: -1,
+	})
+	render.NoContent(w, r)
+}
+
+// RevocationsHandler lists revocations made after ?since= (RFC 3339) so the
+// gateway can keep its own copy of the list. It is only reachable from
+// inside the service network.
+func (a *AuthHandler) RevocationsHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			renderError(w, r, http.StatusBadRequest, "Invalid since")
+			return
+		}
+	}
+	revocations, err := a.revocations.GetRevocations(r.Context(), since)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	render.JSON(w, r, revocations)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/jwt"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultAccessTokenTTL  = time.Hour * 3
+	defaultRefreshTokenTTL = time.Hour * 24 * 30
+	refreshTokenCookie     = "refresh_token"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshHandler trades a refresh token for a new access token and a new
+// refresh token. Presenting a token that was already rotated means it leaked,
+// so the whole family is revoked and the user has to log in again.
+func (a *AuthHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	request := new(RefreshRequest)
+	json.NewDecoder(r.Body).Decode(request)
+	if request.RefreshToken == "" {
+		if cookie, err := r.Cookie(refreshTokenCookie); err == nil {
+			request.RefreshToken = cookie.Value
+		}
+	}
+	if request.RefreshToken == "" {
+		renderError(w, r, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	used, err := a.tokenStore.GetRefreshToken(r.Context(), crypto.HashToken(request.RefreshToken))
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if used.UsedAt != nil {
+		a.tokenStore.RevokeFamily(r.Context(), used.FamilyID)
+		renderError(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if !used.Active(time.Now()) {
+		renderError(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if revoked, err := a.revocations.IsRevoked(r.Context(), "", used.UserID, used.CreatedAt); err != nil || revoked {
+		renderError(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	user, err := a.userService.GetUserById(r.Context(), used.UserID)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	refreshToken, next, err := newRefreshToken(user.ID, used.FamilyID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	if _, err = a.tokenStore.RotateRefreshToken(r.Context(), used, next); err == token.ErrTokenReused {
+		a.tokenStore.RevokeFamily(r.Context(), used.FamilyID)
+		renderError(w, r, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	} else if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	a.renderTokens(w, r, user, refreshToken, next.ExpiresAt)
+}
+
+func (a *AuthHandler) renderTokens(w http.ResponseWriter, r *http.Request, user *User, refreshToken string, refreshExp time.Time) {
+	exp := time.Now().Add(AccessTokenTTL())
+	claims := jwt.NewClaims(user.ID, user.Name, exp)
+	if user.Role != "" {
+		claims.Roles = []string{user.Role}
+	}
+	accessToken, err := jwt.Create(claims)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:    "token",
+		Value:   accessToken,
+		Expires: exp,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    refreshToken,
+		Path:     configs.GetConfig().Service.API_ENDPOINT + "/refresh",
+		Expires:  refreshExp,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	render.JSON(w, r, ResponseMessage{
+		User:         user,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+func newRefreshToken(userID uint, familyID string) (string, *token.RefreshToken, error) {
+	refreshToken, err := crypto.NewOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return refreshToken, &token.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: crypto.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+	}, nil
+}
+
+func AccessTokenTTL() time.Duration {
+	if ttl := configs.GetConfig().Service.ACCESS_TOKEN_TTL; ttl > 0 {
+		return ttl
+	}
+	return defaultAccessTokenTTL
+}
+
+func refreshTokenTTL() time.Duration {
+	if ttl := configs.GetConfig().Service.REFRESH_TOKEN_TTL; ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTokenTTL
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/internal/models/reset"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/notifier"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+const defaultResetTokenTTL = 30 * time.Minute
+
+type PasswordResetHandlers interface {
+	ForgotPasswordHandler(w http.ResponseWriter, r *http.Request)
+	ResetPasswordHandler(w http.ResponseWriter, r *http.Request)
+}
+
+type PasswordResetHandler struct {
+	userService UserSerivce
+	resets      reset.Store
+	tokenStore  token.Store
+	revocations revocation.Store
+	notifier    notifier.Notifier
+}
+
+func NewPasswordResetHandler(u UserSerivce, rs reset.Store, t token.Store, rv revocation.Store, n notifier.Notifier) PasswordResetHandlers {
+	return &PasswordResetHandler{
+		userService: u,
+		resets:      rs,
+		tokenStore:  t,
+		revocations: rv,
+		notifier:    n,
+	}
+}
+
+type ForgotPasswordRequest struct {
+	Name string `json:"name"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// ForgotPasswordHandler sends a reset token to the user. It answers the same
+// way whether or not the user exists.
+func (h *PasswordResetHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	request := new(ForgotPasswordRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil || request.Name == "" {
+		renderError(w, r, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	if user, err := h.userService.GetUser(r.Context(), request.Name); err == nil {
+		if err := h.sendResetToken(r, user); err != nil {
+			log.Printf("password reset for user %d: %v", user.ID, err)
+		}
+	}
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, MessageResponse{Message: "If the user exists, a reset token was sent"})
+}
+
+func (h *PasswordResetHandler) sendResetToken(r *http.Request, user *User) error {
+	resetToken, err := crypto.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	stored := &reset.ResetToken{
+		UserID:    user.ID,
+		TokenHash: crypto.HashToken(resetToken),
+		ExpiresAt: time.Now().Add(resetTokenTTL()),
+	}
+	if _, err := h.resets.CreateResetToken(r.Context(), stored); err != nil {
+		return err
+	}
+	return h.notifier.NotifyPasswordReset(r.Context(), notifier.PasswordReset{
+		UserID:    user.ID,
+		Name:      user.Name,
+		Token:     resetToken,
+		ExpiresAt: stored.ExpiresAt,
+	})
+}
+
+// ResetPasswordHandler sets a new password with a reset token and logs the
+// user out everywhere.
+func (h *PasswordResetHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	request := new(ResetPasswordRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil || request.Token == "" {
+		renderError(w, r, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	if err := PasswordPolicy().Validate(request.NewPassword); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash, err := crypto.HashPassword(request.NewPassword)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	used, err := h.resets.ConsumeResetToken(r.Context(), crypto.HashToken(request.Token))
+	if err == reset.ErrInvalidResetToken {
+		renderError(w, r, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	} else if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	err = h.userService.UpdatePassword(r.Context(), used.UserID, hash)
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	if err := h.revocations.RevokeUser(r.Context(), used.UserID); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	if err := h.tokenStore.RevokeUserTokens(r.Context(), used.UserID); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
+		return
+	}
+	render.NoContent(w, r)
+}
+
+// PasswordPolicy is crypto.DefaultPasswordPolicy adjusted by the config. It
+// matches the user service's.
+func PasswordPolicy() crypto.PasswordPolicy {
+	config := configs.GetConfig().Service
+	policy := crypto.DefaultPasswordPolicy
+	if config.PASSWORD_MIN_LENGTH > 0 {
+		policy.MinLength = config.PASSWORD_MIN_LENGTH
+	}
+	if config.PASSWORD_MIN_CLASSES > 0 {
+		policy.MinClasses = config.PASSWORD_MIN_CLASSES
+	}
+	return policy
+}
+
+func resetTokenTTL() time.Duration {
+	if ttl := configs.GetConfig().Service.RESET_TOKEN_TTL; ttl > 0 {
+		return ttl
+	}
+	return defaultResetTokenTTL
+}
@@ -0,0 +1,72 @@
+package reset
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken is returned for unknown, expired and used tokens alike.
+var ErrInvalidResetToken = errors.New("invalid reset token")
+
+type Store interface {
+	CreateResetToken(ctx context.Context, resetToken *ResetToken) (*ResetToken, error)
+	ConsumeResetToken(ctx context.Context, hash string) (*ResetToken, error)
+}
+
+// ResetToken is a single use password reset token, stored hashed.
+type ResetToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func NewStore() Store {
+	return &store{
+		db: db.GetDB(),
+	}
+}
+
+func (s *store) CreateResetToken(ctx context.Context, resetToken *ResetToken) (*ResetToken, error) {
+	if err := s.db.WithContext(ctx).Create(resetToken).Error; err != nil {
+		return nil, err
+	}
+	return resetToken, nil
+}
+
+// ConsumeResetToken marks the token used, along with every other pending
+// token of the same user, and returns it.
+func (s *store) ConsumeResetToken(ctx context.Context, hash string) (*ResetToken, error) {
+	resetToken := new(ResetToken)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&ResetToken{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Where("token_hash = ?", hash).First(resetToken).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", resetToken.UserID).
+			Update("used_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resetToken, nil
+}
@@ -0,0 +1,21 @@
+package reset
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReset struct {
+	mock.Mock
+}
+
+func (m *MockReset) CreateResetToken(ctx context.Context, resetToken *ResetToken) (*ResetToken, error) {
+	args := m.Called(ctx, resetToken)
+	return args.Get(0).(*ResetToken), args.Error(1)
+}
+
+func (m *MockReset) ConsumeResetToken(ctx context.Context, hash string) (*ResetToken, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(*ResetToken), args.Error(1)
+}
@@ -0,0 +1,145 @@
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/db"
+	"github.com/ennemli/todo/todo/pkg/jwt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Store interface {
+	RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userID uint) error
+	IsRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error)
+	GetRevocations(ctx context.Context, since time.Time) (*Revocations, error)
+	Prune(ctx context.Context, maxTokenAge time.Duration) error
+}
+
+// RevokedToken is a single access token revoked before its exp, e.g. on
+// logout. It is pruned once ExpiresAt has passed.
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	ExpiresAt time.Time `json:"exp" gorm:"index;not null"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"index;not null"`
+}
+
+// UserRevocation invalidates every token issued to the user before
+// RevokedAt. user-service writes to the same table when an account is
+// deleted.
+type UserRevocation struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"index;not null"`
+}
+
+type Revocations struct {
+	Tokens []RevokedToken   `json:"tokens"`
+	Users  []UserRevocation `json:"users"`
+	Now    time.Time        `json:"now"`
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func NewStore() Store {
+	return &store{
+		db: db.GetDB(),
+	}
+}
+
+func (s *store) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}).Error
+}
+
+func (s *store) RevokeUser(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+	}).Create(&UserRevocation{
+		UserID:    userID,
+		RevokedAt: time.Now(),
+	}).Error
+}
+
+func (s *store) IsRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error) {
+	var count int64
+	if jti != "" {
+		if err := s.db.WithContext(ctx).Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	if err := s.db.WithContext(ctx).Model(&UserRevocation{}).
+		Where("user_id = ? AND revoked_at > ?", userID, issuedAt).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *store) GetRevocations(ctx context.Context, since time.Time) (*Revocations, error) {
+	revocations := &Revocations{Now: time.Now()}
+	if err := s.db.WithContext(ctx).Where("revoked_at > ? AND expires_at > ?", since, revocations.Now).
+		Find(&revocations.Tokens).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Where("revoked_at > ?", since).
+		Find(&revocations.Users).Error; err != nil {
+		return nil, err
+	}
+	return revocations, nil
+}
+
+// Prune drops revocations that no longer matter because every token they
+// cover has expired anyway.
+func (s *store) Prune(ctx context.Context, maxTokenAge time.Duration) error {
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Where("revoked_at <= ?", now.Add(-maxTokenAge)).Delete(&UserRevocation{}).Error
+}
+
+// PruneEvery runs Prune until ctx is done.
+func PruneEvery(ctx context.Context, s Store, interval time.Duration, maxTokenAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Prune(ctx, maxTokenAge)
+		}
+	}
+}
+
+// Checker adapts a Store to jwt.RevocationList. Lookups that fail are
+// treated as revoked.
+type Checker struct {
+	Store Store
+}
+
+func (c Checker) IsRevoked(claims *jwt.Claims) bool {
+	userID, err := claims.UserID()
+	if err != nil {
+		return true
+	}
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := c.Store.IsRevoked(context.Background(), claims.ID, userID, issuedAt)
+	return err != nil || revoked
+}
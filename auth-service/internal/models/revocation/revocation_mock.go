@@ -0,0 +1,37 @@
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRevocation struct {
+	mock.Mock
+}
+
+func (m *MockRevocation) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, userID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevocation) RevokeUser(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRevocation) IsRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, jti, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRevocation) GetRevocations(ctx context.Context, since time.Time) (*Revocations, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).(*Revocations), args.Error(1)
+}
+
+func (m *MockRevocation) Prune(ctx context.Context, maxTokenAge time.Duration) error {
+	args := m.Called(ctx, maxTokenAge)
+	return args.Error(0)
+}
@@ -0,0 +1,94 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrTokenReused is returned when a refresh token that was already rotated
+// is presented again.
+var ErrTokenReused = errors.New("refresh token reused")
+
+type Store interface {
+	CreateRefreshToken(ctx context.Context, refreshToken *RefreshToken) (*RefreshToken, error)
+	GetRefreshToken(ctx context.Context, hash string) (*RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, used *RefreshToken, next *RefreshToken) (*RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeUserTokens(ctx context.Context, userID uint) error
+}
+
+// RefreshToken is stored hashed. Every token issued by rotating another one
+// shares its FamilyID, so a reused token can revoke the whole chain.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"index;not null"`
+	FamilyID  string    `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+func (t *RefreshToken) Active(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func NewStore() Store {
+	return &store{
+		db: db.GetDB(),
+	}
+}
+
+func (s *store) CreateRefreshToken(ctx context.Context, refreshToken *RefreshToken) (*RefreshToken, error) {
+	if err := s.db.WithContext(ctx).Create(refreshToken).Error; err != nil {
+		return nil, err
+	}
+	return refreshToken, nil
+}
+
+func (s *store) GetRefreshToken(ctx context.Context, hash string) (*RefreshToken, error) {
+	refreshToken := new(RefreshToken)
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", hash).First(refreshToken).Error; err != nil {
+		return nil, err
+	}
+	return refreshToken, nil
+}
+
+func (s *store) RotateRefreshToken(ctx context.Context, used *RefreshToken, next *RefreshToken) (*RefreshToken, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", used.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func (s *store) RevokeFamily(ctx context.Context, familyID string) error {
+	return s.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *store) RevokeUserTokens(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,36 @@
+package token
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockToken struct {
+	mock.Mock
+}
+
+func (m *MockToken) CreateRefreshToken(ctx context.Context, refreshToken *RefreshToken) (*RefreshToken, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(*RefreshToken), args.Error(1)
+}
+
+func (m *MockToken) GetRefreshToken(ctx context.Context, hash string) (*RefreshToken, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(*RefreshToken), args.Error(1)
+}
+
+func (m *MockToken) RotateRefreshToken(ctx context.Context, used *RefreshToken, next *RefreshToken) (*RefreshToken, error) {
+	args := m.Called(ctx, used, next)
+	return args.Get(0).(*RefreshToken), args.Error(1)
+}
+
+func (m *MockToken) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *MockToken) RevokeUserTokens(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
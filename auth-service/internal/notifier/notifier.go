@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// PasswordReset is sent to a user who asked to reset their password.
+type PasswordReset struct {
+	UserID    uint      `json:"user_id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Notifier delivers messages to users. Users only have a name for now, so
+// implementations that reach them out of band look up where to send it.
+type Notifier interface {
+	NotifyPasswordReset(ctx context.Context, message PasswordReset) error
+}
+
+// New returns the notifier selected by kind, "log" or "file".
+func New(kind string, path string) (Notifier, error) {
+	switch kind {
+	case "", "log":
+		return Log{Logger: log.Default()}, nil
+	case "file":
+		if path == "" {
+			return nil, fmt.Errorf("the file notifier needs a path")
+		}
+		return &File{Path: path}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier %q", kind)
+}
+
+// Log writes messages, tokens included, to a logger. For local use only.
+type Log struct {
+	Logger *log.Logger
+}
+
+func (l Log) NotifyPasswordReset(ctx context.Context, message PasswordReset) error {
+	l.Logger.Printf("password reset for %s (user %d): token %s, valid until %s",
+		message.Name, message.UserID, message.Token, message.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// File appends messages to Path as JSON lines. For local use only.
+type File struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (f *File) NotifyPasswordReset(ctx context.Context, message PasswordReset) error {
+	data, err := json.Marshal(struct {
+		Type string `json:"type"`
+		PasswordReset
+	}{"password_reset", message})
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
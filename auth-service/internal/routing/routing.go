@@ -3,16 +3,30 @@ package routing
 import (
 	"github.com/ennemli/todo/todo/configs"
 	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
+	"github.com/ennemli/todo/todo/internal/models/reset"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/notifier"
 	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/throttle"
 	"github.com/go-chi/chi/v5"
 )
 
-func InitRouting() {
+func InitRouting(revocations revocation.Store, n notifier.Notifier) {
 	r := server.GetRouter()
 
-	authHandler := handlers.NewAuthHandler(new(handlers.UserServiceClient))
+	userService := new(handlers.UserServiceClient)
+	tokens := token.NewStore()
+	authHandler := handlers.NewAuthHandler(userService, tokens, revocations, throttle.NewMemory(handlers.LoginPolicy()))
+	resetHandler := handlers.NewPasswordResetHandler(userService, reset.NewStore(), tokens, revocations, n)
 	r.Route(configs.GetConfig().Service.API_ENDPOINT, func(r chi.Router) {
 		r.Post("/", authHandler.LoginHandler)
 		r.Post("/valid", authHandler.ValidateHandler)
+		r.Post("/refresh", authHandler.RefreshHandler)
+		r.Post("/logout", authHandler.LogoutHandler)
+		r.Post("/password/forgot", resetHandler.ForgotPasswordHandler)
+		r.Post("/password/reset", resetHandler.ResetPasswordHandler)
+		r.Get("/internal/revocations", authHandler.RevocationsHandler)
+		r.Get("/.well-known/jwks.json", authHandler.JWKSHandler)
 	})
 }
@@ -10,8 +10,11 @@ import (
 	"testing"
 
 	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
 	"github.com/ennemli/todo/todo/internal/server"
 	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/throttle"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -25,7 +28,17 @@ func (m *UserServiceClientMock) GetUser(ctx context.Context, name string) (*hand
 	return args.Get(0).(*handlers.User), args.Error(1)
 }
 
-func login(T *testing.T, m *UserServiceClientMock) string {
+func (m *UserServiceClientMock) GetUserById(ctx context.Context, id uint) (*handlers.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*handlers.User), args.Error(1)
+}
+
+func (m *UserServiceClientMock) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	args := m.Called(ctx, id, hash)
+	return args.Error(0)
+}
+
+func login(T *testing.T, m *UserServiceClientMock, mt *token.MockToken) string {
 	credential := handlers.Credential{
 		Name:     "Guts",
 		Password: "123445",
@@ -35,8 +48,12 @@ func login(T *testing.T, m *UserServiceClientMock) string {
 		ID:       1,
 		Name:     credential.Name,
 		Password: hashedPassword,
+		Role:     "admin",
 	}
 	m.On("GetUser", mock.Anything, credential.Name).Return(expectedUser, nil)
+	mt.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(t *token.RefreshToken) bool {
+		return t.UserID == expectedUser.ID && t.FamilyID != "" && t.TokenHash != ""
+	})).Return(&token.RefreshToken{}, nil)
 
 	body, _ := json.Marshal(credential)
 	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
@@ -54,6 +71,7 @@ func login(T *testing.T, m *UserServiceClientMock) string {
 	assert.NotNil(T, tokenCookie)
 
 	m.AssertExpectations(T)
+	mt.AssertExpectations(T)
 	return tokenCookie.Value
 }
 
@@ -62,24 +80,33 @@ func TestLogin(T *testing.T) {
 	InitServe()
 	r := server.GetRouter()
 	m := new(UserServiceClientMock)
-	authHandler := handlers.NewAuthHandler(m)
+	mt := new(token.MockToken)
+	authHandler := handlers.NewAuthHandler(m, mt, new(revocation.MockRevocation), throttle.NewMemory(throttle.DefaultPolicy))
 	r.Post("/login", authHandler.LoginHandler)
-	login(T, m)
+	login(T, m, mt)
 }
 
 func TestValidate(T *testing.T) {
 	InitServe()
 	r := server.GetRouter()
 	m := new(UserServiceClientMock)
-	authHandler := handlers.NewAuthHandler(m)
+	mt := new(token.MockToken)
+	authHandler := handlers.NewAuthHandler(m, mt, new(revocation.MockRevocation), throttle.NewMemory(throttle.DefaultPolicy))
 	r.Post("/login", authHandler.LoginHandler)
 	r.Post("/auth/validate", authHandler.ValidateHandler)
-	tokenString := login(T, m)
+	tokenString := login(T, m, mt)
 	req, _ := http.NewRequest("POST", "/auth/validate", nil)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tokenString))
 	res := MakeRequest(req)
 
 	assert.Equal(T, http.StatusOK, res.Code)
+	var identity handlers.Identity
+	err := json.NewDecoder(res.Body).Decode(&identity)
+	assert.Nil(T, err)
+	assert.Equal(T, uint(1), identity.ID)
+	assert.Equal(T, "Guts", identity.Name)
+	assert.Equal(T, []string{"admin"}, identity.Roles)
+	assert.NotZero(T, identity.ExpiresAt)
 }
 
 func TestInvalidToken(T *testing.T) {
@@ -98,7 +125,8 @@ func TestInvalidToken(T *testing.T) {
 	InitServe()
 	r := server.GetRouter()
 	m := new(UserServiceClientMock)
-	authHandler := handlers.NewAuthHandler(m)
+	mt := new(token.MockToken)
+	authHandler := handlers.NewAuthHandler(m, mt, new(revocation.MockRevocation), throttle.NewMemory(throttle.DefaultPolicy))
 	r.Post("/auth/validate", authHandler.ValidateHandler)
 	for _, tc := range tt {
 		T.Run(tc.name, func(T *testing.T) {
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/throttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var loginTestPolicy = throttle.Policy{
+	FreeAttempts:    1,
+	BaseDelay:       time.Minute,
+	MaxDelay:        time.Hour,
+	LockoutAttempts: 5,
+	Lockout:         time.Hour,
+	Window:          time.Hour,
+}
+
+func setupLogin(T *testing.T) *UserServiceClientMock {
+	InitServe()
+	m := new(UserServiceClientMock)
+	hashedPassword, _ := crypto.HashPassword("123445")
+	m.On("GetUser", mock.Anything, "Guts").Return(&handlers.User{ID: 1, Name: "Guts", Password: hashedPassword}, nil)
+	for _, name := range []string{"Nobody", "Someone"} {
+		m.On("GetUser", mock.Anything, name).Return((*handlers.User)(nil), gorm.ErrRecordNotFound)
+	}
+	mt := new(token.MockToken)
+	mt.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(&token.RefreshToken{}, nil)
+	authHandler := handlers.NewAuthHandler(m, mt, new(revocation.MockRevocation), throttle.NewMemory(loginTestPolicy))
+	server.GetRouter().Post("/login", authHandler.LoginHandler)
+	return m
+}
+
+func attemptLogin(name string, password string, ip string) *http.Response {
+	body, _ := json.Marshal(handlers.Credential{Name: name, Password: password})
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	req.Header.Set("X-Forwarded-For", ip)
+	return MakeRequest(req).Result()
+}
+
+func TestLoginUniformError(T *testing.T) {
+	setupLogin(T)
+
+	messages := []string{}
+	for _, name := range []string{"Guts", "Nobody"} {
+		res := attemptLogin(name, "wrong-password", "10.0.0."+name)
+		assert.Equal(T, http.StatusUnauthorized, res.StatusCode)
+		var errorResponse errors.ErrorResponse
+		json.NewDecoder(res.Body).Decode(&errorResponse)
+		messages = append(messages, errorResponse.Message)
+	}
+	assert.Equal(T, messages[0], messages[1])
+}
+
+func TestLoginThrottledPerName(T *testing.T) {
+	setupLogin(T)
+
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Guts", "wrong-password", "10.0.1.1").StatusCode)
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Guts", "wrong-password", "10.0.1.2").StatusCode)
+
+	res := attemptLogin("Guts", "123445", "10.0.1.3")
+	assert.Equal(T, http.StatusTooManyRequests, res.StatusCode)
+	// The delay runs from the failed attempt, not from the end of its check.
+	retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	assert.Nil(T, err)
+	assert.True(T, retryAfter > 0 && retryAfter <= 60, "Retry-After %d", retryAfter)
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Nobody", "wrong-password", "10.0.1.4").StatusCode)
+}
+
+func TestLoginThrottledPerIP(T *testing.T) {
+	setupLogin(T)
+
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Nobody", "wrong-password", "10.0.2.1").StatusCode)
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Someone", "wrong-password", "10.0.2.1").StatusCode)
+	assert.Equal(T, http.StatusTooManyRequests, attemptLogin("Guts", "123445", "10.0.2.1").StatusCode)
+	assert.Equal(T, http.StatusOK, attemptLogin("Guts", "123445", "10.0.2.2").StatusCode)
+}
+
+func TestLoginSuccessResetsName(T *testing.T) {
+	setupLogin(T)
+
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Guts", "wrong-password", "10.0.3.1").StatusCode)
+	assert.Equal(T, http.StatusOK, attemptLogin("Guts", "123445", "10.0.3.2").StatusCode)
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Guts", "wrong-password", "10.0.3.3").StatusCode)
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Guts", "wrong-password", "10.0.3.4").StatusCode)
+}
+
+func TestLoginSuccessDoesNotCountAgainstIP(T *testing.T) {
+	setupLogin(T)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(T, http.StatusOK, attemptLogin("Guts", "123445", "10.0.5.1").StatusCode)
+	}
+	assert.Equal(T, http.StatusUnauthorized, attemptLogin("Nobody", "wrong-password", "10.0.5.1").StatusCode)
+}
+
+func TestLoginConcurrentGuessesAreThrottled(T *testing.T) {
+	InitServe()
+	m := new(UserServiceClientMock)
+	hashedPassword, _ := crypto.HashPassword("123445")
+	m.On("GetUser", mock.Anything, "Griffith").After(50*time.Millisecond).Return(&handlers.User{ID: 3, Name: "Griffith", Password: hashedPassword}, nil)
+	authHandler := handlers.NewAuthHandler(m, new(token.MockToken), new(revocation.MockRevocation), throttle.NewMemory(loginTestPolicy))
+	server.GetRouter().Post("/login", authHandler.LoginHandler)
+
+	codes := make(chan int, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- attemptLogin("Griffith", "wrong-password", "10.0.6.1").StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	checked := 0
+	for code := range codes {
+		if code != http.StatusTooManyRequests {
+			checked++
+		}
+	}
+	assert.Equal(T, loginTestPolicy.FreeAttempts+1, checked)
+}
+
+func TestLoginRehashesOutdatedHash(T *testing.T) {
+	InitServe()
+	m := new(UserServiceClientMock)
+	outdated, _ := bcrypt.GenerateFromPassword([]byte("123445"), bcrypt.MinCost)
+	m.On("GetUser", mock.Anything, "Casca").Return(&handlers.User{ID: 2, Name: "Casca", Password: string(outdated)}, nil)
+	m.On("UpdatePassword", mock.Anything, uint(2), mock.MatchedBy(func(hash string) bool {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err == nil && cost == bcrypt.DefaultCost && bcrypt.CompareHashAndPassword([]byte(hash), []byte("123445")) == nil
+	})).Return(nil)
+	mt := new(token.MockToken)
+	mt.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(&token.RefreshToken{}, nil)
+	authHandler := handlers.NewAuthHandler(m, mt, new(revocation.MockRevocation), throttle.NewMemory(loginTestPolicy))
+	server.GetRouter().Post("/login", authHandler.LoginHandler)
+
+	assert.Equal(T, http.StatusOK, attemptLogin("Casca", "123445", "10.0.4.1").StatusCode)
+	m.AssertExpectations(T)
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/jwt"
+	"github.com/ennemli/todo/todo/pkg/throttle"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type revokeAll struct{}
+
+func (revokeAll) IsRevoked(claims *jwt.Claims) bool { return true }
+
+func setupLogout(T *testing.T) (string, *token.MockToken, *revocation.MockRevocation) {
+	InitServe()
+	r := server.GetRouter()
+	m := new(UserServiceClientMock)
+	mt := new(token.MockToken)
+	mr := new(revocation.MockRevocation)
+	authHandler := handlers.NewAuthHandler(m, mt, mr, throttle.NewMemory(throttle.DefaultPolicy))
+	r.Post("/login", authHandler.LoginHandler)
+	r.Post("/auth/logout", authHandler.LogoutHandler)
+	r.Post("/auth/validate", authHandler.ValidateHandler)
+	return login(T, m, mt), mt, mr
+}
+
+func logout(tokenString string, request handlers.LogoutRequest) int {
+	body, _ := json.Marshal(request)
+	req, _ := http.NewRequest("POST", "/auth/logout", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tokenString))
+	return MakeRequest(req).Code
+}
+
+func TestLogoutRevokesToken(T *testing.T) {
+	tokenString, mt, mr := setupLogout(T)
+	mr.On("RevokeToken", mock.Anything, mock.MatchedBy(func(jti string) bool { return jti != "" }), uint(1), mock.Anything).Return(nil)
+
+	assert.Equal(T, http.StatusNoContent, logout(tokenString, handlers.LogoutRequest{}))
+
+	mr.AssertExpectations(T)
+	mr.AssertNotCalled(T, "RevokeUser", mock.Anything, mock.Anything)
+	mt.AssertNotCalled(T, "RevokeUserTokens", mock.Anything, mock.Anything)
+}
+
+func TestLogoutAllSessions(T *testing.T) {
+	tokenString, mt, mr := setupLogout(T)
+	mr.On("RevokeToken", mock.Anything, mock.Anything, uint(1), mock.Anything).Return(nil)
+	mr.On("RevokeUser", mock.Anything, uint(1)).Return(nil)
+	mt.On("RevokeUserTokens", mock.Anything, uint(1)).Return(nil)
+
+	assert.Equal(T, http.StatusNoContent, logout(tokenString, handlers.LogoutRequest{All: true}))
+
+	mr.AssertExpectations(T)
+	mt.AssertExpectations(T)
+}
+
+func TestRevokedTokenIsInvalid(T *testing.T) {
+	tokenString, _, _ := setupLogout(T)
+	jwt.SetRevocationList(revokeAll{})
+	defer jwt.SetRevocationList(nil)
+
+	req, _ := http.NewRequest("POST", "/auth/validate", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tokenString))
+	assert.Equal(T, http.StatusUnauthorized, MakeRequest(req).Code)
+
+	assert.Equal(T, http.StatusUnauthorized, logout(tokenString, handlers.LogoutRequest{}))
+}
+
+func TestCheckerUsesTheExactIssueTime(T *testing.T) {
+	mr := new(revocation.MockRevocation)
+	issuedAt := time.Date(2024, 5, 24, 9, 0, 0, 250000000, time.UTC)
+	claims := jwt.NewClaims(1, "user", issuedAt.Add(time.Hour))
+	claims.IssuedAt = gojwt.NewNumericDate(issuedAt)
+	claims.ID = "jti"
+	mr.On("IsRevoked", mock.Anything, "jti", uint(1), issuedAt).Return(true, nil)
+
+	assert.True(T, revocation.Checker{Store: mr}.IsRevoked(claims))
+	mr.AssertExpectations(T)
+}
+
+func TestRevocations(T *testing.T) {
+	InitServe()
+	r := server.GetRouter()
+	mr := new(revocation.MockRevocation)
+	authHandler := handlers.NewAuthHandler(new(UserServiceClientMock), new(token.MockToken), mr, throttle.NewMemory(throttle.DefaultPolicy))
+	r.Get("/auth/internal/revocations", authHandler.RevocationsHandler)
+	since := time.Date(2024, 5, 24, 9, 0, 0, 0, time.UTC)
+	mr.On("GetRevocations", mock.Anything, since).Return(&revocation.Revocations{
+		Tokens: []revocation.RevokedToken{{JTI: "jti", UserID: 1}},
+		Users:  []revocation.UserRevocation{{UserID: 2}},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/auth/internal/revocations?since="+since.Format(time.RFC3339Nano), nil)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var revocations revocation.Revocations
+	err := json.NewDecoder(res.Body).Decode(&revocations)
+	assert.Nil(T, err)
+	assert.Equal(T, "jti", revocations.Tokens[0].JTI)
+	assert.Equal(T, uint(2), revocations.Users[0].UserID)
+	mr.AssertExpectations(T)
+
+	req, _ = http.NewRequest("GET", "/auth/internal/revocations?since=yesterday", nil)
+	assert.Equal(T, http.StatusBadRequest, MakeRequest(req).Code)
+}
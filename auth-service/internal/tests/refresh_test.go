@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/ennemli/todo/todo/pkg/throttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func refresh(refreshToken string) *http.Response {
+	body, _ := json.Marshal(handlers.RefreshRequest{RefreshToken: refreshToken})
+	req, _ := http.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(body))
+	return MakeRequest(req).Result()
+}
+
+func setupRefresh() (*UserServiceClientMock, *token.MockToken) {
+	InitServe()
+	r := server.GetRouter()
+	m := new(UserServiceClientMock)
+	mt := new(token.MockToken)
+	mr := new(revocation.MockRevocation)
+	mr.On("IsRevoked", mock.Anything, "", uint(1), mock.Anything).Return(false, nil)
+	authHandler := handlers.NewAuthHandler(m, mt, mr, throttle.NewMemory(throttle.DefaultPolicy))
+	r.Post("/auth/refresh", authHandler.RefreshHandler)
+	return m, mt
+}
+
+func TestRefreshRotatesToken(T *testing.T) {
+	m, mt := setupRefresh()
+	stored := &token.RefreshToken{
+		UserID:    1,
+		FamilyID:  "family",
+		TokenHash: crypto.HashToken("old-token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	m.On("GetUserById", mock.Anything, uint(1)).Return(&handlers.User{ID: 1, Name: "Guts"}, nil)
+	mt.On("GetRefreshToken", mock.Anything, stored.TokenHash).Return(stored, nil)
+	mt.On("RotateRefreshToken", mock.Anything, stored, mock.MatchedBy(func(next *token.RefreshToken) bool {
+		return next.UserID == 1 && next.FamilyID == "family" && next.TokenHash != stored.TokenHash
+	})).Return(&token.RefreshToken{}, nil)
+
+	res := refresh("old-token")
+
+	assert.Equal(T, http.StatusOK, res.StatusCode)
+	var response handlers.ResponseMessage
+	err := json.NewDecoder(res.Body).Decode(&response)
+	assert.Nil(T, err)
+	assert.NotEmpty(T, response.Token)
+	assert.NotEmpty(T, response.RefreshToken)
+	assert.NotEqual(T, "old-token", response.RefreshToken)
+	m.AssertExpectations(T)
+	mt.AssertExpectations(T)
+}
+
+func TestRefreshReuseRevokesFamily(T *testing.T) {
+	_, mt := setupRefresh()
+	usedAt := time.Now().Add(-time.Minute)
+	stored := &token.RefreshToken{
+		UserID:    1,
+		FamilyID:  "family",
+		TokenHash: crypto.HashToken("stolen-token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+		UsedAt:    &usedAt,
+	}
+	mt.On("GetRefreshToken", mock.Anything, stored.TokenHash).Return(stored, nil)
+	mt.On("RevokeFamily", mock.Anything, "family").Return(nil)
+
+	res := refresh("stolen-token")
+
+	assert.Equal(T, http.StatusUnauthorized, res.StatusCode)
+	mt.AssertExpectations(T)
+	mt.AssertNotCalled(T, "RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRefreshConcurrentReuseRevokesFamily(T *testing.T) {
+	m, mt := setupRefresh()
+	stored := &token.RefreshToken{
+		UserID:    1,
+		FamilyID:  "family",
+		TokenHash: crypto.HashToken("raced-token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	m.On("GetUserById", mock.Anything, uint(1)).Return(&handlers.User{ID: 1, Name: "Guts"}, nil)
+	mt.On("GetRefreshToken", mock.Anything, stored.TokenHash).Return(stored, nil)
+	mt.On("RotateRefreshToken", mock.Anything, stored, mock.Anything).Return((*token.RefreshToken)(nil), token.ErrTokenReused)
+	mt.On("RevokeFamily", mock.Anything, "family").Return(nil)
+
+	res := refresh("raced-token")
+
+	assert.Equal(T, http.StatusUnauthorized, res.StatusCode)
+	mt.AssertExpectations(T)
+}
+
+func TestRefreshInvalid(T *testing.T) {
+	revokedAt := time.Now()
+	tt := []struct {
+		name     string
+		token    string
+		stored   *token.RefreshToken
+		err      error
+		expected int
+	}{
+		{"Missing", "", nil, nil, http.StatusBadRequest},
+		{"Unknown", "unknown", nil, gorm.ErrRecordNotFound, http.StatusUnauthorized},
+		{"Expired", "expired", &token.RefreshToken{UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil, http.StatusUnauthorized},
+		{"Revoked", "revoked", &token.RefreshToken{UserID: 1, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil, http.StatusUnauthorized},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			_, mt := setupRefresh()
+			mt.On("GetRefreshToken", mock.Anything, crypto.HashToken(tc.token)).Return(tc.stored, tc.err)
+
+			res := refresh(tc.token)
+
+			assert.Equal(T, tc.expected, res.StatusCode)
+			mt.AssertNotCalled(T, "RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
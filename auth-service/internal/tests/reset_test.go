@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/auth"
+	"github.com/ennemli/todo/todo/internal/models/reset"
+	"github.com/ennemli/todo/todo/internal/models/revocation"
+	"github.com/ennemli/todo/todo/internal/models/token"
+	"github.com/ennemli/todo/todo/internal/notifier"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type recordingNotifier struct {
+	sent []notifier.PasswordReset
+}
+
+func (n *recordingNotifier) NotifyPasswordReset(ctx context.Context, message notifier.PasswordReset) error {
+	n.sent = append(n.sent, message)
+	return nil
+}
+
+func postJSON(path string, body interface{}) *http.Response {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(data))
+	return MakeRequest(req).Result()
+}
+
+func TestForgotPassword(T *testing.T) {
+	InitServe()
+	m := new(UserServiceClientMock)
+	m.On("GetUser", mock.Anything, "Guts").Return(&handlers.User{ID: 1, Name: "Guts"}, nil)
+	m.On("GetUser", mock.Anything, "Nobody").Return((*handlers.User)(nil), gorm.ErrRecordNotFound)
+	rs := new(reset.MockReset)
+	rs.On("CreateResetToken", mock.Anything, mock.MatchedBy(func(t *reset.ResetToken) bool {
+		return t.UserID == 1 && t.TokenHash != ""
+	})).Return(&reset.ResetToken{}, nil)
+	n := new(recordingNotifier)
+	resetHandler := handlers.NewPasswordResetHandler(m, rs, new(token.MockToken), new(revocation.MockRevocation), n)
+	server.GetRouter().Post("/password/forgot", resetHandler.ForgotPasswordHandler)
+
+	for _, name := range []string{"Guts", "Nobody"} {
+		res := postJSON("/password/forgot", handlers.ForgotPasswordRequest{Name: name})
+		assert.Equal(T, http.StatusAccepted, res.StatusCode)
+	}
+
+	rs.AssertNumberOfCalls(T, "CreateResetToken", 1)
+	if assert.Len(T, n.sent, 1) {
+		assert.Equal(T, uint(1), n.sent[0].UserID)
+		stored := rs.Calls[0].Arguments.Get(1).(*reset.ResetToken)
+		assert.Equal(T, crypto.HashToken(n.sent[0].Token), stored.TokenHash)
+		assert.Equal(T, stored.ExpiresAt, n.sent[0].ExpiresAt)
+	}
+}
+
+func TestResetPassword(T *testing.T) {
+	InitServe()
+	m := new(UserServiceClientMock)
+	m.On("UpdatePassword", mock.Anything, uint(1), mock.MatchedBy(func(hash string) bool {
+		ok, _, _ := crypto.VerifyPassword(hash, "Griffith-1")
+		return ok
+	})).Return(nil)
+	m.On("UpdatePassword", mock.Anything, uint(2), mock.Anything).Return(gorm.ErrRecordNotFound)
+	rs := new(reset.MockReset)
+	rs.On("ConsumeResetToken", mock.Anything, crypto.HashToken("valid-token")).Return(&reset.ResetToken{UserID: 1}, nil).Once()
+	rs.On("ConsumeResetToken", mock.Anything, crypto.HashToken("deleted-user-token")).Return(&reset.ResetToken{UserID: 2}, nil).Once()
+	rs.On("ConsumeResetToken", mock.Anything, mock.Anything).Return((*reset.ResetToken)(nil), reset.ErrInvalidResetToken)
+	mt := new(token.MockToken)
+	mt.On("RevokeUserTokens", mock.Anything, uint(1)).Return(nil)
+	mr := new(revocation.MockRevocation)
+	mr.On("RevokeUser", mock.Anything, uint(1)).Return(nil)
+	resetHandler := handlers.NewPasswordResetHandler(m, rs, mt, mr, new(recordingNotifier))
+	server.GetRouter().Post("/password/reset", resetHandler.ResetPasswordHandler)
+
+	tt := []struct {
+		name     string
+		token    string
+		password string
+		expected int
+	}{
+		{"WeakPassword", "valid-token", "123", http.StatusBadRequest},
+		{"Valid", "valid-token", "Griffith-1", http.StatusNoContent},
+		{"Reused", "valid-token", "Griffith-1", http.StatusBadRequest},
+		{"Unknown", "unknown-token", "Griffith-1", http.StatusBadRequest},
+		{"DeletedUser", "deleted-user-token", "Griffith-1", http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			res := postJSON("/password/reset", handlers.ResetPasswordRequest{Token: tc.token, NewPassword: tc.password})
+			assert.Equal(T, tc.expected, res.StatusCode)
+		})
+	}
+	m.AssertNumberOfCalls(T, "UpdatePassword", 2)
+	mt.AssertExpectations(T)
+	mr.AssertExpectations(T)
+}
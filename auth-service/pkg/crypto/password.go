@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// HashParams selects the algorithm and cost of new password hashes. Stored
+// hashes made with other parameters still verify but are reported as
+// needing a rehash.
+type HashParams struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+}
+
+var DefaultHashParams = HashParams{
+	Algorithm:     AlgorithmBcrypt,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+	Argon2KeyLen:  32,
+	Argon2SaltLen: 16,
+}
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+var (
+	paramsMu   sync.RWMutex
+	hashParams = DefaultHashParams
+)
+
+// SetHashParams changes the parameters of new hashes. Zero fields keep their
+// default.
+func SetHashParams(p HashParams) error {
+	d := DefaultHashParams
+	if p.Algorithm == "" {
+		p.Algorithm = d.Algorithm
+	}
+	if p.Algorithm != AlgorithmBcrypt && p.Algorithm != AlgorithmArgon2id {
+		return fmt.Errorf("unknown password hash algorithm %q", p.Algorithm)
+	}
+	if p.BcryptCost == 0 {
+		p.BcryptCost = d.BcryptCost
+	}
+	if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost %d out of range", p.BcryptCost)
+	}
+	if p.Argon2Time == 0 {
+		p.Argon2Time = d.Argon2Time
+	}
+	if p.Argon2Memory == 0 {
+		p.Argon2Memory = d.Argon2Memory
+	}
+	if p.Argon2Threads == 0 {
+		p.Argon2Threads = d.Argon2Threads
+	}
+	if p.Argon2KeyLen == 0 {
+		p.Argon2KeyLen = d.Argon2KeyLen
+	}
+	if p.Argon2SaltLen == 0 {
+		p.Argon2SaltLen = d.Argon2SaltLen
+	}
+	paramsMu.Lock()
+	defer paramsMu.Unlock()
+	hashParams = p
+	return nil
+}
+
+func currentParams() HashParams {
+	paramsMu.RLock()
+	defer paramsMu.RUnlock()
+	return hashParams
+}
+
+func HashPassword(password string) (string, error) {
+	p := currentParams()
+	if p.Algorithm == AlgorithmArgon2id {
+		return hashArgon2id(password, p)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+	return string(bytes), err
+}
+
+// VerifyPassword reports whether password matches hash and, when it does,
+// whether hash should be replaced because it was made with an outdated
+// algorithm or cost.
+func VerifyPassword(hash string, password string) (ok bool, needsRehash bool, err error) {
+	p := currentParams()
+	if strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$") {
+		stored, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, stored.Argon2Time, stored.Argon2Memory, stored.Argon2Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false, nil
+		}
+		outdated := p.Algorithm != AlgorithmArgon2id ||
+			stored.Argon2Time != p.Argon2Time ||
+			stored.Argon2Memory != p.Argon2Memory ||
+			stored.Argon2Threads != p.Argon2Threads ||
+			uint32(len(key)) != p.Argon2KeyLen
+		return true, outdated, nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, err
+	}
+	return true, p.Algorithm != AlgorithmBcrypt || cost != p.BcryptCost, nil
+}
+
+// hashArgon2id encodes the hash in the PHC string format,
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func hashArgon2id(password string, p HashParams) (string, error) {
+	salt := make([]byte, p.Argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, p.Argon2KeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id,
+		argon2.Version,
+		p.Argon2Memory,
+		p.Argon2Time,
+		p.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(hash string) (HashParams, []byte, []byte, error) {
+	var p HashParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Argon2Memory, &p.Argon2Time, &p.Argon2Threads); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidHash
+	}
+	return p, salt, key, nil
+}
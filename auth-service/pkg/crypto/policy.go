@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// PasswordPolicy describes the passwords users may choose. MinLength and
+// MaxLength count characters, and MinClasses is the number of character
+// classes (lower case, upper case, digits and symbols) a password has to mix.
+type PasswordPolicy struct {
+	MinLength    int
+	MaxLength    int
+	MinClasses   int
+	RejectCommon bool
+}
+
+// bcryptMaxBytes is the length past which bcrypt ignores a password.
+const bcryptMaxBytes = 72
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	MaxLength:    128,
+	MinClasses:   2,
+	RejectCommon: true,
+}
+
+// Validate returns an error describing the first rule password breaks. The
+// message is meant to be shown to the user.
+func (p PasswordPolicy) Validate(password string) error {
+	length := len([]rune(password))
+	if length < p.MinLength {
+		return fmt.Errorf("Password must contain at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("Password must contain at most %d characters", p.MaxLength)
+	}
+	if currentParams().Algorithm == AlgorithmBcrypt && len(password) > bcryptMaxBytes {
+		return fmt.Errorf("Password must be at most %d bytes long", bcryptMaxBytes)
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return fmt.Errorf("Password must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses)
+	}
+	if p.RejectCommon && IsCommonPassword(password) {
+		return fmt.Errorf("Password is too common")
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// IsCommonPassword checks password, case insensitively, against the bundled
+// list of common and breached passwords.
+func IsCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		for _, line := range strings.Split(commonPasswordList, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				commonPasswords[strings.ToLower(line)] = struct{}{}
+			}
+		}
+	})
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ennemli/todo/todo/configs"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Defaults used when JWT_ISSUER and JWT_AUDIENCE are not configured.
+const (
+	DefaultIssuer   = "todo-auth"
+	DefaultAudience = "todo-api"
+)
+
+// Times in tokens carry microseconds, so that a token issued right before
+// its user's sessions are revoked is told apart from one issued right after.
+func init() {
+	gojwt.TimePrecision = time.Microsecond
+}
+
+// Claims are the claims carried by access tokens. The subject is the user id.
+type Claims struct {
+	Name   string   `json:"name,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	gojwt.RegisteredClaims
+}
+
+func NewClaims(userID uint, name string, exp time.Time) *Claims {
+	return &Claims{
+		Name: name,
+		RegisteredClaims: gojwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			ExpiresAt: gojwt.NewNumericDate(exp),
+		},
+	}
+}
+
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 0)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("token has no user")
+	}
+	return uint(id), nil
+}
+
+// Validate is called by the parser after the registered claims are checked.
+func (c *Claims) Validate() error {
+	_, err := c.UserID()
+	return err
+}
+
+func issuer() string {
+	if iss := configs.GetConfig().Service.JWT_ISSUER; iss != "" {
+		return iss
+	}
+	return DefaultIssuer
+}
+
+func audience() string {
+	if aud := configs.GetConfig().Service.JWT_AUDIENCE; aud != "" {
+		return aud
+	}
+	return DefaultAudience
+}
+
+// ParserOptions are the checks applied to every token on top of the
+// signature: exp is required, iss and aud must match the config and exp, nbf
+// and iat are allowed JWT_LEEWAY of clock skew.
+func ParserOptions() []gojwt.ParserOption {
+	return []gojwt.ParserOption{
+		gojwt.WithExpirationRequired(),
+		gojwt.WithIssuedAt(),
+		gojwt.WithIssuer(issuer()),
+		gojwt.WithAudience(audience()),
+		gojwt.WithLeeway(configs.GetConfig().Service.JWT_LEEWAY),
+	}
+}
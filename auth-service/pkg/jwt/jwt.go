@@ -1,41 +1,138 @@
 package jwt
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/pkg/crypto"
 	gojwt "github.com/golang-jwt/jwt/v5"
 )
 
-func Create(data interface{}, exp int64) (string, error) {
-	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256,
-		gojwt.MapClaims{
-			"data": data,
-			"exp":  exp,
-		})
-	tokenStr, err := token.SignedString([]byte(configs.GetConfig().Service.JWT_SK))
+var ErrTokenRevoked = errors.New("token revoked")
+
+// RevocationList is consulted by Validate once a token's signature and exp
+// have been checked.
+type RevocationList interface {
+	IsRevoked(claims *Claims) bool
+}
+
+var (
+	mu          sync.Mutex
+	defaultKeys *KeySet
+	revocations RevocationList
+)
+
+// LoadKeySet builds the key set from the service config. Tokens are signed
+// with JWT_SIGNING_KEY_FILE (RS256 or EdDSA depending on the key type) when
+// it is set and with the JWT_SK secret otherwise. JWT_VERIFY_KEY_FILES lists
+// retired keys that are still accepted. Once tokens are signed with a private
+// key, HS256 tokens are only accepted with JWT_ACCEPT_HS256, which is meant
+// for the time a migration takes.
+func LoadKeySet() (*KeySet, error) {
+	config := configs.GetConfig().Service
+	var hmac *Key
+	if config.JWT_SK != "" {
+		hmac = HMACKey([]byte(config.JWT_SK))
+	}
+	signing := hmac
+	verify := []*Key{}
+	if config.JWT_SIGNING_KEY_FILE != "" {
+		key, err := LoadPrivateKey(config.JWT_SIGNING_KEY_FILE)
+		if err != nil {
+			return nil, err
+		}
+		signing = key
+		if config.JWT_ACCEPT_HS256 {
+			verify = append(verify, hmac)
+		}
+	}
+	for _, path := range strings.Split(config.JWT_VERIFY_KEY_FILES, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := LoadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		verify = append(verify, key)
+	}
+	return NewKeySet(signing, verify...)
+}
+
+// Load (re)reads the keys from the config, e.g. after a rotation.
+func Load() error {
+	ks, err := LoadKeySet()
 	if err != nil {
-		return "", err
+		return err
 	}
-	return tokenStr, nil
+	SetKeySet(ks)
+	return nil
+}
+
+func SetKeySet(ks *KeySet) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultKeys = ks
 }
 
-func Validate(tokenString string) (gojwt.MapClaims, error) {
-	token, err := gojwt.Parse(tokenString, func(token *gojwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*gojwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+func Keys() (*KeySet, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if defaultKeys == nil {
+		ks, err := LoadKeySet()
+		if err != nil {
+			return nil, err
 		}
-		return []byte(configs.GetConfig().Service.JWT_SK), nil
-	})
-	if err != nil {
-		return nil, err
+		defaultKeys = ks
 	}
-	if !token.Valid {
-		return nil, fmt.Errorf("token invalid")
+	return defaultKeys, nil
+}
+
+func SetRevocationList(rl RevocationList) {
+	mu.Lock()
+	defer mu.Unlock()
+	revocations = rl
+}
+
+// Create signs claims after filling in the issuer, audience, iat, nbf and a
+// fresh jti.
+func Create(claims *Claims) (string, error) {
+	ks, err := Keys()
+	if err != nil {
+		return "", err
 	}
-	if claims, ok := token.Claims.(gojwt.MapClaims); ok {
-		return claims, nil
+	jti, err := crypto.NewOpaqueToken()
+	if err != nil {
+		return "", err
 	}
+	now := gojwt.NewNumericDate(time.Now())
+	claims.Issuer = issuer()
+	claims.Audience = gojwt.ClaimStrings{audience()}
+	claims.IssuedAt = now
+	claims.NotBefore = now
+	claims.ID = jti
+	return ks.Sign(claims)
+}
 
-	return nil, fmt.Errorf("token invalid")
+func Validate(tokenString string) (*Claims, error) {
+	ks, err := Keys()
+	if err != nil {
+		return nil, fmt.Errorf("token invalid: %w", err)
+	}
+	claims, err := ks.Verify(tokenString, ParserOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	rl := revocations
+	mu.Unlock()
+	if rl != nil && rl.IsRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
 }
@@ -0,0 +1,257 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+func writePEM(T *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(T.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		T.Fatal(err)
+	}
+	return path
+}
+
+func rsaKeyFile(T *testing.T) string {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		T.Fatal(err)
+	}
+	return writePEM(T, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(private))
+}
+
+func ed25519KeyFile(T *testing.T) string {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		T.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		T.Fatal(err)
+	}
+	return writePEM(T, "PRIVATE KEY", der)
+}
+
+func loadKey(T *testing.T, path string) *Key {
+	key, err := LoadPrivateKey(path)
+	if err != nil {
+		T.Fatal(err)
+	}
+	return key
+}
+
+func claims() *Claims {
+	return NewClaims(1, "Guts", time.Now().Add(time.Hour))
+}
+
+func TestSignAndVerify(T *testing.T) {
+	testCases := []struct {
+		name string
+		key  *Key
+		alg  string
+	}{
+		{"HS256", HMACKey([]byte("secret")), "HS256"},
+		{"RS256", loadKey(T, rsaKeyFile(T)), "RS256"},
+		{"EdDSA", loadKey(T, ed25519KeyFile(T)), "EdDSA"},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			ks, err := NewKeySet(tc.key)
+			if err != nil {
+				T.Fatal(err)
+			}
+			tokenStr, err := ks.Sign(claims())
+			if err != nil {
+				T.Fatal(err)
+			}
+			token, _, err := gojwt.NewParser().ParseUnverified(tokenStr, gojwt.MapClaims{})
+			if err != nil {
+				T.Fatal(err)
+			}
+			if token.Header["kid"] != tc.key.ID {
+				T.Errorf("kid=%v, expected %v", token.Header["kid"], tc.key.ID)
+			}
+			if token.Method.Alg() != tc.alg {
+				T.Errorf("alg=%v, expected %v", token.Method.Alg(), tc.alg)
+			}
+			if _, err := ks.Verify(tokenStr); err != nil {
+				T.Errorf("Verify() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRotation(T *testing.T) {
+	oldPath := rsaKeyFile(T)
+	oldKey := loadKey(T, oldPath)
+	oldSet, _ := NewKeySet(oldKey)
+	oldToken, _ := oldSet.Sign(claims())
+
+	retired, err := LoadPublicKey(oldPath)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if retired.ID != oldKey.ID {
+		T.Fatalf("kid of the public key %v differs from the private key %v", retired.ID, oldKey.ID)
+	}
+	newSet, _ := NewKeySet(loadKey(T, ed25519KeyFile(T)), retired)
+	if _, err := newSet.Verify(oldToken); err != nil {
+		T.Errorf("token signed with the retired key rejected: %v", err)
+	}
+
+	dropped, _ := NewKeySet(loadKey(T, ed25519KeyFile(T)))
+	if _, err := dropped.Verify(oldToken); err == nil {
+		T.Errorf("token signed with a dropped key accepted")
+	}
+}
+
+func TestLegacyTokenWithoutKid(T *testing.T) {
+	secret := []byte("secret")
+	legacy, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims()).SignedString(secret)
+	if err != nil {
+		T.Fatal(err)
+	}
+	ks, _ := NewKeySet(loadKey(T, rsaKeyFile(T)), HMACKey(secret))
+	if _, err := ks.Verify(legacy); err != nil {
+		T.Errorf("legacy HS256 token rejected: %v", err)
+	}
+}
+
+func TestLoadKeySetHS256(T *testing.T) {
+	secret := "secret"
+	legacy, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims()).SignedString([]byte(secret))
+	if err != nil {
+		T.Fatal(err)
+	}
+	keyFile := rsaKeyFile(T)
+	T.Cleanup(func() {
+		viper.Set("JWT_SK", "")
+		viper.Set("JWT_SIGNING_KEY_FILE", "")
+		viper.Set("JWT_ACCEPT_HS256", false)
+	})
+	testCases := []struct {
+		name       string
+		keyFile    string
+		acceptHS   bool
+		acceptedHS bool
+	}{
+		{"OnlySecret", "", false, true},
+		{"PrivateKey", keyFile, false, false},
+		{"PrivateKeyOptIn", keyFile, true, true},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			viper.Set("JWT_SK", secret)
+			viper.Set("JWT_SIGNING_KEY_FILE", tc.keyFile)
+			viper.Set("JWT_ACCEPT_HS256", tc.acceptHS)
+			ks, err := LoadKeySet()
+			if err != nil {
+				T.Fatal(err)
+			}
+			if _, err := ks.Verify(legacy); (err == nil) != tc.acceptedHS {
+				T.Errorf("HS256 token accepted=%v, expected %v", err == nil, tc.acceptedHS)
+			}
+		})
+	}
+}
+
+func TestAlgorithmMismatch(T *testing.T) {
+	key := loadKey(T, rsaKeyFile(T))
+	ks, _ := NewKeySet(key)
+	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims())
+	token.Header["kid"] = key.ID
+	forged, err := token.SignedString([]byte("guessed"))
+	if err != nil {
+		T.Fatal(err)
+	}
+	if _, err := ks.Verify(forged); err == nil {
+		T.Errorf("HS256 token accepted for an RS256 kid")
+	}
+}
+
+func TestJWKS(T *testing.T) {
+	rsaKey := loadKey(T, rsaKeyFile(T))
+	edKey := loadKey(T, ed25519KeyFile(T))
+	ks, _ := NewKeySet(rsaKey, edKey, HMACKey([]byte("secret")))
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 {
+		T.Fatalf("expected 2 published keys, got %d", len(jwks.Keys))
+	}
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kid {
+		case rsaKey.ID:
+			if jwk.Kty != "RSA" || jwk.Alg != "RS256" || jwk.N == "" || jwk.E != "AQAB" {
+				T.Errorf("unexpected RSA jwk %+v", jwk)
+			}
+		case edKey.ID:
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.X == "" {
+				T.Errorf("unexpected Ed25519 jwk %+v", jwk)
+			}
+		default:
+			T.Errorf("unexpected kid %v", jwk.Kid)
+		}
+	}
+}
+
+func TestClaimsValidation(T *testing.T) {
+	ks, _ := NewKeySet(HMACKey([]byte("secret")))
+	opts := []gojwt.ParserOption{
+		gojwt.WithExpirationRequired(),
+		gojwt.WithIssuer(DefaultIssuer),
+		gojwt.WithAudience(DefaultAudience),
+		gojwt.WithLeeway(30 * time.Second),
+	}
+	valid := func() *Claims {
+		c := claims()
+		c.Issuer = DefaultIssuer
+		c.Audience = gojwt.ClaimStrings{DefaultAudience}
+		return c
+	}
+	testCases := []struct {
+		name   string
+		modify func(c *Claims)
+		valid  bool
+	}{
+		{"Valid", func(c *Claims) {}, true},
+		{"WrongIssuer", func(c *Claims) { c.Issuer = "someone-else" }, false},
+		{"WrongAudience", func(c *Claims) { c.Audience = gojwt.ClaimStrings{"another-api"} }, false},
+		{"NoSubject", func(c *Claims) { c.Subject = "" }, false},
+		{"NoExpiration", func(c *Claims) { c.ExpiresAt = nil }, false},
+		{"ExpiredWithinLeeway", func(c *Claims) { c.ExpiresAt = gojwt.NewNumericDate(time.Now().Add(-10 * time.Second)) }, true},
+		{"ExpiredPastLeeway", func(c *Claims) { c.ExpiresAt = gojwt.NewNumericDate(time.Now().Add(-time.Minute)) }, false},
+		{"NotYetValid", func(c *Claims) { c.NotBefore = gojwt.NewNumericDate(time.Now().Add(time.Minute)) }, false},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			c := valid()
+			tc.modify(c)
+			tokenStr, err := ks.Sign(c)
+			if err != nil {
+				T.Fatal(err)
+			}
+			got, err := ks.Verify(tokenStr, opts...)
+			if tc.valid != (err == nil) {
+				T.Fatalf("Verify() error = %v, expected valid=%v", err, tc.valid)
+			}
+			if !tc.valid {
+				return
+			}
+			if id, _ := got.UserID(); id != 1 || got.Name != "Guts" {
+				T.Errorf("got user %d %q, expected 1 Guts", id, got.Name)
+			}
+		})
+	}
+}
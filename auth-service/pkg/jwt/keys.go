@@ -0,0 +1,183 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// HMACKeyID is the kid of the shared HS256 secret. Tokens issued before kids
+// were introduced carry no kid and are verified with that secret as well.
+const HMACKeyID = "hs256"
+
+type Key struct {
+	ID     string
+	Method gojwt.SigningMethod
+	signer interface{}
+	public interface{}
+}
+
+func HMACKey(secret []byte) *Key {
+	return &Key{
+		ID:     HMACKeyID,
+		Method: gojwt.SigningMethodHS256,
+		signer: secret,
+		public: secret,
+	}
+}
+
+// NewKey wraps an RSA or Ed25519 private key, deriving its kid from the
+// public key so that the same PEM file always yields the same kid.
+func NewKey(private crypto.Signer) (*Key, error) {
+	key, err := NewPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.signer = private
+	return key, nil
+}
+
+func NewPublicKey(public crypto.PublicKey) (*Key, error) {
+	var method gojwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = gojwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = gojwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &Key{
+		ID:     base64.RawURLEncoding.EncodeToString(sum[:16]),
+		Method: method,
+		public: public,
+	}, nil
+}
+
+// LoadPrivateKey reads a PKCS#8 or PKCS#1 PEM encoded private key.
+func LoadPrivateKey(path string) (*Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var private interface{}
+	private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, private)
+	}
+	return NewKey(signer)
+}
+
+// LoadPublicKey reads a PEM encoded public key. A private key file is accepted
+// too, in which case only its public half is kept.
+func LoadPublicKey(path string) (*Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var public interface{}
+	public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	if err != nil {
+		key, err := LoadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		key.signer = nil
+		return key, nil
+	}
+	return NewPublicKey(public)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+// KeySet holds the key used to sign new tokens and every key that is still
+// accepted for verification, so the signing key can be rotated while tokens
+// signed with the previous one remain valid until they expire.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+func NewKeySet(signing *Key, verify ...*Key) (*KeySet, error) {
+	if signing == nil || signing.signer == nil {
+		return nil, fmt.Errorf("a signing key is required")
+	}
+	ks := &KeySet{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verify {
+		if key != nil {
+			ks.keys[key.ID] = key
+		}
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) Sign(claims gojwt.Claims) (string, error) {
+	token := gojwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.signer)
+}
+
+func (ks *KeySet) Verify(tokenString string, opts ...gojwt.ParserOption) (*Claims, error) {
+	token, err := gojwt.ParseWithClaims(tokenString, new(Claims), ks.keyFunc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("token invalid")
+	}
+	if claims, ok := token.Claims.(*Claims); ok {
+		return claims, nil
+	}
+	return nil, fmt.Errorf("token invalid")
+}
+
+func (ks *KeySet) keyFunc(token *gojwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = HMACKeyID
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key id: %v", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
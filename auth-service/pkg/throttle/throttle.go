@@ -0,0 +1,184 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker records attempts per key and tells callers how long a key has to
+// wait before its next attempt. Attempt counts an attempt as failed before it
+// is made, so that concurrent attempts cannot all get through while the first
+// ones are being checked. Release gives back an attempt that succeeded.
+type Tracker interface {
+	Attempt(keys ...string) time.Duration
+	Release(keys ...string)
+	Reset(key string)
+}
+
+// Policy lets FreeAttempts failures through, then delays each further attempt
+// by BaseDelay doubling up to MaxDelay, and locks the key out for Lockout once
+// it reaches LockoutAttempts. Failures are forgotten after Window without one.
+type Policy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAttempts int
+	Lockout         time.Duration
+	Window          time.Duration
+}
+
+var DefaultPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAttempts: 10,
+	Lockout:         15 * time.Minute,
+	Window:          time.Hour,
+}
+
+// sweepSize is the number of tracked keys above which Fail drops the
+// entries that no longer matter.
+const sweepSize = 10000
+
+type entry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Memory is an in-process Tracker.
+type Memory struct {
+	mu      sync.Mutex
+	policy  Policy
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+func NewMemory(policy Policy) *Memory {
+	return &Memory{
+		policy:  policy,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Attempt returns how long the longest waiting of keys still has to wait, or
+// records a failure for each of them and returns 0 when none has to.
+func (m *Memory) Attempt(keys ...string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var wait time.Duration
+	for _, key := range keys {
+		if w := m.wait(key, now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, key := range keys {
+		m.fail(key, now)
+	}
+	return 0
+}
+
+// Release forgets the failure Attempt recorded for each of keys.
+func (m *Memory) Release(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		e, ok := m.entries[key]
+		if !ok {
+			continue
+		}
+		e.failures--
+		if e.failures <= 0 {
+			delete(m.entries, key)
+			continue
+		}
+		m.block(e)
+	}
+}
+
+func (m *Memory) Wait(key string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.wait(key, m.now())
+}
+
+func (m *Memory) Fail(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fail(key, m.now())
+}
+
+func (m *Memory) Reset(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+}
+
+func (m *Memory) wait(key string, now time.Time) time.Duration {
+	e, ok := m.entries[key]
+	if !ok {
+		return 0
+	}
+	if m.expired(e, now) {
+		delete(m.entries, key)
+		return 0
+	}
+	if wait := e.blockedUntil.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (m *Memory) fail(key string, now time.Time) {
+	e, ok := m.entries[key]
+	if !ok || m.expired(e, now) {
+		if len(m.entries) >= sweepSize {
+			m.sweep(now)
+		}
+		e = new(entry)
+		m.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+	m.block(e)
+}
+
+// block sets how long e is blocked after its last failure.
+func (m *Memory) block(e *entry) {
+	switch {
+	case m.policy.LockoutAttempts > 0 && e.failures >= m.policy.LockoutAttempts:
+		e.blockedUntil = e.lastFailure.Add(m.policy.Lockout)
+	case e.failures > m.policy.FreeAttempts:
+		e.blockedUntil = e.lastFailure.Add(m.delay(e.failures - m.policy.FreeAttempts))
+	default:
+		e.blockedUntil = time.Time{}
+	}
+}
+
+func (m *Memory) delay(n int) time.Duration {
+	delay := m.policy.BaseDelay
+	for i := 1; i < n && delay < m.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if m.policy.MaxDelay > 0 && delay > m.policy.MaxDelay {
+		delay = m.policy.MaxDelay
+	}
+	return delay
+}
+
+func (m *Memory) expired(e *entry, now time.Time) bool {
+	return now.Sub(e.lastFailure) > m.policy.Window && !now.Before(e.blockedUntil)
+}
+
+func (m *Memory) sweep(now time.Time) {
+	for key, e := range m.entries {
+		if m.expired(e, now) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+	LockoutAttempts: 6,
+	Lockout:         time.Minute,
+	Window:          time.Hour,
+}
+
+func TestBackoff(T *testing.T) {
+	testCases := []struct {
+		failures int
+		wait     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, time.Minute},
+	}
+	for _, tc := range testCases {
+		m := NewMemory(testPolicy)
+		now := time.Unix(1000, 0)
+		m.now = func() time.Time { return now }
+		for i := 0; i < tc.failures; i++ {
+			m.Fail("guts")
+		}
+		if got := m.Wait("guts"); got != tc.wait {
+			T.Errorf("after %d failures Wait()=%v, expected %v", tc.failures, got, tc.wait)
+		}
+		if got := m.Wait("casca"); got != 0 {
+			T.Errorf("unrelated key has to wait %v", got)
+		}
+	}
+}
+
+func TestDelayCap(T *testing.T) {
+	m := NewMemory(Policy{FreeAttempts: 0, BaseDelay: time.Second, MaxDelay: 3 * time.Second, Window: time.Hour})
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	for i := 0; i < 50; i++ {
+		m.Fail("guts")
+	}
+	if got := m.Wait("guts"); got != 3*time.Second {
+		T.Errorf("Wait()=%v, expected the 3s cap", got)
+	}
+}
+
+func TestExpiryAndReset(T *testing.T) {
+	m := NewMemory(testPolicy)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	for i := 0; i < testPolicy.LockoutAttempts; i++ {
+		m.Fail("guts")
+	}
+
+	now = now.Add(30 * time.Second)
+	if got := m.Wait("guts"); got != 30*time.Second {
+		T.Errorf("Wait()=%v during the lockout, expected 30s", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := m.Wait("guts"); got != 0 {
+		T.Errorf("Wait()=%v after the lockout, expected 0", got)
+	}
+	m.Fail("guts")
+	if got := m.Wait("guts"); got != time.Minute {
+		T.Errorf("failures inside the window should count: Wait()=%v", got)
+	}
+
+	now = now.Add(2 * time.Hour)
+	m.Fail("guts")
+	if got := m.Wait("guts"); got != 0 {
+		T.Errorf("failures outside the window should be forgotten: Wait()=%v", got)
+	}
+
+	for i := 0; i < testPolicy.LockoutAttempts; i++ {
+		m.Fail("guts")
+	}
+	m.Reset("guts")
+	if got := m.Wait("guts"); got != 0 {
+		T.Errorf("Wait()=%v after Reset, expected 0", got)
+	}
+}
+
+func TestAttemptAndRelease(T *testing.T) {
+	m := NewMemory(testPolicy)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+		if got := m.Attempt("guts", "casca"); got != 0 {
+			T.Fatalf("attempt %d has to wait %v", i+1, got)
+		}
+	}
+	if got := m.Attempt("guts"); got != time.Second {
+		T.Errorf("Attempt()=%v once the free attempts are reserved, expected 1s", got)
+	}
+	if got := m.Attempt("griffith", "casca"); got != time.Second {
+		T.Errorf("Attempt()=%v should wait for the longest of the keys, expected 1s", got)
+	}
+	if got := m.Wait("griffith"); got != 0 {
+		T.Errorf("a refused attempt was recorded: Wait()=%v", got)
+	}
+
+	m.Release("guts")
+	if got := m.Wait("guts"); got != 0 {
+		T.Errorf("Wait()=%v after Release, expected 0", got)
+	}
+	if got := m.Wait("casca"); got != time.Second {
+		T.Errorf("Release of another key changed Wait()=%v, expected 1s", got)
+	}
+	m.Release("guts", "guts", "guts")
+	if _, ok := m.entries["guts"]; ok {
+		T.Errorf("a key without failures should be forgotten")
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"time"
 
+	"github.com/ennemli/todo/todo/configs"
 	"github.com/ennemli/todo/todo/internal/db"
 	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
 	"github.com/ennemli/todo/todo/internal/models/todo"
 	"github.com/ennemli/todo/todo/internal/routing"
 	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/internal/users"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 )
@@ -20,7 +26,15 @@ func main() {
 	r.Use(middleware.Recoverer)
 	r.Use(middlewares.SetTimeOut(time.Second * 2))
 	r.Use(render.SetContentType(render.ContentTypeJSON))
-	db.GetDB().AutoMigrate(&todo.Todo{})
-	routing.InitRouting(todo.NewStore())
+	if err := db.GetDB().AutoMigrate(&item.Item{}, &tag.Tag{}, &tag.TodoTag{}, &list.List{}, &list.Member{}, &list.Invitation{}); err != nil {
+		panic(err)
+	}
+	if err := todo.Migrate(db.GetDB()); err != nil {
+		panic(err)
+	}
+	config := configs.GetConfig().Service
+	store := todo.NewStore()
+	routing.InitRouting(store, item.NewStore(), tag.NewStore(), list.NewStore(), users.NewClient(config.USERS_ENDPOINT))
+	go todo.Purge(context.Background(), store, config.TRASH_RETENTION, config.PURGE_INTERVAL)
 	s.ListenAndServe()
 }
@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -18,9 +20,14 @@ type dbConfig struct {
 }
 
 type serviceConfig struct {
-	APP_PORT     int
-	APP_DEBUG    bool
-	API_ENDPOINT string
+	APP_PORT        int
+	APP_DEBUG       bool
+	API_ENDPOINT    string
+	MAX_PAGE_SIZE   int
+	USERS_ENDPOINT  string
+	TRASH_RETENTION time.Duration
+	PURGE_INTERVAL  time.Duration
+	MAX_IMPORT_SIZE uint
 }
 
 func Initialize(filename string, filepath string, filetype string) {
@@ -42,9 +49,14 @@ func GetConfig() *Config {
 		DB_USER:     viper.GetString("DB_USER"),
 	}
 	APP := serviceConfig{
-		APP_DEBUG:    viper.GetBool("APP_DEBUG"),
-		APP_PORT:     viper.GetInt("APP_PORT"),
-		API_ENDPOINT: viper.GetString("API_ENDPOINT"),
+		APP_DEBUG:       viper.GetBool("APP_DEBUG"),
+		APP_PORT:        viper.GetInt("APP_PORT"),
+		API_ENDPOINT:    viper.GetString("API_ENDPOINT"),
+		MAX_PAGE_SIZE:   viper.GetInt("MAX_PAGE_SIZE"),
+		USERS_ENDPOINT:  viper.GetString("USERS_ENDPOINT"),
+		TRASH_RETENTION: viper.GetDuration("TRASH_RETENTION"),
+		PURGE_INTERVAL:  viper.GetDuration("PURGE_INTERVAL"),
+		MAX_IMPORT_SIZE: viper.GetSizeInBytes("MAX_IMPORT_SIZE"),
 	}
 
 	return &Config{
@@ -10,6 +10,10 @@ var ResponseRequestTimeout ErrorResponse = ErrorResponse{
 	Message: "Request timeout",
 }
 
+var ResponseUnauthorized ErrorResponse = ErrorResponse{
+	Message: "Unauthorized",
+}
+
 type ErrorResponse struct {
 	Message string `json:"message"`
 }
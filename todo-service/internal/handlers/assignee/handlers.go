@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/users"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+type Handlers interface {
+	Assign(w http.ResponseWriter, r *http.Request)
+	Unassign(w http.ResponseWriter, r *http.Request)
+}
+
+type assigneeHandlers struct {
+	todos todo.Store
+	users users.Client
+}
+
+type Assignment struct {
+	AssigneeID uint `json:"assignee_id"`
+}
+
+func NewAssigneeHandlers(todos todo.Store, users users.Client) Handlers {
+	return &assigneeHandlers{todos: todos, users: users}
+}
+
+// Assign assigns the todo to the user in the body, who has to exist in the
+// user service.
+func (h *assigneeHandlers) Assign(w http.ResponseWriter, r *http.Request) {
+	userID, todoItem, ok := h.visibleTodo(w, r)
+	if !ok {
+		return
+	}
+	if !canAssign(w, r, todoItem) {
+		return
+	}
+	assignment := new(Assignment)
+	if err := json.NewDecoder(r.Body).Decode(assignment); err != nil || assignment.AssigneeID == 0 {
+		renderError(w, r, http.StatusBadRequest, "assignee_id must be a user ID")
+		return
+	}
+	_, err := h.users.GetUser(r.Context(), assignment.AssigneeID)
+	if err == users.ErrUserNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", assignment.AssigneeID))
+		return
+	}
+	if err == users.ErrUnavailable {
+		renderError(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.update(w, r, userID, todoItem, assignment.AssigneeID)
+}
+
+// Unassign can also be done by the assignee, to hand the todo back.
+func (h *assigneeHandlers) Unassign(w http.ResponseWriter, r *http.Request) {
+	userID, todoItem, ok := h.visibleTodo(w, r)
+	if !ok {
+		return
+	}
+	if todoItem.AssigneeID == nil || *todoItem.AssigneeID != userID {
+		if !canAssign(w, r, todoItem) {
+			return
+		}
+	}
+	h.update(w, r, userID, todoItem, nil)
+}
+
+// update stores assigneeID, a uint or nil, on todoItem.
+func (h *assigneeHandlers) update(w http.ResponseWriter, r *http.Request, userID uint, todoItem *todo.Todo, assigneeID interface{}) {
+	todoItem, err := h.todos.UpdateTodo(r.Context(), userID, todoItem, map[string]interface{}{"assignee_id": assigneeID})
+	switch err {
+	case nil:
+	case gorm.ErrRecordNotFound:
+		renderError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	case todo.ErrReadOnly, todo.ErrAssignee:
+		renderError(w, r, http.StatusForbidden, err.Error())
+		return
+	default:
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, todoItem)
+}
+
+func (h *assigneeHandlers) visibleTodo(w http.ResponseWriter, r *http.Request) (uint, *todo.Todo, bool) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return 0, nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return 0, nil, false
+	}
+	todoItem, err := h.todos.GetTodoById(r.Context(), userID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
+		return 0, nil, false
+	}
+	return userID, todoItem, true
+}
+
+// canAssign renders why the caller cannot assign todoItem, if they cannot.
+func canAssign(w http.ResponseWriter, r *http.Request, todoItem *todo.Todo) bool {
+	if todoItem.Role == todo.RoleAssignee {
+		renderError(w, r, http.StatusForbidden, todo.ErrAssignee.Error())
+		return false
+	}
+	if !todoItem.CanEdit() {
+		renderError(w, r, http.StatusForbidden, todo.ErrReadOnly.Error())
+		return false
+	}
+	return true
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, errors.ErrorResponse{Message: message})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/ennemli/todo/todo/pkg/maputil"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+const maxItems = 100
+
+type Handlers interface {
+	GetItems(w http.ResponseWriter, r *http.Request)
+	CreateItem(w http.ResponseWriter, r *http.Request)
+	UpdateItem(w http.ResponseWriter, r *http.Request)
+	ToggleItem(w http.ResponseWriter, r *http.Request)
+	DeleteItemById(w http.ResponseWriter, r *http.Request)
+	ReorderItems(w http.ResponseWriter, r *http.Request)
+}
+
+type itemHandlers struct {
+	todos todo.Store
+	items item.Store
+}
+
+func NewItemHandlers(todos todo.Store, items item.Store) Handlers {
+	return &itemHandlers{todos: todos, items: items}
+}
+
+type Order struct {
+	IDs []uint `json:"ids"`
+}
+
+func (h *itemHandlers) GetItems(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := h.ownedTodo(w, r, false)
+	if !ok {
+		return
+	}
+	items, err := h.items.GetItems(r.Context(), todoID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, items)
+}
+
+func (h *itemHandlers) CreateItem(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := h.ownedTodo(w, r, true)
+	if !ok {
+		return
+	}
+	itemEntry := new(item.Item)
+	if err := json.NewDecoder(r.Body).Decode(itemEntry); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	itemEntry.Name = strings.TrimSpace(itemEntry.Name)
+	if itemEntry.Name == "" {
+		renderError(w, r, http.StatusBadRequest, "Item name is required")
+		return
+	}
+	items, err := h.items.GetItems(r.Context(), todoID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(items) >= maxItems {
+		renderError(w, r, http.StatusConflict, fmt.Sprintf("A todo can have at most %d checklist items", maxItems))
+		return
+	}
+	itemEntry.ID = 0
+	itemEntry.TodoID = todoID
+	itemEntry, err = h.items.CreateItem(r.Context(), itemEntry)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, itemEntry)
+}
+
+func (h *itemHandlers) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	itemEntry, ok := h.ownedItem(w, r)
+	if !ok {
+		return
+	}
+	updatedFields := make(map[string]interface{})
+	if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !maputil.AnyKeys(updatedFields, "name", "done") {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if value, ok := updatedFields["name"]; ok {
+		name, ok := value.(string)
+		if !ok || strings.TrimSpace(name) == "" {
+			renderError(w, r, http.StatusBadRequest, "Item name is required")
+			return
+		}
+		updatedFields["name"] = strings.TrimSpace(name)
+	}
+	if value, ok := updatedFields["done"]; ok {
+		if _, ok := value.(bool); !ok {
+			renderError(w, r, http.StatusBadRequest, "done must be true or false")
+			return
+		}
+	}
+	h.update(w, r, itemEntry, updatedFields)
+}
+
+func (h *itemHandlers) ToggleItem(w http.ResponseWriter, r *http.Request) {
+	itemEntry, ok := h.ownedItem(w, r)
+	if !ok {
+		return
+	}
+	h.update(w, r, itemEntry, map[string]interface{}{"done": !itemEntry.Done})
+}
+
+func (h *itemHandlers) DeleteItemById(w http.ResponseWriter, r *http.Request) {
+	itemEntry, ok := h.ownedItem(w, r)
+	if !ok {
+		return
+	}
+	itemEntry, err := h.items.DeleteItemById(r.Context(), itemEntry.TodoID, itemEntry.ID)
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, "Item not found")
+		return
+	}
+	render.JSON(w, r, itemEntry)
+}
+
+func (h *itemHandlers) ReorderItems(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := h.ownedTodo(w, r, true)
+	if !ok {
+		return
+	}
+	order := new(Order)
+	if err := json.NewDecoder(r.Body).Decode(order); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	items, err := h.items.ReorderItems(r.Context(), todoID, order.IDs)
+	if err == item.ErrOrderMismatch {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, items)
+}
+
+func (h *itemHandlers) update(w http.ResponseWriter, r *http.Request, itemEntry *item.Item, fields map[string]interface{}) {
+	itemEntry, err := h.items.UpdateItem(r.Context(), itemEntry, fields)
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, "Item not found")
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, itemEntry)
+}
+
+// ownedTodo returns the id of the todo in the URL after checking that the
+// caller can see it and, with edit, change it. Otherwise it renders the
+// error and returns false.
+func (h *itemHandlers) ownedTodo(w http.ResponseWriter, r *http.Request, edit bool) (uint, bool) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return 0, false
+	}
+	todoItem, err := h.todos.GetTodoById(r.Context(), userID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
+		return 0, false
+	}
+	if edit && !todoItem.CanEdit() {
+		renderError(w, r, http.StatusForbidden, todo.ErrReadOnly.Error())
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *itemHandlers) ownedItem(w http.ResponseWriter, r *http.Request) (*item.Item, bool) {
+	todoID, ok := h.ownedTodo(w, r, true)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "itemId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid item ID")
+		return nil, false
+	}
+	itemEntry, err := h.items.GetItemById(r.Context(), todoID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Item with ID %d not found", id))
+		return nil, false
+	}
+	return itemEntry, true
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, errors.ErrorResponse{Message: message})
+}
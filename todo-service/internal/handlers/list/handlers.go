@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/users"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/ennemli/todo/todo/pkg/maputil"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+const maxListNameLength = 100
+
+type Handlers interface {
+	GetLists(w http.ResponseWriter, r *http.Request)
+	GetListById(w http.ResponseWriter, r *http.Request)
+	CreateList(w http.ResponseWriter, r *http.Request)
+	UpdateList(w http.ResponseWriter, r *http.Request)
+	DeleteListById(w http.ResponseWriter, r *http.Request)
+	GetMembers(w http.ResponseWriter, r *http.Request)
+	SetRole(w http.ResponseWriter, r *http.Request)
+	RemoveMember(w http.ResponseWriter, r *http.Request)
+	GetInvitations(w http.ResponseWriter, r *http.Request)
+	CreateInvitation(w http.ResponseWriter, r *http.Request)
+	DeleteInvitationById(w http.ResponseWriter, r *http.Request)
+	GetMyInvitations(w http.ResponseWriter, r *http.Request)
+	AcceptInvitation(w http.ResponseWriter, r *http.Request)
+	DeclineInvitation(w http.ResponseWriter, r *http.Request)
+}
+
+type listHandlers struct {
+	lists list.Store
+	users users.Client
+}
+
+func NewListHandlers(lists list.Store, users users.Client) Handlers {
+	return &listHandlers{lists: lists, users: users}
+}
+
+func (h *listHandlers) GetLists(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	lists, err := h.lists.GetLists(r.Context(), userID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, lists)
+}
+
+func (h *listHandlers) GetListById(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, false)
+	if !ok {
+		return
+	}
+	render.JSON(w, r, listItem)
+}
+
+func (h *listHandlers) CreateList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	listItem := new(list.List)
+	if err := json.NewDecoder(r.Body).Decode(listItem); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	name, message := validateName(listItem.Name)
+	if message != "" {
+		renderError(w, r, http.StatusBadRequest, message)
+		return
+	}
+	listItem, err := h.lists.CreateList(r.Context(), &list.List{Name: name, CreatedBy: userID})
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, listItem)
+}
+
+func (h *listHandlers) UpdateList(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, true)
+	if !ok {
+		return
+	}
+	updatedFields := make(map[string]interface{})
+	if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !maputil.AnyKeys(updatedFields, "name") {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if value, ok := updatedFields["name"]; ok {
+		raw, _ := value.(string)
+		name, message := validateName(raw)
+		if message != "" {
+			renderError(w, r, http.StatusBadRequest, message)
+			return
+		}
+		updatedFields["name"] = name
+	}
+	listItem, err := h.lists.UpdateList(r.Context(), listItem, updatedFields)
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, "List not found")
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, listItem)
+}
+
+func (h *listHandlers) DeleteListById(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, true)
+	if !ok {
+		return
+	}
+	listItem, err := h.lists.DeleteListById(r.Context(), listItem.ID)
+	if err == list.ErrListNotEmpty {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, "List not found")
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, listItem)
+}
+
+func (h *listHandlers) GetMembers(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, false)
+	if !ok {
+		return
+	}
+	members, err := h.lists.GetMembers(r.Context(), listItem.ID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, members)
+}
+
+func (h *listHandlers) SetRole(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, true)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	body := new(list.Member)
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !list.ValidRole(body.Role) {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid role %q", body.Role))
+		return
+	}
+	member, err := h.lists.SetRole(r.Context(), listItem.ID, uint(memberID), body.Role)
+	if err == list.ErrLastOwner {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d is not a member of this list", memberID))
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, member)
+}
+
+// RemoveMember lets owners remove anyone from the list and other members
+// leave it.
+func (h *listHandlers) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, false)
+	if !ok {
+		return
+	}
+	userID, _ := identity.UserID(r.Context())
+	memberID, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if uint(memberID) != userID && listItem.Role != list.RoleOwner {
+		renderError(w, r, http.StatusForbidden, "Only owners can manage a list")
+		return
+	}
+	err = h.lists.RemoveMember(r.Context(), listItem.ID, uint(memberID))
+	if err == list.ErrLastOwner {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d is not a member of this list", memberID))
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *listHandlers) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, true)
+	if !ok {
+		return
+	}
+	invitations, err := h.lists.GetInvitations(r.Context(), listItem.ID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, invitations)
+}
+
+func (h *listHandlers) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, true)
+	if !ok {
+		return
+	}
+	userID, _ := identity.UserID(r.Context())
+	invitation := new(list.Invitation)
+	if err := json.NewDecoder(r.Body).Decode(invitation); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if invitation.UserID == 0 {
+		renderError(w, r, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if invitation.Role == "" {
+		invitation.Role = list.RoleViewer
+	}
+	if !list.ValidRole(invitation.Role) {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid role %q", invitation.Role))
+		return
+	}
+	_, err := h.users.GetUser(r.Context(), invitation.UserID)
+	if err == users.ErrUserNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", invitation.UserID))
+		return
+	}
+	if err == users.ErrUnavailable {
+		renderError(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	invitation = &list.Invitation{ListID: listItem.ID, UserID: invitation.UserID, Role: invitation.Role, InvitedBy: userID}
+	invitation, err = h.lists.CreateInvitation(r.Context(), invitation)
+	if err == list.ErrAlreadyMember || err == list.ErrAlreadyInvited {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, invitation)
+}
+
+func (h *listHandlers) DeleteInvitationById(w http.ResponseWriter, r *http.Request) {
+	listItem, ok := h.memberOf(w, r, true)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "invitationId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+	invitation, err := h.lists.GetInvitationById(r.Context(), uint(id))
+	if err == nil && invitation.ListID == listItem.ID {
+		err = h.lists.DeleteInvitationById(r.Context(), uint(id))
+	} else if err == nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Invitation with ID %d not found", id))
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *listHandlers) GetMyInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	invitations, err := h.lists.GetInvitationsFor(r.Context(), userID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, invitations)
+}
+
+func (h *listHandlers) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := h.invitationFor(w, r)
+	if !ok {
+		return
+	}
+	member, err := h.lists.AcceptInvitation(r.Context(), invitation)
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Invitation with ID %d not found", invitation.ID))
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, member)
+}
+
+func (h *listHandlers) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := h.invitationFor(w, r)
+	if !ok {
+		return
+	}
+	err := h.lists.DeleteInvitationById(r.Context(), invitation.ID)
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Invitation with ID %d not found", invitation.ID))
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memberOf returns the list in the URL after checking that the caller is a
+// member of it and, with owner, one of its owners. Otherwise it renders the
+// error and returns false.
+func (h *listHandlers) memberOf(w http.ResponseWriter, r *http.Request, owner bool) (*list.List, bool) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "listId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid list ID")
+		return nil, false
+	}
+	listItem, err := h.lists.GetListById(r.Context(), userID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("List with ID %d not found", id))
+		return nil, false
+	}
+	if owner && listItem.Role != list.RoleOwner {
+		renderError(w, r, http.StatusForbidden, "Only owners can manage a list")
+		return nil, false
+	}
+	return listItem, true
+}
+
+// invitationFor returns the invitation in the URL when it was sent to the
+// caller.
+func (h *listHandlers) invitationFor(w http.ResponseWriter, r *http.Request) (*list.Invitation, bool) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "invitationId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid invitation ID")
+		return nil, false
+	}
+	invitation, err := h.lists.GetInvitationById(r.Context(), uint(id))
+	if err != nil || invitation.UserID != userID {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Invitation with ID %d not found", id))
+		return nil, false
+	}
+	return invitation, true
+}
+
+func validateName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "List name is required"
+	}
+	if utf8.RuneCountInString(name) > maxListNameLength {
+		return "", fmt.Sprintf("List name must be at most %d characters", maxListNameLength)
+	}
+	return name, ""
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, errors.ErrorResponse{Message: message})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/ennemli/todo/todo/pkg/maputil"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+const maxTagNameLength = 32
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Handlers interface {
+	GetTags(w http.ResponseWriter, r *http.Request)
+	GetTagById(w http.ResponseWriter, r *http.Request)
+	CreateTag(w http.ResponseWriter, r *http.Request)
+	UpdateTag(w http.ResponseWriter, r *http.Request)
+	DeleteTagById(w http.ResponseWriter, r *http.Request)
+	AttachTag(w http.ResponseWriter, r *http.Request)
+	DetachTag(w http.ResponseWriter, r *http.Request)
+}
+
+type tagHandlers struct {
+	todos todo.Store
+	tags  tag.Store
+}
+
+func NewTagHandlers(todos todo.Store, tags tag.Store) Handlers {
+	return &tagHandlers{todos: todos, tags: tags}
+}
+
+func (h *tagHandlers) GetTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	tags, err := h.tags.GetTags(r.Context(), userID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, tags)
+}
+
+func (h *tagHandlers) GetTagById(w http.ResponseWriter, r *http.Request) {
+	tagItem, ok := h.ownedTag(w, r)
+	if !ok {
+		return
+	}
+	render.JSON(w, r, tagItem)
+}
+
+func (h *tagHandlers) CreateTag(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	tagItem := new(tag.Tag)
+	if err := json.NewDecoder(r.Body).Decode(tagItem); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	name, message := validateName(tagItem.Name)
+	if message == "" {
+		message = validateColor(tagItem.Color)
+	}
+	if message != "" {
+		renderError(w, r, http.StatusBadRequest, message)
+		return
+	}
+	tagItem = &tag.Tag{UserID: userID, Name: name, Color: tagItem.Color}
+	tagItem, err := h.tags.CreateTag(r.Context(), tagItem)
+	if err == tag.ErrDuplicateTag {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, tagItem)
+}
+
+func (h *tagHandlers) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	tagItem, ok := h.ownedTag(w, r)
+	if !ok {
+		return
+	}
+	updatedFields := make(map[string]interface{})
+	if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !maputil.AnyKeys(updatedFields, "name", "color") {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if value, ok := updatedFields["name"]; ok {
+		raw, _ := value.(string)
+		name, message := validateName(raw)
+		if message != "" {
+			renderError(w, r, http.StatusBadRequest, message)
+			return
+		}
+		updatedFields["name"] = name
+	}
+	if value, ok := updatedFields["color"]; ok {
+		color, isString := value.(string)
+		if message := validateColor(color); !isString || message != "" {
+			renderError(w, r, http.StatusBadRequest, "color must be a #rrggbb hex color")
+			return
+		}
+	}
+	tagItem, err := h.tags.UpdateTag(r.Context(), tagItem, updatedFields)
+	if err == tag.ErrDuplicateTag {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, "Tag not found")
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, tagItem)
+}
+
+func (h *tagHandlers) DeleteTagById(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "tagId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+	tagItem, err := h.tags.DeleteTagById(r.Context(), userID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Tag with ID %d not found", id))
+		return
+	}
+	render.JSON(w, r, tagItem)
+}
+
+func (h *tagHandlers) AttachTag(w http.ResponseWriter, r *http.Request) {
+	h.setTag(w, r, h.tags.AttachTag)
+}
+
+func (h *tagHandlers) DetachTag(w http.ResponseWriter, r *http.Request) {
+	h.setTag(w, r, h.tags.DetachTag)
+}
+
+// setTag checks that the caller owns both the todo and the tag in the URL
+// before calling change with their ids.
+func (h *tagHandlers) setTag(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, todoID uint, tagID uint) error) {
+	tagItem, ok := h.ownedTag(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todoItem, err := h.todos.GetTodoById(r.Context(), tagItem.UserID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
+		return
+	}
+	if !todoItem.CanEdit() {
+		renderError(w, r, http.StatusForbidden, todo.ErrReadOnly.Error())
+		return
+	}
+	if err := change(r.Context(), uint(id), tagItem.ID); err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *tagHandlers) ownedTag(w http.ResponseWriter, r *http.Request) (*tag.Tag, bool) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "tagId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid tag ID")
+		return nil, false
+	}
+	tagItem, err := h.tags.GetTagById(r.Context(), userID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Tag with ID %d not found", id))
+		return nil, false
+	}
+	return tagItem, true
+}
+
+func validateName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "Tag name is required"
+	}
+	if utf8.RuneCountInString(name) > maxTagNameLength {
+		return "", fmt.Sprintf("Tag name must be at most %d characters", maxTagNameLength)
+	}
+	if strings.Contains(name, ",") {
+		return "", "Tag name cannot contain commas"
+	}
+	return name, ""
+}
+
+func validateColor(color string) string {
+	if color != "" && !colorPattern.MatchString(color) {
+		return "color must be a #rrggbb hex color"
+	}
+	return ""
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, errors.ErrorResponse{Message: message})
+}
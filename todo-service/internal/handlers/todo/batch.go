@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/go-chi/render"
+)
+
+// MaxBatchSize is the most operations a batch can hold.
+const MaxBatchSize = 100
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+const (
+	ModeAtomic  = "atomic"
+	ModePartial = "partial"
+)
+
+// Operation is one step of a batch. Body is the todo to create or the
+// fields to update; IfMatch and Cascade work like the If-Match header and
+// the cascade parameter of PUT and DELETE /{id}.
+type Operation struct {
+	Op      string          `json:"op"`
+	ID      uint            `json:"id,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	IfMatch string          `json:"if_match,omitempty"`
+	Cascade bool            `json:"cascade,omitempty"`
+}
+
+// Batch is a list of operations run in one transaction. In atomic mode, the
+// default, one failing operation fails them all; in partial mode each
+// operation succeeds or fails on its own.
+type Batch struct {
+	Mode       string       `json:"mode"`
+	Operations []*Operation `json:"operations"`
+}
+
+// Result is the outcome of an operation: the status its own request would
+// have been answered with, and the todo or why it failed.
+type Result struct {
+	Status int        `json:"status"`
+	ETag   string     `json:"etag,omitempty"`
+	Todo   *todo.Todo `json:"todo,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+	Results []*Result `json:"results"`
+}
+
+// Batch answers with the result of every operation, in order. When an
+// atomic batch fails, it answers with the status of the failed operation
+// and the others get 424 Failed Dependency.
+func (h *todoHandlers) Batch(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	batch := new(Batch)
+	if err := json.NewDecoder(r.Body).Decode(batch); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if batch.Mode == "" {
+		batch.Mode = ModeAtomic
+	}
+	if batch.Mode != ModeAtomic && batch.Mode != ModePartial {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid mode %q", batch.Mode))
+		return
+	}
+	if len(batch.Operations) == 0 || len(batch.Operations) > MaxBatchSize {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("A batch holds between 1 and %d operations", MaxBatchSize))
+		return
+	}
+
+	todos := make([]*todo.Todo, len(batch.Operations))
+	failures := make([]*requestError, len(batch.Operations))
+	err := h.store.Transaction(r.Context(), func(tx todo.Store) error {
+		for i, op := range batch.Operations {
+			if batch.Mode == ModeAtomic {
+				if todos[i], failures[i] = h.run(r.Context(), tx, userID, op); failures[i] != nil {
+					return failures[i]
+				}
+				continue
+			}
+			err := tx.Transaction(r.Context(), func(tx todo.Store) error {
+				if todos[i], failures[i] = h.run(r.Context(), tx, userID, op); failures[i] != nil {
+					return failures[i]
+				}
+				return nil
+			})
+			if err != nil && failures[i] == nil {
+				return err
+			}
+		}
+		return tx.Decorate(r.Context(), userID, decorated(batch.Operations, todos, failures)...)
+	})
+	if _, failed := err.(*requestError); err != nil && !failed {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := &BatchResult{Results: make([]*Result, len(batch.Operations))}
+	for i, failure := range failures {
+		switch {
+		case failure != nil:
+			result.Results[i] = &Result{Status: failure.status, Error: failure.message}
+		case err != nil:
+			result.Results[i] = &Result{Status: http.StatusFailedDependency, Error: "Another operation of the batch failed"}
+		}
+	}
+	if err != nil {
+		render.Status(r, err.(*requestError).status)
+		render.JSON(w, r, result)
+		return
+	}
+	finish(batch.Operations, todos, result.Results)
+	render.JSON(w, r, result)
+}
+
+// run runs op in store for userID.
+func (h *todoHandlers) run(ctx context.Context, store todo.Store, userID uint, op *Operation) (*todo.Todo, *requestError) {
+	switch op.Op {
+	case OpCreate:
+		todoItem := new(todo.Todo)
+		if err := json.Unmarshal(op.Body, todoItem); err != nil {
+			return nil, &requestError{http.StatusBadRequest, "Invalid request payload"}
+		}
+		return createTodo(ctx, store, userID, todoItem)
+	case OpUpdate:
+		return h.updateTodo(ctx, store, userID, op.ID, op.IfMatch, func() (map[string]interface{}, error) {
+			fields := make(map[string]interface{})
+			return fields, json.Unmarshal(op.Body, &fields)
+		})
+	case OpDelete:
+		return h.deleteTodo(ctx, store, userID, op.ID, op.Cascade, op.IfMatch)
+	}
+	return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid op %q", op.Op)}
+}
+
+// decorated returns the todos created or updated by the operations that did
+// not fail, whose results show their checklist progress and tags.
+func decorated(ops []*Operation, todos []*todo.Todo, failures []*requestError) []*todo.Todo {
+	var decorated []*todo.Todo
+	for i, op := range ops {
+		if failures[i] == nil && op.Op != OpDelete {
+			decorated = append(decorated, todos[i])
+		}
+	}
+	return decorated
+}
+
+// finish fills the results of the operations that did not fail once their
+// transaction is committed, with the ETags of the updated todos.
+func finish(ops []*Operation, todos []*todo.Todo, results []*Result) {
+	for i, op := range ops {
+		if results[i] != nil {
+			continue
+		}
+		results[i] = &Result{Status: http.StatusOK, Todo: todos[i]}
+		if op.Op == OpUpdate {
+			results[i].ETag = tagOf(todos[i])
+		}
+	}
+}
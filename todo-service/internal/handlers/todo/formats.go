@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/ical"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatICS  = "ics"
+)
+
+// formats maps the formats todos are exported and imported in to their
+// media types.
+var formats = map[string]string{
+	FormatJSON: "application/json",
+	FormatCSV:  "text/csv",
+	FormatICS:  "text/calendar",
+}
+
+// csvColumns are the columns of an exported CSV file. An imported one names
+// its columns in a header row, and only needs name; id and completed_at are
+// ignored, like unknown columns.
+var csvColumns = []string{"id", "external_id", "name", "description", "date", "status", "priority", "due_at", "completed_at", "recurrence", "list_id"}
+
+// icsStatuses maps statuses to the STATUS of a VTODO.
+var icsStatuses = map[string]string{
+	todo.StatusOpen:       "NEEDS-ACTION",
+	todo.StatusInProgress: "IN-PROCESS",
+	todo.StatusDone:       "COMPLETED",
+	todo.StatusArchived:   "CANCELLED",
+}
+
+// icsPriorities maps priorities to the PRIORITY of a VTODO, from 1, the
+// highest, to 9.
+var icsPriorities = map[int]int{
+	todo.PriorityHigh:   1,
+	todo.PriorityMedium: 5,
+	todo.PriorityLow:    9,
+}
+
+// formatOf returns the format of a Content-Type, JSON unless it is the media
+// type of another one.
+func formatOf(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for format, formatType := range formats {
+		if mediaType == formatType {
+			return format
+		}
+	}
+	return FormatJSON
+}
+
+// encoder writes todos in a format as they are read. close ends the file,
+// which may hold no todos.
+type encoder interface {
+	encode(todos []*todo.Todo) error
+	close() error
+}
+
+func newEncoder(format string, w io.Writer) encoder {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}
+	case FormatICS:
+		return &icsEncoder{w: ical.NewWriter(w), now: time.Now().UTC()}
+	}
+	return &jsonEncoder{w: w}
+}
+
+// jsonEncoder writes an array of todos as GET /{id} renders them.
+type jsonEncoder struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *jsonEncoder) encode(todos []*todo.Todo) error {
+	for _, t := range todos {
+		body, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		separator := ",\n"
+		if !e.started {
+			separator, e.started = "[", true
+		}
+		if _, err := io.WriteString(e.w, separator+string(body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jsonEncoder) close() error {
+	end := "]\n"
+	if !e.started {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// csvEncoder writes a header row then a row of csvColumns per todo, with
+// times in RFC 3339.
+type csvEncoder struct {
+	w       *csv.Writer
+	started bool
+}
+
+func (e *csvEncoder) encode(todos []*todo.Todo) error {
+	e.header()
+	for _, t := range todos {
+		e.w.Write([]string{
+			strconv.FormatUint(uint64(t.ID), 10),
+			valueOf(t.ExternalID),
+			t.Name,
+			t.Description,
+			formatTime(&t.Date),
+			t.Status,
+			strconv.Itoa(t.Priority),
+			formatTime(t.DueAt),
+			formatTime(t.CompletedAt),
+			t.Recurrence,
+			formatID(t.ListID),
+		})
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) close() error {
+	e.header()
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) header() {
+	if !e.started {
+		e.w.Write(csvColumns)
+		e.started = true
+	}
+}
+
+// icsEncoder writes a VCALENDAR with a VTODO per todo. Its UID is the
+// external ID of the todo, or one made from its ID.
+type icsEncoder struct {
+	w       *ical.Writer
+	now     time.Time
+	started bool
+}
+
+func (e *icsEncoder) encode(todos []*todo.Todo) error {
+	e.header()
+	for _, t := range todos {
+		e.w.Begin("VTODO")
+		uid := fmt.Sprintf("todo-%d", t.ID)
+		if t.ExternalID != nil {
+			uid = *t.ExternalID
+		}
+		e.w.Text("UID", uid)
+		e.w.Time("DTSTAMP", e.now)
+		e.w.Time("CREATED", t.CreatedAt)
+		e.w.Time("LAST-MODIFIED", t.UpdatedAt)
+		e.w.Text("SUMMARY", t.Name)
+		if t.Description != "" {
+			e.w.Text("DESCRIPTION", t.Description)
+		}
+		if !t.Date.IsZero() {
+			e.w.Time("DTSTART", t.Date)
+		}
+		if t.DueAt != nil {
+			e.w.Time("DUE", *t.DueAt)
+		}
+		if status, ok := icsStatuses[t.Status]; ok {
+			e.w.Raw("STATUS", status)
+		}
+		if priority, ok := icsPriorities[t.Priority]; ok {
+			e.w.Raw("PRIORITY", strconv.Itoa(priority))
+		}
+		if t.CompletedAt != nil {
+			e.w.Time("COMPLETED", *t.CompletedAt)
+		}
+		if t.Recurrence != "" {
+			e.w.Raw("RRULE", t.Recurrence)
+		}
+		e.w.End("VTODO")
+	}
+	return e.w.Flush()
+}
+
+func (e *icsEncoder) close() error {
+	e.header()
+	e.w.End("VCALENDAR")
+	return e.w.Flush()
+}
+
+func (e *icsEncoder) header() {
+	if !e.started {
+		e.w.Begin("VCALENDAR")
+		e.w.Raw("VERSION", "2.0")
+		e.w.Raw("PRODID", "-//ennemli//todo//EN")
+		e.started = true
+	}
+}
+
+// importRow is a todo read from an imported file, or the errors that kept
+// it from being read.
+type importRow struct {
+	todo   *todo.Todo
+	errors []string
+}
+
+// decodeTodos reads the todos of data, a file in format. It fails when data
+// is not such a file; a todo of it that cannot be read is a row with errors.
+func decodeTodos(format string, data []byte) ([]*importRow, error) {
+	switch format {
+	case FormatCSV:
+		return decodeCSV(data)
+	case FormatICS:
+		return decodeICS(data)
+	}
+	return decodeJSON(data)
+}
+
+// decodeJSON reads an array of todos. Only the fields a todo is created
+// with are kept.
+func decodeJSON(data []byte) ([]*importRow, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: expected an array of todos")
+	}
+	rows := make([]*importRow, len(raws))
+	for i, raw := range raws {
+		t := new(todo.Todo)
+		if err := json.Unmarshal(raw, t); err != nil {
+			rows[i] = &importRow{todo: new(todo.Todo), errors: []string{err.Error()}}
+			continue
+		}
+		rows[i] = &importRow{todo: &todo.Todo{
+			Name:        t.Name,
+			Description: t.Description,
+			Date:        t.Date,
+			Status:      t.Status,
+			Priority:    t.Priority,
+			DueAt:       t.DueAt,
+			Recurrence:  t.Recurrence,
+			ListID:      t.ListID,
+			ExternalID:  t.ExternalID,
+		}}
+	}
+	return rows, nil
+}
+
+// decodeCSV reads a CSV file with a header row, as csvEncoder writes them.
+// Times are in RFC 3339 or are dates such as 2024-03-01.
+func decodeCSV(data []byte) ([]*importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("Invalid CSV: missing header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("Invalid CSV: missing name column")
+	}
+	rows := make([]*importRow, len(records)-1)
+	for i, record := range records[1:] {
+		row := &importRow{todo: new(todo.Todo)}
+		get := func(column string) string {
+			if index, ok := columns[column]; ok && index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+		t := row.todo
+		t.Name = get("name")
+		t.Description = get("description")
+		t.Status = get("status")
+		t.Recurrence = get("recurrence")
+		if value := get("external_id"); value != "" {
+			t.ExternalID = &value
+		}
+		if value := get("date"); value != "" {
+			if date, err := parseTime(value); err != nil {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid date %q", value))
+			} else {
+				t.Date = date
+			}
+		}
+		if value := get("due_at"); value != "" {
+			if dueAt, err := parseTime(value); err != nil {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid due_at %q", value))
+			} else {
+				t.DueAt = &dueAt
+			}
+		}
+		if value := get("priority"); value != "" {
+			if t.Priority, err = strconv.Atoi(value); err != nil {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid priority %q", value))
+			}
+		}
+		if value := get("list_id"); value != "" {
+			if listID, err := strconv.ParseUint(value, 10, 0); err != nil {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid list_id %q", value))
+			} else {
+				id := uint(listID)
+				t.ListID = &id
+			}
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// decodeICS reads the VTODOs of a VCALENDAR: UID is the external ID,
+// SUMMARY the name, DESCRIPTION the description, DTSTART the date and DUE
+// the due date, along with STATUS, PRIORITY and RRULE.
+func decodeICS(data []byte) ([]*importRow, error) {
+	calendar, err := ical.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid iCalendar: %v", err)
+	}
+	if calendar.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("Invalid iCalendar: expected a VCALENDAR")
+	}
+	rows := []*importRow{}
+	for _, c := range calendar.Components {
+		if c.Name != "VTODO" {
+			continue
+		}
+		row := &importRow{todo: new(todo.Todo)}
+		t := row.todo
+		if p := c.Get("UID"); p != nil && p.Text() != "" {
+			uid := p.Text()
+			t.ExternalID = &uid
+		}
+		if p := c.Get("SUMMARY"); p != nil {
+			t.Name = p.Text()
+		}
+		if p := c.Get("DESCRIPTION"); p != nil {
+			t.Description = p.Text()
+		}
+		if p := c.Get("DTSTART"); p != nil {
+			if t.Date, err = p.Time(); err != nil {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid DTSTART %q", p.Value))
+			}
+		}
+		if p := c.Get("DUE"); p != nil {
+			if dueAt, err := p.Time(); err != nil {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid DUE %q", p.Value))
+			} else {
+				t.DueAt = &dueAt
+			}
+		}
+		if p := c.Get("STATUS"); p != nil {
+			for status, icsStatus := range icsStatuses {
+				if strings.EqualFold(p.Value, icsStatus) {
+					t.Status = status
+				}
+			}
+			if t.Status == "" {
+				row.errors = append(row.errors, fmt.Sprintf("Invalid STATUS %q", p.Value))
+			}
+		}
+		if p := c.Get("PRIORITY"); p != nil {
+			priority, err := strconv.Atoi(p.Value)
+			switch {
+			case err != nil || priority < 0 || priority > 9:
+				row.errors = append(row.errors, fmt.Sprintf("Invalid PRIORITY %q", p.Value))
+			case priority == 0:
+				t.Priority = todo.PriorityNone
+			case priority < 5:
+				t.Priority = todo.PriorityHigh
+			case priority == 5:
+				t.Priority = todo.PriorityMedium
+			default:
+				t.Priority = todo.PriorityLow
+			}
+		}
+		if p := c.Get("RRULE"); p != nil {
+			t.Recurrence = p.Value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+	}
+	return t, err
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+func valueOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
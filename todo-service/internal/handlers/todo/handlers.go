@@ -1,81 +1,272 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ennemli/todo/todo/configs"
 	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/tag"
 	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/etag"
+	"github.com/ennemli/todo/todo/pkg/identity"
 	"github.com/ennemli/todo/todo/pkg/maputil"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"gorm.io/gorm"
 )
 
 type Handlers interface {
 	GetTodos(w http.ResponseWriter, r *http.Request)
+	SearchTodos(w http.ResponseWriter, r *http.Request)
 	GetTodoById(w http.ResponseWriter, r *http.Request)
 	CreateTodo(w http.ResponseWriter, r *http.Request)
 	DeleteTodoById(w http.ResponseWriter, r *http.Request)
 	UpdateTodo(w http.ResponseWriter, r *http.Request)
+	GetOccurrences(w http.ResponseWriter, r *http.Request)
+	GetTrash(w http.ResponseWriter, r *http.Request)
+	RestoreTodoById(w http.ResponseWriter, r *http.Request)
+	PurgeTodoById(w http.ResponseWriter, r *http.Request)
+	Batch(w http.ResponseWriter, r *http.Request)
+	GetChanges(w http.ResponseWriter, r *http.Request)
+	Export(w http.ResponseWriter, r *http.Request)
+	Import(w http.ResponseWriter, r *http.Request)
+}
+
+// Occurrences previews the upcoming occurrences of a recurring todo.
+type Occurrences struct {
+	Recurrence  string      `json:"recurrence"`
+	Occurrences []time.Time `json:"occurrences"`
 }
 
 type todoHandlers struct {
 	store todo.Store
+	items item.Store
+	tags  tag.Store
 }
 
-func NewTodoHandlers(store todo.Store) Handlers {
-	return &todoHandlers{store: store}
+func NewTodoHandlers(store todo.Store, items item.Store, tags tag.Store) Handlers {
+	return &todoHandlers{store: store, items: items, tags: tags}
 }
 
 func (h *todoHandlers) GetTodos(w http.ResponseWriter, r *http.Request) {
-	todos, err := h.store.GetTodos(r.Context())
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	opts, err := todo.ParseListOptions(r.URL.Query(), configs.GetConfig().Service.MAX_PAGE_SIZE)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.store.GetTodos(r.Context(), userID, opts)
+	if err == nil {
+		err = h.decorate(r.Context(), userID, page.Items...)
+	}
 	if err != nil {
 		renderError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
-	render.JSON(w, r, todos)
+	renderTagged(w, r, page)
+}
+
+func (h *todoHandlers) SearchTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	opts, err := todo.ParseSearchOptions(r.URL.Query(), configs.GetConfig().Service.MAX_PAGE_SIZE)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.store.SearchTodos(r.Context(), userID, opts)
+	if err == nil {
+		todos := make([]*todo.Todo, len(page.Items))
+		for i, result := range page.Items {
+			todos[i] = &result.Todo
+		}
+		err = h.decorate(r.Context(), userID, todos...)
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, page)
 }
 
 func (h *todoHandlers) GetTodoById(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
-	todo, err := h.store.GetTodoById(r.Context(), uint(id))
+	todo, err := h.store.GetTodoById(r.Context(), userID, uint(id))
 	if err != nil {
 		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
 		return
 	}
-	render.JSON(w, r, todo)
+	if err := h.decorate(r.Context(), userID, todo); err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	renderTagged(w, r, todo)
 }
 
 func (h *todoHandlers) DeleteTodoById(w http.ResponseWriter, r *http.Request) {
-	todo := &todo.Todo{}
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
-	todo, err = h.store.DeleteTodoById(r.Context(), uint(id))
+	cascade := false
+	if raw := r.URL.Query().Get("cascade"); raw != "" {
+		if cascade, err = strconv.ParseBool(raw); err != nil {
+			renderError(w, r, http.StatusBadRequest, "Invalid cascade")
+			return
+		}
+	}
+	todoItem, reqErr := h.deleteTodo(r.Context(), h.store, userID, uint(id), cascade, r.Header.Get("If-Match"))
+	if reqErr != nil {
+		renderError(w, r, reqErr.status, reqErr.message)
+		return
+	}
+	render.JSON(w, r, todoItem)
+}
+
+// deleteTodo moves the todo id to the trash of store. A todo with a
+// checklist needs cascade, which deletes its items too.
+func (h *todoHandlers) deleteTodo(ctx context.Context, store todo.Store, userID uint, id uint, cascade bool, ifMatch string) (*todo.Todo, *requestError) {
+	opts := todo.DeleteOptions{Cascade: cascade}
+	if ifMatch != "" {
+		existingTodo, err := store.GetTodoById(ctx, userID, id)
+		if err != nil {
+			return nil, &requestError{http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id)}
+		}
+		if reqErr := checkIfMatch(existingTodo, ifMatch); reqErr != nil {
+			return nil, reqErr
+		}
+		opts.Version = existingTodo.Version
+	}
+	todoItem, err := store.DeleteTodoById(ctx, userID, id, opts)
+	if err == todo.ErrStale {
+		return nil, &requestError{http.StatusPreconditionFailed, err.Error()}
+	}
+	if itemsErr, ok := err.(*todo.ItemsError); ok {
+		return nil, &requestError{http.StatusConflict, fmt.Sprintf("Todo with ID %d has %d checklist items; delete them first or pass cascade=true", id, itemsErr.Count)}
+	}
+	if err == todo.ErrReadOnly || err == todo.ErrAssignee {
+		return nil, &requestError{http.StatusForbidden, err.Error()}
+	}
+	if err == gorm.ErrRecordNotFound {
+		return nil, &requestError{http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id)}
+	}
 	if err != nil {
-		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
+		return nil, &requestError{http.StatusInternalServerError, err.Error()}
+	}
+	return todoItem, nil
+}
+
+// GetChanges answers with the todos that changed since the sync token in
+// since, each with its ETag, or 410 Gone when the client has to sync again
+// from the start.
+func (h *todoHandlers) GetChanges(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	since, err := todo.ParseSyncToken(r.URL.Query().Get("since"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	render.JSON(w, r, todo)
+	maxPageSize := configs.GetConfig().Service.MAX_PAGE_SIZE
+	if maxPageSize <= 0 {
+		maxPageSize = todo.MaxPageSize
+	}
+	limit := min(todo.DefaultPageSize, maxPageSize)
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxPageSize {
+			renderError(w, r, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+	}
+	page, err := h.store.GetChanges(r.Context(), userID, since, limit)
+	if err == todo.ErrSyncTokenExpired {
+		renderError(w, r, http.StatusGone, err.Error())
+		return
+	}
+	var todos []*todo.Todo
+	if err == nil {
+		for _, change := range page.Changes {
+			if change.Todo != nil {
+				change.ETag = tagOf(change.Todo)
+				todos = append(todos, change.Todo)
+			}
+		}
+		err = h.decorate(r.Context(), userID, todos...)
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, page)
 }
 
-func (h *todoHandlers) CreateTodo(w http.ResponseWriter, r *http.Request) {
-	todoItem := new(todo.Todo)
-	err := json.NewDecoder(r.Body).Decode(todoItem)
+func (h *todoHandlers) GetTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	todos, err := h.store.GetTrash(r.Context(), userID)
+	if err == nil {
+		err = h.decorate(r.Context(), userID, todos...)
+	}
 	if err != nil {
-		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		renderError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	render.JSON(w, r, todos)
+}
 
-	todoItem, err = h.store.CreateTodo(r.Context(), todoItem)
+func (h *todoHandlers) RestoreTodoById(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todoItem, err := h.store.RestoreTodoById(r.Context(), userID, uint(id))
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d is not in the trash", id))
+		return
+	}
+	if err == nil {
+		err = h.decorate(r.Context(), userID, todoItem)
+	}
 	if err != nil {
 		renderError(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -83,36 +274,373 @@ func (h *todoHandlers) CreateTodo(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, todoItem)
 }
 
-func (h *todoHandlers) UpdateTodo(w http.ResponseWriter, r *http.Request) {
+func (h *todoHandlers) PurgeTodoById(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
-	var existingTodo *todo.Todo
-	existingTodo, err = h.store.GetTodoById(r.Context(), uint(id))
+	todoItem, err := h.store.PurgeTodoById(r.Context(), userID, uint(id))
+	if err == gorm.ErrRecordNotFound {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d is not in the trash", id))
+		return
+	}
 	if err != nil {
-		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
+		renderError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	render.JSON(w, r, todoItem)
+}
 
-	updatedFields := make(map[string]interface{})
-	if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
+func (h *todoHandlers) CreateTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	todoItem := new(todo.Todo)
+	err := json.NewDecoder(r.Body).Decode(todoItem)
+	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	created, reqErr := createTodo(r.Context(), h.store, userID, todoItem)
+	if reqErr != nil {
+		renderError(w, r, reqErr.status, reqErr.message)
+		return
+	}
+	render.JSON(w, r, created)
+}
 
-	if !maputil.AnyKeys(updatedFields, "name", "date", "description") {
-		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+// createTodo validates todoItem and creates it in store for userID.
+func createTodo(ctx context.Context, store todo.Store, userID uint, todoItem *todo.Todo) (*todo.Todo, *requestError) {
+	var err error
+	todoItem.UserID = userID
+	if todoItem.Status != "" && !todo.ValidStatus(todoItem.Status) {
+		return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid status %q", todoItem.Status)}
+	}
+	if !todo.ValidPriority(todoItem.Priority) {
+		return nil, &requestError{http.StatusBadRequest, "Priority must be between 0 and 3"}
+	}
+	todoItem.RecurrenceStart = nil
+	if todoItem.Recurrence != "" {
+		if todoItem.DueAt == nil {
+			return nil, &requestError{http.StatusBadRequest, "A recurring todo needs a due_at"}
+		}
+		if todoItem.Recurrence, err = todo.NormalizeRecurrence(todoItem.Recurrence); err != nil {
+			return nil, &requestError{http.StatusBadRequest, err.Error()}
+		}
+		start := todoItem.DueAt.UTC()
+		todoItem.RecurrenceStart = &start
+	}
+	todoItem.CompletedAt = nil
+	todoItem.NextID = nil
+	todoItem.AssigneeID = nil
+	todoItem.Progress = nil
+	todoItem.Tags = nil
+	todoItem.Version = 0
+	if todoItem.ExternalID != nil && *todoItem.ExternalID == "" {
+		todoItem.ExternalID = nil
+	}
+	if todoItem.Status == todo.StatusDone {
+		now := time.Now().UTC()
+		todoItem.CompletedAt = &now
+	}
+
+	created, err := store.CreateTodo(ctx, todoItem)
+	if err == todo.ErrListNotFound {
+		return nil, &requestError{http.StatusNotFound, fmt.Sprintf("List with ID %d not found", *todoItem.ListID)}
+	}
+	if err == todo.ErrReadOnly {
+		return nil, &requestError{http.StatusForbidden, err.Error()}
+	}
+	if err == todo.ErrExternalID {
+		return nil, &requestError{http.StatusConflict, err.Error()}
+	}
+	if err != nil {
+		return nil, &requestError{http.StatusInternalServerError, err.Error()}
+	}
+	return created, nil
+}
+
+func (h *todoHandlers) UpdateTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todoItem, reqErr := h.updateTodo(r.Context(), h.store, userID, uint(id), r.Header.Get("If-Match"), func() (map[string]interface{}, error) {
+		updatedFields := make(map[string]interface{})
+		return updatedFields, json.NewDecoder(r.Body).Decode(&updatedFields)
+	})
+	if reqErr == nil {
+		if err := h.decorate(r.Context(), userID, todoItem); err != nil {
+			reqErr = &requestError{http.StatusInternalServerError, err.Error()}
+		}
+	}
+	if reqErr != nil {
+		renderError(w, r, reqErr.status, reqErr.message)
+		return
+	}
+
+	renderTagged(w, r, todoItem)
+}
+
+// updateTodo applies the fields read by decode to the todo id of store.
+// decode is only called once the todo is known to be one userID may change.
+func (h *todoHandlers) updateTodo(ctx context.Context, store todo.Store, userID uint, id uint, ifMatch string, decode func() (map[string]interface{}, error)) (*todo.Todo, *requestError) {
+	existingTodo, err := store.GetTodoById(ctx, userID, id)
+	if err != nil {
+		return nil, &requestError{http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id)}
+	}
+	if !existingTodo.CanEdit() {
+		return nil, &requestError{http.StatusForbidden, todo.ErrReadOnly.Error()}
+	}
+	if reqErr := checkIfMatch(existingTodo, ifMatch); reqErr != nil {
+		return nil, reqErr
+	}
+
+	updatedFields, err := decode()
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid request payload"}
+	}
+
+	if !maputil.AnyKeys(updatedFields, "name", "date", "description", "status", "priority", "due_at", "recurrence", "list_id") {
+		return nil, &requestError{http.StatusBadRequest, "Invalid request payload"}
+	}
+	if _, ok := updatedFields["list_id"]; ok && existingTodo.Role == todo.RoleAssignee {
+		return nil, &requestError{http.StatusForbidden, todo.ErrAssignee.Error()}
+	}
+	if reqErr := prepareUpdate(existingTodo, updatedFields, time.Now().UTC()); reqErr != nil {
+		return nil, reqErr
+	}
+	next, err := nextOccurrence(existingTodo, updatedFields)
+	if err != nil {
+		return nil, &requestError{http.StatusInternalServerError, err.Error()}
+	}
+	if next != nil {
+		existingTodo, err = store.CompleteOccurrence(ctx, userID, existingTodo, updatedFields, next)
+	} else {
+		existingTodo, err = store.UpdateTodo(ctx, userID, existingTodo, updatedFields)
+	}
+	switch err {
+	case nil:
+		return existingTodo, nil
+	case gorm.ErrRecordNotFound:
+		return nil, &requestError{http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id)}
+	case todo.ErrListNotFound:
+		return nil, &requestError{http.StatusNotFound, fmt.Sprintf("List with ID %v not found", updatedFields["list_id"])}
+	case todo.ErrReadOnly, todo.ErrAssignee:
+		return nil, &requestError{http.StatusForbidden, err.Error()}
+	case todo.ErrStale:
+		if ifMatch != "" {
+			return nil, &requestError{http.StatusPreconditionFailed, err.Error()}
+		}
+		return nil, &requestError{http.StatusConflict, err.Error()}
+	}
+	return nil, &requestError{http.StatusInternalServerError, err.Error()}
+}
+
+func (h *todoHandlers) GetOccurrences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
-	existingTodo, err = h.store.UpdateTodo(r.Context(), existingTodo, updatedFields)
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > todo.MaxOccurrences {
+			renderError(w, r, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", todo.MaxOccurrences))
+			return
+		}
+	}
+	todoItem, err := h.store.GetTodoById(r.Context(), userID, uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Todo with ID %d not found", id))
+		return
+	}
+	if todoItem.Recurrence == "" {
+		renderError(w, r, http.StatusConflict, fmt.Sprintf("Todo with ID %d does not recur", id))
+		return
+	}
+	occurrences, err := todoItem.Upcoming(limit)
 	if err != nil {
 		renderError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	render.JSON(w, r, Occurrences{Recurrence: todoItem.Recurrence, Occurrences: occurrences})
+}
+
+// decorate sets the checklist progress and the tags userID put on todos.
+func (h *todoHandlers) decorate(ctx context.Context, userID uint, todos ...*todo.Todo) error {
+	return todo.Decorate(ctx, h.items, h.tags, userID, todos...)
+}
+
+// checkIfMatch fails with 412 Precondition Failed when ifMatch, an If-Match
+// header, is set and does not list the ETag of todoItem.
+func checkIfMatch(todoItem *todo.Todo, ifMatch string) *requestError {
+	if ifMatch == "" {
+		return nil
+	}
+	if !etag.Match(ifMatch, tagOf(todoItem), false) {
+		return &requestError{http.StatusPreconditionFailed, fmt.Sprintf("Todo with ID %d has changed", todoItem.ID)}
+	}
+	return nil
+}
+
+// requestError is a failed request's status and message.
+type requestError struct {
+	status  int
+	message string
+}
 
-	render.JSON(w, r, existingTodo)
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// prepareUpdate validates the status, priority, due date, recurrence and
+// list in fields and converts them to column values. Moving to done records
+// the completion time; leaving done clears it. A recurrence needs a due
+// date, which starts its series.
+func prepareUpdate(existing *todo.Todo, fields map[string]interface{}, now time.Time) *requestError {
+	if value, ok := fields["status"]; ok {
+		status, ok := value.(string)
+		if !ok || !todo.ValidStatus(status) {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid status %v", value)}
+		}
+		current := existing.Status
+		if current == "" {
+			current = todo.StatusOpen
+		}
+		if !todo.CanTransition(current, status) {
+			return &requestError{http.StatusConflict, fmt.Sprintf("Cannot change status from %s to %s", current, status)}
+		}
+		if status == todo.StatusDone && current != todo.StatusDone {
+			fields["completed_at"] = now
+		}
+		if status != todo.StatusDone && current == todo.StatusDone {
+			fields["completed_at"] = nil
+		}
+	}
+	if value, ok := fields["priority"]; ok {
+		priority, ok := value.(float64)
+		if !ok || priority != float64(int(priority)) || !todo.ValidPriority(int(priority)) {
+			return &requestError{http.StatusBadRequest, "Priority must be between 0 and 3"}
+		}
+		fields["priority"] = int(priority)
+	}
+	if value, ok := fields["due_at"]; ok && value != nil {
+		raw, ok := value.(string)
+		if !ok {
+			return &requestError{http.StatusBadRequest, "due_at must be an RFC 3339 timestamp or null"}
+		}
+		dueAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return &requestError{http.StatusBadRequest, "due_at must be an RFC 3339 timestamp or null"}
+		}
+		fields["due_at"] = dueAt.UTC()
+	}
+	if value, ok := fields["list_id"]; ok && value != nil {
+		listID, ok := value.(float64)
+		if !ok || listID < 1 || listID != float64(uint(listID)) {
+			return &requestError{http.StatusBadRequest, "list_id must be a list ID or null"}
+		}
+		fields["list_id"] = uint(listID)
+	}
+	dueAt := existing.DueAt
+	if value, ok := fields["due_at"]; ok {
+		dueAt = nil
+		if value != nil {
+			t := value.(time.Time)
+			dueAt = &t
+		}
+	}
+	if value, ok := fields["recurrence"]; ok && value != nil && value != "" {
+		raw, ok := value.(string)
+		if !ok {
+			return &requestError{http.StatusBadRequest, "recurrence must be an RRULE or null"}
+		}
+		recurrence, err := todo.NormalizeRecurrence(raw)
+		if err != nil {
+			return &requestError{http.StatusBadRequest, err.Error()}
+		}
+		if dueAt == nil {
+			return &requestError{http.StatusBadRequest, "A recurring todo needs a due_at"}
+		}
+		fields["recurrence"] = recurrence
+		fields["recurrence_start"] = *dueAt
+	} else if ok {
+		fields["recurrence"] = ""
+		fields["recurrence_start"] = nil
+	} else if existing.Recurrence != "" && dueAt == nil {
+		return &requestError{http.StatusBadRequest, "A recurring todo needs a due_at"}
+	}
+	return nil
+}
+
+// nextOccurrence returns the todo to create when the update in fields
+// completes a recurring todo, or nil.
+func nextOccurrence(existing *todo.Todo, fields map[string]interface{}) (*todo.Todo, error) {
+	if fields["status"] != todo.StatusDone || existing.Status == todo.StatusDone {
+		return nil, nil
+	}
+	updated := *existing
+	if value, ok := fields["due_at"]; ok {
+		updated.DueAt = nil
+		if value != nil {
+			t := value.(time.Time)
+			updated.DueAt = &t
+		}
+	}
+	if value, ok := fields["recurrence"]; ok {
+		updated.Recurrence = value.(string)
+		updated.RecurrenceStart = nil
+		if value != "" {
+			start := fields["recurrence_start"].(time.Time)
+			updated.RecurrenceStart = &start
+		}
+	}
+	return updated.NextOccurrence()
+}
+
+// tagOf returns the ETag of todoItem, which follows its version.
+func tagOf(todoItem *todo.Todo) string {
+	return etag.Version(todoItem.ID, todoItem.Version)
+}
+
+// renderTagged renders v with its ETag, the one of its version for a todo
+// and one derived from its JSON otherwise. A GET whose If-None-Match lists
+// that tag gets 304 Not Modified instead.
+func renderTagged(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var tag string
+	var err error
+	if todoItem, ok := v.(*todo.Todo); ok {
+		tag = tagOf(todoItem)
+	} else if tag, err = etag.Of(v); err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("ETag", tag)
+	if r.Method == http.MethodGet && etag.Match(r.Header.Get("If-None-Match"), tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	render.JSON(w, r, v)
 }
 
 func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
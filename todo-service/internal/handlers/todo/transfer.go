@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ennemli/todo/todo/configs"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/go-chi/render"
+)
+
+// DefaultMaxImportSize is the largest file, in bytes, Import reads unless
+// MAX_IMPORT_SIZE is set.
+const DefaultMaxImportSize = 1 << 20
+
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportInvalid = "invalid"
+)
+
+// ImportRow is what became of the todo at position Row, from 1, of an
+// imported file: it was created as the todo ID, skipped because a todo with
+// its external ID exists, or invalid because of Errors.
+type ImportRow struct {
+	Row        int      `json:"row"`
+	ExternalID string   `json:"external_id,omitempty"`
+	Status     string   `json:"status"`
+	ID         uint     `json:"id,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun  bool         `json:"dry_run"`
+	Created int          `json:"created"`
+	Skipped int          `json:"skipped"`
+	Invalid int          `json:"invalid"`
+	Rows    []*ImportRow `json:"rows"`
+}
+
+// errRollback rolls back an import that is a dry run or has invalid rows.
+var errRollback = errors.New("rollback")
+
+// Export streams the todos the caller can see as a file in the format
+// parameter: json, the default, csv or ics.
+func (h *todoHandlers) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+	contentType, ok := formats[format]
+	if !ok {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid format %q", format))
+		return
+	}
+	attach := func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="todos.%s"`, format))
+	}
+
+	enc := newEncoder(format, w)
+	written := false
+	err := h.store.ExportTodos(r.Context(), userID, func(todos []*todo.Todo) error {
+		if format == FormatJSON {
+			if err := h.decorate(r.Context(), userID, todos...); err != nil {
+				return err
+			}
+		}
+		if !written {
+			attach()
+			written = true
+		}
+		return enc.encode(todos)
+	})
+	if err != nil && !written {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Once the file is being written, an error can only cut it short.
+	if err != nil {
+		return
+	}
+	if !written {
+		attach()
+	}
+	enc.close()
+}
+
+// Import creates the todos of a file in the format parameter or, without
+// one, in the format of its Content-Type. The todos are created in one
+// transaction, skipping those whose external ID is already taken; a row
+// that is invalid fails the import with 422 Unprocessable Entity. With
+// dry_run=true nothing is created, but every row is reported as it would
+// have been.
+func (h *todoHandlers) Import(w http.ResponseWriter, r *http.Request) {
+	userID, ok := identity.UserID(r.Context())
+	if !ok {
+		renderError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatOf(r.Header.Get("Content-Type"))
+	}
+	if _, ok := formats[format]; !ok {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid format %q", format))
+		return
+	}
+	var err error
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			renderError(w, r, http.StatusBadRequest, "Invalid dry_run")
+			return
+		}
+	}
+	maxSize := int64(configs.GetConfig().Service.MAX_IMPORT_SIZE)
+	if maxSize <= 0 {
+		maxSize = DefaultMaxImportSize
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		renderError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The file is larger than %d bytes", maxSize))
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	rows, err := decodeTodos(format, data)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.importTodos(r.Context(), userID, rows, dryRun)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if result.Invalid > 0 && !dryRun {
+		render.Status(r, http.StatusUnprocessableEntity)
+	}
+	render.JSON(w, r, result)
+}
+
+// importTodos creates the todos of rows for userID in one transaction, each
+// in a savepoint of its own, and rolls it back when it is a dry run or a
+// row is invalid.
+func (h *todoHandlers) importTodos(ctx context.Context, userID uint, rows []*importRow, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun, Rows: make([]*ImportRow, len(rows))}
+	created := make([]*todo.Todo, len(rows))
+	err := h.store.Transaction(ctx, func(tx todo.Store) error {
+		for i, row := range rows {
+			outcome := &ImportRow{Row: i + 1, Status: ImportInvalid, Errors: row.errors}
+			if row.todo.ExternalID != nil {
+				outcome.ExternalID = *row.todo.ExternalID
+			}
+			if strings.TrimSpace(row.todo.Name) == "" {
+				outcome.Errors = append(outcome.Errors, "A todo needs a name")
+			}
+			result.Rows[i] = outcome
+			if len(outcome.Errors) > 0 {
+				result.Invalid++
+				continue
+			}
+			var reqErr *requestError
+			err := tx.Transaction(ctx, func(tx todo.Store) error {
+				created[i], reqErr = createTodo(ctx, tx, userID, row.todo)
+				if reqErr != nil {
+					return reqErr
+				}
+				return nil
+			})
+			switch {
+			case reqErr == nil && err != nil:
+				return err
+			case reqErr == nil:
+				outcome.Status = ImportCreated
+				result.Created++
+			case reqErr.status == http.StatusInternalServerError:
+				return reqErr
+			// createTodo only conflicts with a todo of the same external ID.
+			case reqErr.status == http.StatusConflict:
+				outcome.Status = ImportSkipped
+				result.Skipped++
+			default:
+				outcome.Errors = []string{reqErr.message}
+				result.Invalid++
+			}
+		}
+		if dryRun || result.Invalid > 0 {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && err != errRollback {
+		return nil, err
+	}
+	if err == nil {
+		for i, outcome := range result.Rows {
+			if outcome.Status == ImportCreated {
+				outcome.ID = created[i].ID
+			}
+		}
+	}
+	return result, nil
+}
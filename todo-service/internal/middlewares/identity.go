@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/pkg/identity"
+	"github.com/go-chi/render"
+)
+
+func WithIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := identity.FromRequest(r)
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, errors.ResponseUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(identity.NewContext(r.Context(), caller)))
+	})
+}
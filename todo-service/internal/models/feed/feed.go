@@ -0,0 +1,244 @@
+package feed
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Counter hands out the sequence numbers of the changes a user sees. A
+// user's row stays locked by the transaction that took a number until it
+// commits, so their numbers become visible in the order they were taken,
+// while the writes other users see do not wait for it. PurgedSeq is the
+// highest number of a purged todo's tombstone.
+type Counter struct {
+	UserID    uint   `gorm:"primaryKey;autoIncrement:false"`
+	Seq       uint64 `gorm:"not null;default:0"`
+	PurgedSeq uint64 `gorm:"not null;default:0"`
+}
+
+func (Counter) TableName() string {
+	return "feed_counters"
+}
+
+// Entry is the last change of a todo a user saw, numbered by their
+// counter. Deleted marks a tombstone: the todo went to the trash, or the
+// user can no longer see it.
+type Entry struct {
+	UserID  uint   `gorm:"primaryKey;autoIncrement:false"`
+	TodoID  uint   `gorm:"primaryKey;autoIncrement:false;index"`
+	Seq     uint64 `gorm:"not null;index"`
+	Deleted bool   `gorm:"not null;default:false"`
+}
+
+func (Entry) TableName() string {
+	return "feed_entries"
+}
+
+// Touch records a change of the todos ids that leaves their rows as they
+// were, such as to their tags or checklist, and bumps their versions.
+func Touch(tx *gorm.DB, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Table("todos").Where("id IN ?", ids).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return err
+	}
+	return Record(tx, ids...)
+}
+
+// Record records a change of the todos ids for every user who can see them,
+// and a tombstone for every user who saw them but no longer can.
+func Record(tx *gorm.DB, ids ...uint) error {
+	return record(tx, ids, nil)
+}
+
+// Reach records the todos ids for userID alone, after their access to them
+// changed: a change of those they can see, and a tombstone of those they
+// saw but no longer can.
+func Reach(tx *gorm.DB, userID uint, ids ...uint) error {
+	return record(tx, ids, &userID)
+}
+
+func record(tx *gorm.DB, ids []uint, only *uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var todos []struct {
+		ID         uint
+		UserID     uint
+		ListID     *uint
+		AssigneeID *uint
+		DeletedAt  gorm.DeletedAt
+	}
+	err := tx.Table("todos").Select("id, user_id, list_id, assignee_id, deleted_at").Where("id IN ?", ids).Scan(&todos).Error
+	if err != nil {
+		return err
+	}
+	viewers, err := viewersOf(tx, ids)
+	if err != nil {
+		return err
+	}
+	var entries []*Entry
+	if err := tx.Where("todo_id IN ?", ids).Find(&entries).Error; err != nil {
+		return err
+	}
+	seen := map[uint]map[uint]bool{}
+	for _, entry := range entries {
+		if seen[entry.TodoID] == nil {
+			seen[entry.TodoID] = map[uint]bool{}
+		}
+		seen[entry.TodoID][entry.UserID] = !entry.Deleted
+	}
+
+	changes := map[uint][]*Entry{}
+	add := func(userID uint, todoID uint, deleted bool) {
+		if only != nil && userID != *only {
+			return
+		}
+		if _, ok := seen[todoID][userID]; deleted && !ok {
+			return
+		}
+		changes[userID] = append(changes[userID], &Entry{UserID: userID, TodoID: todoID, Deleted: deleted})
+	}
+	for _, t := range todos {
+		can := map[uint]bool{}
+		if t.ListID == nil {
+			can[t.UserID] = true
+		} else {
+			for _, userID := range viewers[*t.ListID] {
+				can[userID] = true
+			}
+		}
+		if t.AssigneeID != nil {
+			can[*t.AssigneeID] = true
+		}
+		for userID := range can {
+			add(userID, t.ID, t.DeletedAt.Valid)
+		}
+		for userID, visible := range seen[t.ID] {
+			if visible && !can[userID] {
+				add(userID, t.ID, true)
+			}
+		}
+	}
+	return save(tx, changes)
+}
+
+// viewersOf returns the members of the lists of the todos ids.
+func viewersOf(tx *gorm.DB, ids []uint) (map[uint][]uint, error) {
+	var members []struct {
+		ListID uint
+		UserID uint
+	}
+	err := tx.Table("list_members").Select("list_id, user_id").
+		Where("list_id IN (SELECT list_id FROM todos WHERE id IN ?)", ids).
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	viewers := map[uint][]uint{}
+	for _, member := range members {
+		viewers[member.ListID] = append(viewers[member.ListID], member.UserID)
+	}
+	return viewers, nil
+}
+
+// save numbers the entries of each user and stores them. Counters are
+// locked in the order of user ids, so that two writes cannot wait for each
+// other.
+func save(tx *gorm.DB, changes map[uint][]*Entry) error {
+	userIDs := make([]uint, 0, len(changes))
+	for userID := range changes {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	for _, userID := range userIDs {
+		entries := changes[userID]
+		sort.Slice(entries, func(i, j int) bool { return entries[i].TodoID < entries[j].TodoID })
+		seq, err := next(tx, userID, len(entries))
+		if err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			entry.Seq = seq - uint64(len(entries)-1-i)
+		}
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "todo_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"seq", "deleted"}),
+		}).Create(&entries).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next takes count sequence numbers of userID and returns the last one.
+func next(tx *gorm.DB, userID uint, count int) (uint64, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Counter{UserID: userID}).Error; err != nil {
+		return 0, err
+	}
+	err := tx.Model(&Counter{}).Where("user_id = ?", userID).UpdateColumn("seq", gorm.Expr("seq + ?", count)).Error
+	if err != nil {
+		return 0, err
+	}
+	counter := new(Counter)
+	if err := tx.Where("user_id = ?", userID).First(counter).Error; err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// Forget drops the entries of the todos ids, which are purged, and records
+// for each user that their tombstones up to the last of them are gone.
+func Forget(tx *gorm.DB, ids ...uint) error {
+	var purged []struct {
+		UserID uint
+		Seq    uint64
+	}
+	err := tx.Model(&Entry{}).Select("user_id, MAX(seq) AS seq").Where("todo_id IN ?", ids).Group("user_id").Scan(&purged).Error
+	if err != nil {
+		return err
+	}
+	for _, p := range purged {
+		err := tx.Model(&Counter{}).Where("user_id = ? AND purged_seq < ?", p.UserID, p.Seq).UpdateColumn("purged_seq", p.Seq).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("todo_id IN ?", ids).Delete(&Entry{}).Error
+}
+
+// Drop deletes the feed of userID, who is purged.
+func Drop(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&Entry{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&Counter{}).Error
+}
+
+// Migrate creates the feed. On its first run, the todos written before
+// there was one are recorded for the users who can see them.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Counter{}, &Entry{}); err != nil {
+		return err
+	}
+	var count int64
+	if err := db.Model(&Counter{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	var ids []uint
+	if err := db.Table("todos").Where("deleted_at IS NULL").Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(ids); start += 1000 {
+			if err := Record(tx, ids[start:min(start+1000, len(ids))]...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
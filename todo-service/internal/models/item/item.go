@@ -0,0 +1,225 @@
+package item
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ennemli/todo/todo/internal/db"
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"gorm.io/gorm"
+)
+
+var ErrOrderMismatch = errors.New("The order must list every item of the todo exactly once")
+
+type Store interface {
+	GetItems(ctx context.Context, todoID uint) ([]*Item, error)
+	GetItemById(ctx context.Context, todoID uint, id uint) (*Item, error)
+	CreateItem(ctx context.Context, itemEntry *Item) (*Item, error)
+	UpdateItem(ctx context.Context, itemEntry *Item, fields map[string]interface{}) (*Item, error)
+	DeleteItemById(ctx context.Context, todoID uint, id uint) (*Item, error)
+	ReorderItems(ctx context.Context, todoID uint, ids []uint) ([]*Item, error)
+	CountItems(ctx context.Context, todoID uint) (int64, error)
+	DeleteItems(ctx context.Context, todoID uint) error
+	Progress(ctx context.Context, todoIDs []uint) (map[uint]int, error)
+	CopyItems(ctx context.Context, fromID uint, toID uint) error
+}
+
+// Item is a step of a todo's checklist. Items are listed by Position.
+type Item struct {
+	gorm.Model
+	TodoID   uint   `json:"todo_id" gorm:"index;not null"`
+	Name     string `json:"name" gorm:"not null"`
+	Done     bool   `json:"done" gorm:"not null;default:false"`
+	Position int    `json:"position" gorm:"not null;default:0"`
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func NewStore() Store {
+	return NewStoreWith(db.GetDB())
+}
+
+// NewStoreWith returns a store that works in db, such as the transaction of
+// another store.
+func NewStoreWith(db *gorm.DB) Store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) GetItems(ctx context.Context, todoID uint) ([]*Item, error) {
+	items := []*Item{}
+	if err := s.db.WithContext(ctx).Where("todo_id = ?", todoID).Order("position, id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *store) GetItemById(ctx context.Context, todoID uint, id uint) (*Item, error) {
+	itemEntry := new(Item)
+	if err := s.db.WithContext(ctx).Where("todo_id = ?", todoID).First(itemEntry, id).Error; err != nil {
+		return nil, err
+	}
+	return itemEntry, nil
+}
+
+// CreateItem appends itemEntry to the end of its todo's checklist. Like
+// UpdateItem when it checks or unchecks an item, and DeleteItemById, it
+// changes the progress of the todo, which is recorded as a change of it.
+func (s *store) CreateItem(ctx context.Context, itemEntry *Item) (*Item, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&Item{}).Where("todo_id = ?", itemEntry.TodoID).
+			Select("COALESCE(MAX(position), -1)").Scan(&last).Error; err != nil {
+			return err
+		}
+		itemEntry.Position = last + 1
+		if err := tx.Create(itemEntry).Error; err != nil {
+			return err
+		}
+		return feed.Touch(tx, itemEntry.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return itemEntry, nil
+}
+
+func (s *store) UpdateItem(ctx context.Context, itemEntry *Item, fields map[string]interface{}) (*Item, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(itemEntry).Where("todo_id = ?", itemEntry.TodoID).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if _, ok := fields["done"]; !ok {
+			return nil
+		}
+		return feed.Touch(tx, itemEntry.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return itemEntry, nil
+}
+
+func (s *store) DeleteItemById(ctx context.Context, todoID uint, id uint) (*Item, error) {
+	itemEntry := new(Item)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("todo_id = ?", todoID).First(itemEntry, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(itemEntry).Error; err != nil {
+			return err
+		}
+		return feed.Touch(tx, todoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return itemEntry, nil
+}
+
+// ReorderItems gives the items of a todo the positions of their ids in ids.
+func (s *store) ReorderItems(ctx context.Context, todoID uint, ids []uint) ([]*Item, error) {
+	var items []*Item
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&Item{}).Where("todo_id = ?", todoID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		if !sameIDs(existing, ids) {
+			return ErrOrderMismatch
+		}
+		for position, id := range ids {
+			if err := tx.Model(&Item{}).Where("id = ? AND todo_id = ?", id, todoID).
+				Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("todo_id = ?", todoID).Order("position, id").Find(&items).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *store) CountItems(ctx context.Context, todoID uint) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&Item{}).Where("todo_id = ?", todoID).Count(&count).Error
+	return count, err
+}
+
+func (s *store) DeleteItems(ctx context.Context, todoID uint) error {
+	return s.db.WithContext(ctx).Where("todo_id = ?", todoID).Delete(&Item{}).Error
+}
+
+// CopyItems copies the checklist of the todo fromID, unchecked, to the todo
+// toID.
+func (s *store) CopyItems(ctx context.Context, fromID uint, toID uint) error {
+	items, err := s.GetItems(ctx, fromID)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	for _, itemEntry := range items {
+		itemEntry.Model = gorm.Model{}
+		itemEntry.TodoID = toID
+		itemEntry.Done = false
+	}
+	return s.db.WithContext(ctx).Create(&items).Error
+}
+
+// Progress returns the percentage of done items of each todo in todoIDs
+// that has a checklist.
+func (s *store) Progress(ctx context.Context, todoIDs []uint) (map[uint]int, error) {
+	progress := map[uint]int{}
+	if len(todoIDs) == 0 {
+		return progress, nil
+	}
+	var rows []struct {
+		TodoID uint
+		Total  int
+		Done   int
+	}
+	err := s.db.WithContext(ctx).Model(&Item{}).
+		Select("todo_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE done) AS done").
+		Where("todo_id IN ?", todoIDs).Group("todo_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		progress[row.TodoID] = Percent(row.Done, row.Total)
+	}
+	return progress, nil
+}
+
+// Percent is done out of total as a whole percentage, rounded down so that a
+// checklist only shows 100 once every item is done.
+func Percent(done int, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return done * 100 / total
+}
+
+func sameIDs(existing []uint, ids []uint) bool {
+	if len(existing) != len(ids) {
+		return false
+	}
+	seen := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package item
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockItem struct {
+	mock.Mock
+}
+
+func (m *MockItem) GetItems(ctx context.Context, todoID uint) ([]*Item, error) {
+	args := m.Called(ctx, todoID)
+	return args.Get(0).([]*Item), args.Error(1)
+}
+
+func (m *MockItem) GetItemById(ctx context.Context, todoID uint, id uint) (*Item, error) {
+	args := m.Called(ctx, todoID, id)
+	return args.Get(0).(*Item), args.Error(1)
+}
+
+func (m *MockItem) CreateItem(ctx context.Context, itemEntry *Item) (*Item, error) {
+	args := m.Called(ctx, itemEntry)
+	return args.Get(0).(*Item), args.Error(1)
+}
+
+func (m *MockItem) UpdateItem(ctx context.Context, itemEntry *Item, fields map[string]interface{}) (*Item, error) {
+	args := m.Called(ctx, itemEntry, fields)
+	return args.Get(0).(*Item), args.Error(1)
+}
+
+func (m *MockItem) DeleteItemById(ctx context.Context, todoID uint, id uint) (*Item, error) {
+	args := m.Called(ctx, todoID, id)
+	return args.Get(0).(*Item), args.Error(1)
+}
+
+func (m *MockItem) ReorderItems(ctx context.Context, todoID uint, ids []uint) ([]*Item, error) {
+	args := m.Called(ctx, todoID, ids)
+	return args.Get(0).([]*Item), args.Error(1)
+}
+
+func (m *MockItem) CountItems(ctx context.Context, todoID uint) (int64, error) {
+	args := m.Called(ctx, todoID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockItem) DeleteItems(ctx context.Context, todoID uint) error {
+	args := m.Called(ctx, todoID)
+	return args.Error(0)
+}
+
+func (m *MockItem) Progress(ctx context.Context, todoIDs []uint) (map[uint]int, error) {
+	args := m.Called(ctx, todoIDs)
+	return args.Get(0).(map[uint]int), args.Error(1)
+}
+
+func (m *MockItem) CopyItems(ctx context.Context, fromID uint, toID uint) error {
+	args := m.Called(ctx, fromID, toID)
+	return args.Error(0)
+}
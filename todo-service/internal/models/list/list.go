@@ -0,0 +1,370 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/db"
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrLastOwner      = errors.New("A list must keep at least one owner")
+	ErrListNotEmpty   = errors.New("Move or delete the todos of the list first")
+	ErrAlreadyMember  = errors.New("The user is already a member of this list")
+	ErrAlreadyInvited = errors.New("The user already has a pending invitation to this list")
+)
+
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// CanEdit reports whether members with role may change the list's todos.
+func CanEdit(role string) bool {
+	return role == RoleOwner || role == RoleEditor
+}
+
+type Store interface {
+	GetLists(ctx context.Context, userID uint) ([]*List, error)
+	GetListById(ctx context.Context, userID uint, id uint) (*List, error)
+	CreateList(ctx context.Context, listItem *List) (*List, error)
+	UpdateList(ctx context.Context, listItem *List, fields map[string]interface{}) (*List, error)
+	DeleteListById(ctx context.Context, id uint) (*List, error)
+	GetMembers(ctx context.Context, listID uint) ([]*Member, error)
+	SetRole(ctx context.Context, listID uint, userID uint, role string) (*Member, error)
+	RemoveMember(ctx context.Context, listID uint, userID uint) error
+	GetInvitations(ctx context.Context, listID uint) ([]*Invitation, error)
+	GetInvitationsFor(ctx context.Context, userID uint) ([]*Invitation, error)
+	GetInvitationById(ctx context.Context, id uint) (*Invitation, error)
+	CreateInvitation(ctx context.Context, invitation *Invitation) (*Invitation, error)
+	DeleteInvitationById(ctx context.Context, id uint) error
+	AcceptInvitation(ctx context.Context, invitation *Invitation) (*Member, error)
+}
+
+// List is a project whose todos are shared by its members. Role is the
+// role of the user who asked for the list and Members the number of
+// members; both are only filled in by GetLists and GetListById.
+type List struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name" gorm:"not null"`
+	CreatedBy uint      `json:"created_by" gorm:"not null"`
+	Role      string    `json:"role,omitempty" gorm:"column:member_role;->;-:migration"`
+	Members   int64     `json:"members" gorm:"column:member_count;->;-:migration"`
+}
+
+// Member gives a user of the user service a role in a list.
+type Member struct {
+	ListID    uint      `json:"list_id" gorm:"primaryKey;autoIncrement:false"`
+	UserID    uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false;index"`
+	Role      string    `json:"role" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Member) TableName() string {
+	return "list_members"
+}
+
+// Invitation asks a user to join a list with a role. Accepting it makes
+// them a member; ListName is filled in by GetInvitationsFor.
+type Invitation struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	ListID    uint      `json:"list_id" gorm:"uniqueIndex:idx_list_invitations_list_user;not null"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_list_invitations_list_user;index;not null"`
+	Role      string    `json:"role" gorm:"not null"`
+	InvitedBy uint      `json:"invited_by" gorm:"not null"`
+	ListName  string    `json:"list_name,omitempty" gorm:"column:list_name;->;-:migration"`
+}
+
+func (Invitation) TableName() string {
+	return "list_invitations"
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func NewStore() Store {
+	return NewStoreWith(db.GetDB())
+}
+
+// NewStoreWith returns a store that works in db, such as the transaction of
+// another store.
+func NewStoreWith(db *gorm.DB) Store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) lists(ctx context.Context, userID uint) *gorm.DB {
+	return s.db.WithContext(ctx).Model(&List{}).
+		Select("lists.*, list_members.role AS member_role, (SELECT COUNT(*) FROM list_members AS m WHERE m.list_id = lists.id) AS member_count").
+		Joins("JOIN list_members ON list_members.list_id = lists.id AND list_members.user_id = ?", userID)
+}
+
+func (s *store) GetLists(ctx context.Context, userID uint) ([]*List, error) {
+	lists := []*List{}
+	if err := s.lists(ctx, userID).Order("lists.name, lists.id").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// GetListById returns the list only when userID is one of its members.
+func (s *store) GetListById(ctx context.Context, userID uint, id uint) (*List, error) {
+	listItem := new(List)
+	if err := s.lists(ctx, userID).Where("lists.id = ?", id).First(listItem).Error; err != nil {
+		return nil, err
+	}
+	return listItem, nil
+}
+
+// CreateList makes the creator of the list its owner.
+func (s *store) CreateList(ctx context.Context, listItem *List) (*List, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(listItem).Error; err != nil {
+			return err
+		}
+		return tx.Create(&Member{ListID: listItem.ID, UserID: listItem.CreatedBy, Role: RoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	listItem.Role = RoleOwner
+	listItem.Members = 1
+	return listItem, nil
+}
+
+func (s *store) UpdateList(ctx context.Context, listItem *List, fields map[string]interface{}) (*List, error) {
+	result := s.db.WithContext(ctx).Model(listItem).Updates(fields)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return listItem, nil
+}
+
+// DeleteListById deletes an empty list with its members and invitations.
+// Its todos in the trash go back to the trash of the users who created them,
+// which is a change of those todos.
+func (s *store) DeleteListById(ctx context.Context, id uint) (*List, error) {
+	listItem := new(List)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(listItem, id).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Table("todos").Where("list_id = ? AND deleted_at IS NULL", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrListNotEmpty
+		}
+		ids, err := todosOf(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("todos").Where("list_id = ?", id).Update("list_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("list_id = ?", id).Delete(&Member{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("list_id = ?", id).Delete(&Invitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(listItem).Error; err != nil {
+			return err
+		}
+		return feed.Touch(tx, ids...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listItem, nil
+}
+
+func (s *store) GetMembers(ctx context.Context, listID uint) ([]*Member, error) {
+	members := []*Member{}
+	if err := s.db.WithContext(ctx).Where("list_id = ?", listID).Order("created_at, user_id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// SetRole changes the role of a member, which is how they see the list's
+// todos.
+func (s *store) SetRole(ctx context.Context, listID uint, userID uint, role string) (*Member, error) {
+	member := new(Member)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("list_id = ? AND user_id = ?", listID, userID).First(member).Error; err != nil {
+			return err
+		}
+		if member.Role == RoleOwner && role != RoleOwner {
+			if err := keepOwner(tx, listID, userID); err != nil {
+				return err
+			}
+		}
+		member.Role = role
+		if err := tx.Model(member).Where("list_id = ? AND user_id = ?", listID, userID).Update("role", role).Error; err != nil {
+			return err
+		}
+		return reach(tx, listID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// RemoveMember takes the list's todos out of the member's reach, unless they
+// are assigned to them.
+func (s *store) RemoveMember(ctx context.Context, listID uint, userID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member := new(Member)
+		if err := tx.Where("list_id = ? AND user_id = ?", listID, userID).First(member).Error; err != nil {
+			return err
+		}
+		if member.Role == RoleOwner {
+			if err := keepOwner(tx, listID, userID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("list_id = ? AND user_id = ?", listID, userID).Delete(&Member{}).Error; err != nil {
+			return err
+		}
+		return reach(tx, listID, userID)
+	})
+}
+
+func (s *store) GetInvitations(ctx context.Context, listID uint) ([]*Invitation, error) {
+	invitations := []*Invitation{}
+	if err := s.db.WithContext(ctx).Where("list_id = ?", listID).Order("id").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// GetInvitationsFor returns the pending invitations of userID.
+func (s *store) GetInvitationsFor(ctx context.Context, userID uint) ([]*Invitation, error) {
+	invitations := []*Invitation{}
+	err := s.db.WithContext(ctx).Model(&Invitation{}).
+		Select("list_invitations.*, lists.name AS list_name").
+		Joins("JOIN lists ON lists.id = list_invitations.list_id").
+		Where("list_invitations.user_id = ?", userID).
+		Order("list_invitations.id").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (s *store) GetInvitationById(ctx context.Context, id uint) (*Invitation, error) {
+	invitation := new(Invitation)
+	if err := s.db.WithContext(ctx).First(invitation, id).Error; err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *store) CreateInvitation(ctx context.Context, invitation *Invitation) (*Invitation, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Member{}).Where("list_id = ? AND user_id = ?", invitation.ListID, invitation.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyMember
+		}
+		if err := tx.Model(&Invitation{}).Where("list_id = ? AND user_id = ?", invitation.ListID, invitation.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyInvited
+		}
+		return tx.Create(invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *store) DeleteInvitationById(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Delete(&Invitation{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation turns the invitation into a membership.
+func (s *store) AcceptInvitation(ctx context.Context, invitation *Invitation) (*Member, error) {
+	member := &Member{ListID: invitation.ListID, UserID: invitation.UserID, Role: invitation.Role}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&Invitation{}, invitation.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return reach(tx, member.ListID, member.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// keepOwner fails with ErrLastOwner when userID is the only owner of the
+// list.
+func keepOwner(tx *gorm.DB, listID uint, userID uint) error {
+	var count int64
+	err := tx.Model(&Member{}).Where("list_id = ? AND role = ? AND user_id <> ?", listID, RoleOwner, userID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// todosOf returns the ids of the todos of the list, in the trash or not.
+func todosOf(tx *gorm.DB, listID uint) ([]uint, error) {
+	var ids []uint
+	if err := tx.Table("todos").Where("list_id = ?", listID).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// reach records the todos of the list for userID, whose membership
+// changed.
+func reach(tx *gorm.DB, listID uint, userID uint) error {
+	ids, err := todosOf(tx, listID)
+	if err != nil {
+		return err
+	}
+	return feed.Reach(tx, userID, ids...)
+}
@@ -0,0 +1,81 @@
+package list
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockList struct {
+	mock.Mock
+}
+
+func (m *MockList) GetLists(ctx context.Context, userID uint) ([]*List, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*List), args.Error(1)
+}
+
+func (m *MockList) GetListById(ctx context.Context, userID uint, id uint) (*List, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*List), args.Error(1)
+}
+
+func (m *MockList) CreateList(ctx context.Context, listItem *List) (*List, error) {
+	args := m.Called(ctx, listItem)
+	return args.Get(0).(*List), args.Error(1)
+}
+
+func (m *MockList) UpdateList(ctx context.Context, listItem *List, fields map[string]interface{}) (*List, error) {
+	args := m.Called(ctx, listItem, fields)
+	return args.Get(0).(*List), args.Error(1)
+}
+
+func (m *MockList) DeleteListById(ctx context.Context, id uint) (*List, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*List), args.Error(1)
+}
+
+func (m *MockList) GetMembers(ctx context.Context, listID uint) ([]*Member, error) {
+	args := m.Called(ctx, listID)
+	return args.Get(0).([]*Member), args.Error(1)
+}
+
+func (m *MockList) SetRole(ctx context.Context, listID uint, userID uint, role string) (*Member, error) {
+	args := m.Called(ctx, listID, userID, role)
+	return args.Get(0).(*Member), args.Error(1)
+}
+
+func (m *MockList) RemoveMember(ctx context.Context, listID uint, userID uint) error {
+	args := m.Called(ctx, listID, userID)
+	return args.Error(0)
+}
+
+func (m *MockList) GetInvitations(ctx context.Context, listID uint) ([]*Invitation, error) {
+	args := m.Called(ctx, listID)
+	return args.Get(0).([]*Invitation), args.Error(1)
+}
+
+func (m *MockList) GetInvitationsFor(ctx context.Context, userID uint) ([]*Invitation, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*Invitation), args.Error(1)
+}
+
+func (m *MockList) GetInvitationById(ctx context.Context, id uint) (*Invitation, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (m *MockList) CreateInvitation(ctx context.Context, invitation *Invitation) (*Invitation, error) {
+	args := m.Called(ctx, invitation)
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (m *MockList) DeleteInvitationById(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockList) AcceptInvitation(ctx context.Context, invitation *Invitation) (*Member, error) {
+	args := m.Called(ctx, invitation)
+	return args.Get(0).(*Member), args.Error(1)
+}
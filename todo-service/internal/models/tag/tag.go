@@ -0,0 +1,224 @@
+package tag
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/db"
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDuplicateTag = errors.New("A tag with this name already exists")
+
+type Store interface {
+	GetTags(ctx context.Context, userID uint) ([]*Tag, error)
+	GetTagById(ctx context.Context, userID uint, id uint) (*Tag, error)
+	CreateTag(ctx context.Context, tagItem *Tag) (*Tag, error)
+	UpdateTag(ctx context.Context, tagItem *Tag, fields map[string]interface{}) (*Tag, error)
+	DeleteTagById(ctx context.Context, userID uint, id uint) (*Tag, error)
+	AttachTag(ctx context.Context, todoID uint, tagID uint) error
+	DetachTag(ctx context.Context, todoID uint, tagID uint) error
+	TagsOf(ctx context.Context, userID uint, todoIDs []uint) (map[uint][]*Tag, error)
+	CopyTags(ctx context.Context, fromID uint, toID uint) error
+}
+
+// Tag is a label a user can put on their todos. Names are unique per user.
+// Usage is the number of todos carrying the tag and is only filled in by
+// GetTags.
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `json:"userid" gorm:"uniqueIndex:idx_tags_user_name;not null"`
+	Name      string    `json:"name" gorm:"uniqueIndex:idx_tags_user_name;not null"`
+	Color     string    `json:"color,omitempty"`
+	Usage     int64     `json:"usage" gorm:"column:usage_count;->;-:migration"`
+}
+
+// TodoTag joins todos and tags.
+type TodoTag struct {
+	TodoID uint `gorm:"primaryKey;autoIncrement:false"`
+	TagID  uint `gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+type store struct {
+	db *gorm.DB
+}
+
+func NewStore() Store {
+	return NewStoreWith(db.GetDB())
+}
+
+// NewStoreWith returns a store that works in db, such as the transaction of
+// another store.
+func NewStoreWith(db *gorm.DB) Store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) GetTags(ctx context.Context, userID uint) ([]*Tag, error) {
+	tags := []*Tag{}
+	err := s.db.WithContext(ctx).Model(&Tag{}).
+		Select("tags.*, COUNT(todos.id) AS usage_count").
+		Joins("LEFT JOIN todo_tags ON todo_tags.tag_id = tags.id").
+		Joins("LEFT JOIN todos ON todos.id = todo_tags.todo_id AND todos.deleted_at IS NULL").
+		Where("tags.user_id = ?", userID).
+		Group("tags.id").
+		Order("tags.name").
+		Find(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *store) GetTagById(ctx context.Context, userID uint, id uint) (*Tag, error) {
+	tagItem := new(Tag)
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(tagItem, id).Error; err != nil {
+		return nil, err
+	}
+	return tagItem, nil
+}
+
+func (s *store) CreateTag(ctx context.Context, tagItem *Tag) (*Tag, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkName(tx, tagItem.UserID, 0, tagItem.Name); err != nil {
+			return err
+		}
+		return tx.Create(tagItem).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tagItem, nil
+}
+
+// UpdateTag changes the tag of every todo carrying it.
+func (s *store) UpdateTag(ctx context.Context, tagItem *Tag, fields map[string]interface{}) (*Tag, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if name, ok := fields["name"].(string); ok {
+			if err := checkName(tx, tagItem.UserID, tagItem.ID, name); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(tagItem).Where("user_id = ?", tagItem.UserID).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return touch(tx, tagItem.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tagItem, nil
+}
+
+// DeleteTagById deletes the tag and takes it off every todo.
+func (s *store) DeleteTagById(ctx context.Context, userID uint, id uint) (*Tag, error) {
+	tagItem := new(Tag)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).First(tagItem, id).Error; err != nil {
+			return err
+		}
+		if err := touch(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", id).Delete(&TodoTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tagItem).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tagItem, nil
+}
+
+func (s *store) AttachTag(ctx context.Context, todoID uint, tagID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&TodoTag{TodoID: todoID, TagID: tagID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return feed.Touch(tx, todoID)
+	})
+}
+
+func (s *store) DetachTag(ctx context.Context, todoID uint, tagID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("todo_id = ? AND tag_id = ?", todoID, tagID).Delete(&TodoTag{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return feed.Touch(tx, todoID)
+	})
+}
+
+// TagsOf returns the tags userID put on each todo in todoIDs, sorted by
+// name.
+func (s *store) TagsOf(ctx context.Context, userID uint, todoIDs []uint) (map[uint][]*Tag, error) {
+	tags := map[uint][]*Tag{}
+	if len(todoIDs) == 0 {
+		return tags, nil
+	}
+	var rows []struct {
+		Tag
+		TodoID uint
+	}
+	err := s.db.WithContext(ctx).Model(&Tag{}).
+		Select("tags.*, todo_tags.todo_id").
+		Joins("JOIN todo_tags ON todo_tags.tag_id = tags.id").
+		Where("tags.user_id = ? AND todo_tags.todo_id IN ?", userID, todoIDs).
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		tags[rows[i].TodoID] = append(tags[rows[i].TodoID], &rows[i].Tag)
+	}
+	return tags, nil
+}
+
+// CopyTags puts the tags of the todo fromID on the todo toID.
+func (s *store) CopyTags(ctx context.Context, fromID uint, toID uint) error {
+	var tagIDs []uint
+	if err := s.db.WithContext(ctx).Model(&TodoTag{}).Where("todo_id = ?", fromID).Pluck("tag_id", &tagIDs).Error; err != nil {
+		return err
+	}
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	links := make([]*TodoTag, len(tagIDs))
+	for i, tagID := range tagIDs {
+		links[i] = &TodoTag{TodoID: toID, TagID: tagID}
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+func checkName(tx *gorm.DB, userID uint, id uint, name string) error {
+	var count int64
+	if err := tx.Model(&Tag{}).Where("user_id = ? AND name = ? AND id <> ?", userID, name, id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateTag
+	}
+	return nil
+}
+
+// touch records a change of the todos carrying the tag id.
+func touch(tx *gorm.DB, id uint) error {
+	var todoIDs []uint
+	if err := tx.Model(&TodoTag{}).Where("tag_id = ?", id).Pluck("todo_id", &todoIDs).Error; err != nil {
+		return err
+	}
+	return feed.Touch(tx, todoIDs...)
+}
@@ -0,0 +1,56 @@
+package tag
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTag struct {
+	mock.Mock
+}
+
+func (m *MockTag) GetTags(ctx context.Context, userID uint) ([]*Tag, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*Tag), args.Error(1)
+}
+
+func (m *MockTag) GetTagById(ctx context.Context, userID uint, id uint) (*Tag, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*Tag), args.Error(1)
+}
+
+func (m *MockTag) CreateTag(ctx context.Context, tagItem *Tag) (*Tag, error) {
+	args := m.Called(ctx, tagItem)
+	return args.Get(0).(*Tag), args.Error(1)
+}
+
+func (m *MockTag) UpdateTag(ctx context.Context, tagItem *Tag, fields map[string]interface{}) (*Tag, error) {
+	args := m.Called(ctx, tagItem, fields)
+	return args.Get(0).(*Tag), args.Error(1)
+}
+
+func (m *MockTag) DeleteTagById(ctx context.Context, userID uint, id uint) (*Tag, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*Tag), args.Error(1)
+}
+
+func (m *MockTag) AttachTag(ctx context.Context, todoID uint, tagID uint) error {
+	args := m.Called(ctx, todoID, tagID)
+	return args.Error(0)
+}
+
+func (m *MockTag) DetachTag(ctx context.Context, todoID uint, tagID uint) error {
+	args := m.Called(ctx, todoID, tagID)
+	return args.Error(0)
+}
+
+func (m *MockTag) TagsOf(ctx context.Context, userID uint, todoIDs []uint) (map[uint][]*Tag, error) {
+	args := m.Called(ctx, userID, todoIDs)
+	return args.Get(0).(map[uint][]*Tag), args.Error(1)
+}
+
+func (m *MockTag) CopyTags(ctx context.Context, fromID uint, toID uint) error {
+	args := m.Called(ctx, fromID, toID)
+	return args.Error(0)
+}
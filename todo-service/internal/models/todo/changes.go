@@ -0,0 +1,99 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/ennemli/todo/todo/internal/models/feed"
+)
+
+var (
+	ErrInvalidSyncToken = errors.New("Invalid sync token")
+	ErrSyncTokenExpired = errors.New("The sync token has expired; sync again without one")
+)
+
+// Change is a todo that changed. A todo in the trash or out of reach is a
+// tombstone, without the todo.
+type Change struct {
+	Seq     uint64 `json:"seq"`
+	ID      uint   `json:"id"`
+	Deleted bool   `json:"deleted,omitempty"`
+	ETag    string `json:"etag,omitempty"`
+	Todo    *Todo  `json:"todo,omitempty"`
+}
+
+// ChangePage is a page of the change feed. SyncToken is where the next page,
+// or the next sync once HasMore is false, starts from.
+type ChangePage struct {
+	Changes   []*Change `json:"changes"`
+	SyncToken string    `json:"sync_token"`
+	HasMore   bool      `json:"has_more"`
+}
+
+// ParseSyncToken returns the sequence number a sync token stands for; no
+// token stands for the start of the feed.
+func ParseSyncToken(raw string) (uint64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
+
+// GetChanges returns the todos userID can see that changed after since, in
+// the order they changed, with a tombstone for each one they saw that went
+// to the trash or out of their reach. Without since, it is a snapshot of
+// those todos, without tombstones. A since older than a purged tombstone,
+// or newer than the last change, has expired.
+func (s *store) GetChanges(ctx context.Context, userID uint, since uint64, limit int) (*ChangePage, error) {
+	counter := new(feed.Counter)
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(counter).Error; err != nil {
+		return nil, err
+	}
+	if since > counter.Seq || (since > 0 && since < counter.PurgedSeq) {
+		return nil, ErrSyncTokenExpired
+	}
+	q := s.db.WithContext(ctx).Where("user_id = ? AND seq > ? AND seq <= ?", userID, since, counter.Seq)
+	if since == 0 {
+		q = q.Where("deleted = ?", false)
+	}
+	entries := []*feed.Entry{}
+	if err := q.Order("seq").Limit(limit + 1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	page := &ChangePage{Changes: []*Change{}, SyncToken: strconv.FormatUint(counter.Seq, 10)}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		page.HasMore = true
+		page.SyncToken = strconv.FormatUint(entries[limit-1].Seq, 10)
+	}
+	ids := []uint{}
+	for _, entry := range entries {
+		if !entry.Deleted {
+			ids = append(ids, entry.TodoID)
+		}
+	}
+	todos := []*Todo{}
+	if len(ids) > 0 {
+		if err := withRole(s.db.WithContext(ctx).Unscoped(), userID).Where("todos.id IN ?", ids).Find(&todos).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]*Todo, len(todos))
+	for _, t := range todos {
+		byID[t.ID] = t
+	}
+	for _, entry := range entries {
+		change := &Change{Seq: entry.Seq, ID: entry.TodoID, Deleted: true}
+		if t := byID[entry.TodoID]; !entry.Deleted && t != nil && !t.DeletedAt.Valid {
+			change.Deleted = false
+			change.Todo = t
+		}
+		page.Changes = append(page.Changes, change)
+	}
+	return page, nil
+}
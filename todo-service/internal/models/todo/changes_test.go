@@ -0,0 +1,226 @@
+package todo
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB opens the Postgres database at TEST_DATABASE_DSN, or an
+// in-memory SQLite one when it is not set. The test runs in a transaction
+// that is rolled back once it is over.
+func openTestDB(T *testing.T) *gorm.DB {
+	dialector := sqlite.Open("file::memory:")
+	if dsn := os.Getenv("TEST_DATABASE_DSN"); dsn != "" {
+		dialector = postgres.Open(dsn)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	require.Nil(T, err)
+	tx := db.Begin()
+	T.Cleanup(func() { tx.Rollback() })
+	require.Nil(T, tx.AutoMigrate(&list.List{}, &list.Member{}, &list.Invitation{}, &tag.Tag{}, &tag.TodoTag{}, &item.Item{}))
+	require.Nil(T, Migrate(tx))
+	return tx
+}
+
+func TestChanges(T *testing.T) {
+	s := &store{db: openTestDB(T)}
+	ctx := context.Background()
+	owner, other := uint(1000001), uint(1000002)
+	ids := func(page *ChangePage) []uint {
+		ids := []uint{}
+		for _, change := range page.Changes {
+			ids = append(ids, change.ID)
+		}
+		return ids
+	}
+	start := changes(T, s, owner, "", 10).SyncToken
+
+	a, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	b, err := s.CreateTodo(ctx, &Todo{Name: "B", UserID: owner})
+	require.Nil(T, err)
+	created := changes(T, s, owner, start, 10)
+	assert.Equal(T, []uint{a.ID, b.ID}, ids(created))
+	assert.Less(T, created.Changes[0].Seq, created.Changes[1].Seq)
+	assert.Equal(T, strconv.FormatUint(created.Changes[1].Seq, 10), created.SyncToken)
+	assert.False(T, created.HasMore)
+
+	_, err = s.UpdateTodo(ctx, owner, a, map[string]interface{}{"name": "A2"})
+	require.Nil(T, err)
+	updated := changes(T, s, owner, created.SyncToken, 10)
+	assert.Equal(T, []uint{a.ID}, ids(updated))
+	assert.Equal(T, "A2", updated.Changes[0].Todo.Name)
+	assert.Equal(T, uint(2), updated.Changes[0].Todo.Version)
+
+	_, err = s.DeleteTodoById(ctx, owner, b.ID, DeleteOptions{})
+	require.Nil(T, err)
+	deleted := changes(T, s, owner, updated.SyncToken, 10)
+	assert.Equal(T, []uint{b.ID}, ids(deleted))
+	assert.True(T, deleted.Changes[0].Deleted)
+	assert.Nil(T, deleted.Changes[0].Todo)
+
+	assert.Equal(T, []uint{a.ID}, ids(changes(T, s, owner, "", 10)), "a snapshot has no tombstones")
+	assert.Empty(T, changes(T, s, other, start, 10).Changes)
+	assert.Empty(T, changes(T, s, owner, deleted.SyncToken, 10).Changes)
+
+	first := changes(T, s, owner, created.SyncToken, 1)
+	assert.Equal(T, []uint{a.ID}, ids(first))
+	assert.True(T, first.HasMore)
+	second := changes(T, s, owner, first.SyncToken, 1)
+	assert.Equal(T, []uint{b.ID}, ids(second))
+	assert.False(T, second.HasMore)
+
+	_, err = s.RestoreTodoById(ctx, owner, b.ID)
+	require.Nil(T, err)
+	restored := changes(T, s, owner, deleted.SyncToken, 10)
+	assert.Equal(T, []uint{b.ID}, ids(restored))
+	assert.False(T, restored.Changes[0].Deleted)
+
+	_, err = s.DeleteTodoById(ctx, owner, b.ID, DeleteOptions{})
+	require.Nil(T, err)
+	_, err = s.PurgeTodoById(ctx, owner, b.ID)
+	require.Nil(T, err)
+	since, _ := ParseSyncToken(restored.SyncToken)
+	_, err = s.GetChanges(ctx, owner, since, 10)
+	assert.Equal(T, ErrSyncTokenExpired, err, "the tombstone of b is gone")
+	_, err = s.GetChanges(ctx, owner, 0, 10)
+	assert.Nil(T, err)
+	latest := changes(T, s, owner, "", 10).SyncToken
+	assert.Empty(T, changes(T, s, owner, latest, 10).Changes)
+	since, _ = ParseSyncToken(latest)
+	_, err = s.GetChanges(ctx, owner, since+1, 10)
+	assert.Equal(T, ErrSyncTokenExpired, err, "a token from the future")
+}
+
+func TestChangesOfAccess(T *testing.T) {
+	db := openTestDB(T)
+	s := &store{db: db}
+	lists := list.NewStoreWith(db)
+	ctx := context.Background()
+	owner, member := uint(1000001), uint(1000002)
+	shared, err := lists.CreateList(ctx, &list.List{Name: "Home", CreatedBy: owner})
+	require.Nil(T, err)
+	invitation, err := lists.CreateInvitation(ctx, &list.Invitation{ListID: shared.ID, UserID: member, Role: list.RoleViewer, InvitedBy: owner})
+	require.Nil(T, err)
+	start := changes(T, s, member, "", 10).SyncToken
+
+	a, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner, ListID: &shared.ID})
+	require.Nil(T, err)
+	_, err = lists.AcceptInvitation(ctx, invitation)
+	require.Nil(T, err)
+	joined := changes(T, s, member, start, 10)
+	require.Len(T, joined.Changes, 1)
+	assert.Equal(T, list.RoleViewer, joined.Changes[0].Todo.Role)
+
+	_, err = lists.SetRole(ctx, shared.ID, member, list.RoleEditor)
+	require.Nil(T, err)
+	promoted := changes(T, s, member, joined.SyncToken, 10)
+	require.Len(T, promoted.Changes, 1)
+	assert.Equal(T, list.RoleEditor, promoted.Changes[0].Todo.Role)
+
+	require.Nil(T, lists.RemoveMember(ctx, shared.ID, member))
+	removed := changes(T, s, member, promoted.SyncToken, 10)
+	require.Len(T, removed.Changes, 1)
+	assert.Equal(T, a.ID, removed.Changes[0].ID)
+	assert.True(T, removed.Changes[0].Deleted, "a todo out of reach is a tombstone")
+
+	a, err = s.GetTodoById(ctx, owner, a.ID)
+	require.Nil(T, err)
+	_, err = s.UpdateTodo(ctx, owner, a, map[string]interface{}{"assignee_id": member})
+	require.Nil(T, err)
+	assigned := changes(T, s, member, removed.SyncToken, 10)
+	require.Len(T, assigned.Changes, 1)
+	assert.False(T, assigned.Changes[0].Deleted)
+
+	_, err = s.UpdateTodo(ctx, member, assigned.Changes[0].Todo, map[string]interface{}{"assignee_id": nil})
+	require.Nil(T, err)
+	unassigned := changes(T, s, member, assigned.SyncToken, 10)
+	require.Len(T, unassigned.Changes, 1)
+	assert.True(T, unassigned.Changes[0].Deleted)
+	assert.Empty(T, changes(T, s, member, "", 10).Changes)
+}
+
+func TestChangesOfTagsAndChecklists(T *testing.T) {
+	db := openTestDB(T)
+	s := &store{db: db}
+	tags := tag.NewStoreWith(db)
+	items := item.NewStoreWith(db)
+	ctx := context.Background()
+	owner := uint(1000001)
+	a, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	home, err := tags.CreateTag(ctx, &tag.Tag{UserID: owner, Name: "home"})
+	require.Nil(T, err)
+	since := changes(T, s, owner, "", 10).SyncToken
+	versions := func() []uint {
+		page := changes(T, s, owner, since, 10)
+		since = page.SyncToken
+		versions := []uint{}
+		for _, change := range page.Changes {
+			versions = append(versions, change.Todo.Version)
+		}
+		return versions
+	}
+
+	require.Nil(T, tags.AttachTag(ctx, a.ID, home.ID))
+	assert.Equal(T, []uint{2}, versions())
+	require.Nil(T, tags.AttachTag(ctx, a.ID, home.ID))
+	assert.Empty(T, versions(), "attaching a tag twice changes nothing")
+	_, err = tags.UpdateTag(ctx, home, map[string]interface{}{"name": "house"})
+	require.Nil(T, err)
+	assert.Equal(T, []uint{3}, versions())
+
+	step, err := items.CreateItem(ctx, &item.Item{TodoID: a.ID, Name: "step"})
+	require.Nil(T, err)
+	assert.Equal(T, []uint{4}, versions())
+	_, err = items.UpdateItem(ctx, step, map[string]interface{}{"name": "first step"})
+	require.Nil(T, err)
+	assert.Empty(T, versions(), "renaming an item leaves the progress as it was")
+	_, err = items.UpdateItem(ctx, step, map[string]interface{}{"done": true})
+	require.Nil(T, err)
+	assert.Equal(T, []uint{5}, versions())
+
+	_, err = tags.DeleteTagById(ctx, owner, home.ID)
+	require.Nil(T, err)
+	assert.Equal(T, []uint{6}, versions())
+}
+
+func changes(T *testing.T, s *store, userID uint, token string, limit int) *ChangePage {
+	since, err := ParseSyncToken(token)
+	require.Nil(T, err)
+	page, err := s.GetChanges(context.Background(), userID, since, limit)
+	require.Nil(T, err)
+	return page
+}
+
+func TestParseSyncToken(T *testing.T) {
+	testCases := []struct {
+		raw      string
+		expected uint64
+		err      error
+	}{
+		{"", 0, nil},
+		{"42", 42, nil},
+		{"-1", 0, ErrInvalidSyncToken},
+		{"abc", 0, ErrInvalidSyncToken},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.raw, func(T *testing.T) {
+			seq, err := ParseSyncToken(tc.raw)
+			assert.Equal(T, tc.expected, seq)
+			assert.Equal(T, tc.err, err)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package todo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// ExportBatchSize is how many todos ExportTodos reads at a time.
+const ExportBatchSize = 100
+
+// ExportTodos hands the todos userID can see to fn, in batches of
+// ExportBatchSize in the order of their IDs, so that they can be written as
+// they are read. It stops at the first error of fn.
+func (s *store) ExportTodos(ctx context.Context, userID uint, fn func(todos []*Todo) error) error {
+	todos := []*Todo{}
+	q := withRole(Readable(s.db.WithContext(ctx).Model(&Todo{}), userID), userID)
+	return q.FindInBatches(&todos, ExportBatchSize, func(tx *gorm.DB, batch int) error {
+		return fn(todos)
+	}).Error
+}
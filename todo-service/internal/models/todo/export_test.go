@@ -0,0 +1,64 @@
+package todo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTodos(T *testing.T) {
+	s := &store{db: openTestDB(T)}
+	ctx := context.Background()
+	owner, other := uint(1000001), uint(1000002)
+	for i := 0; i < ExportBatchSize+1; i++ {
+		_, err := s.CreateTodo(ctx, &Todo{Name: "Task", UserID: owner})
+		require.Nil(T, err)
+	}
+	_, err := s.CreateTodo(ctx, &Todo{Name: "Other", UserID: other})
+	require.Nil(T, err)
+
+	var sizes []int
+	var lastID uint
+	err = s.ExportTodos(ctx, owner, func(todos []*Todo) error {
+		sizes = append(sizes, len(todos))
+		for _, t := range todos {
+			assert.Equal(T, owner, t.UserID)
+			assert.Less(T, lastID, t.ID)
+			lastID = t.ID
+		}
+		return nil
+	})
+	require.Nil(T, err)
+	assert.Equal(T, []int{ExportBatchSize, 1}, sizes)
+
+	err = s.ExportTodos(ctx, owner, func(todos []*Todo) error { return ErrReadOnly })
+	assert.Equal(T, ErrReadOnly, err)
+}
+
+func TestExternalID(T *testing.T) {
+	s := &store{db: openTestDB(T)}
+	ctx := context.Background()
+	owner, other := uint(1000001), uint(1000002)
+	externalID := func() *string {
+		id := "task-1"
+		return &id
+	}
+
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner, ExternalID: externalID()})
+	require.Nil(T, err)
+	_, err = s.CreateTodo(ctx, &Todo{Name: "B", UserID: owner, ExternalID: externalID()})
+	assert.Equal(T, ErrExternalID, err)
+	_, err = s.CreateTodo(ctx, &Todo{Name: "B", UserID: other, ExternalID: externalID()})
+	assert.Nil(T, err, "external ids are unique per user")
+	_, err = s.CreateTodo(ctx, &Todo{Name: "C", UserID: owner})
+	assert.Nil(T, err)
+	_, err = s.CreateTodo(ctx, &Todo{Name: "D", UserID: owner})
+	assert.Nil(T, err, "todos without an external id are never duplicates")
+
+	_, err = s.DeleteTodoById(ctx, owner, created.ID, DeleteOptions{})
+	require.Nil(T, err)
+	_, err = s.CreateTodo(ctx, &Todo{Name: "B", UserID: owner, ExternalID: externalID()})
+	assert.Equal(T, ErrExternalID, err, "a todo in the trash still has its external id")
+}
@@ -0,0 +1,372 @@
+package todo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// noDueDate stands in for a missing due date so that todos without one sort
+// after every todo that has one.
+var noDueDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type sortField struct {
+	column string
+	value  func(t *Todo) interface{}
+	parse  func(raw json.RawMessage) (interface{}, error)
+}
+
+func parseTime(raw json.RawMessage) (interface{}, error) {
+	var t time.Time
+	err := json.Unmarshal(raw, &t)
+	return t, err
+}
+
+func parseInt(raw json.RawMessage) (interface{}, error) {
+	var i int
+	err := json.Unmarshal(raw, &i)
+	return i, err
+}
+
+func parseFloat(raw json.RawMessage) (interface{}, error) {
+	var f float64
+	err := json.Unmarshal(raw, &f)
+	return f, err
+}
+
+func parseString(raw json.RawMessage) (interface{}, error) {
+	var s string
+	err := json.Unmarshal(raw, &s)
+	return s, err
+}
+
+// sortFields are the fields lists can be sorted by. Ties are broken by id.
+var sortFields = map[string]sortField{
+	"id":         {"id", func(t *Todo) interface{} { return t.ID }, parseInt},
+	"created_at": {"created_at", func(t *Todo) interface{} { return t.CreatedAt }, parseTime},
+	"updated_at": {"updated_at", func(t *Todo) interface{} { return t.UpdatedAt }, parseTime},
+	"date":       {"date", func(t *Todo) interface{} { return t.Date }, parseTime},
+	"due_at": {"COALESCE(due_at, ?)", func(t *Todo) interface{} {
+		if t.DueAt == nil {
+			return noDueDate
+		}
+		return *t.DueAt
+	}, parseTime},
+	"priority": {"priority", func(t *Todo) interface{} { return t.Priority }, parseInt},
+	"name":     {"name", func(t *Todo) interface{} { return t.Name }, parseString},
+	"status":   {"status", func(t *Todo) interface{} { return t.Status }, parseString},
+}
+
+// ListOptions filters, sorts and pages a list of todos. Time ranges include
+// their From bound and exclude their Before bound. Todos match Tags when
+// they carry any of them, or all of them with AllTags. A ListID of 0
+// matches the todos outside lists, and an AssigneeID of 0 the unassigned
+// todos. AssignedToMe matches the todos assigned to the user listing them.
+type ListOptions struct {
+	Status       []string
+	Priority     []int
+	Tags         []string
+	AllTags      bool
+	ListID       *uint
+	AssigneeID   *uint
+	AssignedToMe bool
+	DateFrom     *time.Time
+	DateBefore   *time.Time
+	DueFrom      *time.Time
+	DueBefore    *time.Time
+	Sort         string
+	Desc         bool
+	Limit        int
+	WithTotal    bool
+	after        *cursor
+	afterValue   interface{}
+}
+
+// Page is one page of a list. NextCursor is empty on the last page.
+type Page struct {
+	Items      []*Todo `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      *int64  `json:"total,omitempty"`
+}
+
+type cursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d,omitempty"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// ParseListOptions reads the list query parameters: status, priority, tag,
+// tag_mode, list, assignee, date_from, date_to, due_from, due_to, sort,
+// limit, cursor and total.
+func ParseListOptions(values url.Values, maxPageSize int) (*ListOptions, error) {
+	opts, err := parseListOptions(values, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if sort := values.Get("sort"); sort != "" {
+		opts.Desc = strings.HasPrefix(sort, "-")
+		opts.Sort = strings.TrimPrefix(sort, "-")
+		if _, ok := sortFields[opts.Sort]; !ok {
+			return nil, fmt.Errorf("Cannot sort by %q", opts.Sort)
+		}
+	}
+	if err := opts.parseCursor(values.Get("cursor"), sortFields[opts.Sort].parse); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// CursorAfter returns the cursor of the page that starts after t.
+func (o *ListOptions) CursorAfter(t *Todo) string {
+	return encodeCursor(o.Sort, o.Desc, sortFields[o.Sort].value(t), t.ID)
+}
+
+// parseListOptions reads the parameters shared by every list: the filters,
+// limit and total.
+func parseListOptions(values url.Values, maxPageSize int) (*ListOptions, error) {
+	if maxPageSize <= 0 {
+		maxPageSize = MaxPageSize
+	}
+	opts := &ListOptions{Sort: "id", Limit: min(DefaultPageSize, maxPageSize)}
+	for _, status := range splitList(values["status"]) {
+		if !ValidStatus(status) {
+			return nil, fmt.Errorf("Invalid status %q", status)
+		}
+		opts.Status = append(opts.Status, status)
+	}
+	for _, raw := range splitList(values["priority"]) {
+		priority, err := strconv.Atoi(raw)
+		if err != nil || !ValidPriority(priority) {
+			return nil, fmt.Errorf("Invalid priority %q", raw)
+		}
+		opts.Priority = append(opts.Priority, priority)
+	}
+	opts.Tags = splitList(values["tag"])
+	switch mode := values.Get("tag_mode"); mode {
+	case "", "any":
+	case "all":
+		opts.AllTags = true
+	default:
+		return nil, fmt.Errorf("Invalid tag_mode %q", mode)
+	}
+	if raw := values.Get("list"); raw != "" {
+		listID := uint(0)
+		if raw != "none" {
+			id, err := strconv.ParseUint(raw, 10, 0)
+			if err != nil || id == 0 {
+				return nil, fmt.Errorf("Invalid list %q", raw)
+			}
+			listID = uint(id)
+		}
+		opts.ListID = &listID
+	}
+	switch raw := values.Get("assignee"); raw {
+	case "":
+	case "me":
+		opts.AssignedToMe = true
+	default:
+		assigneeID := uint(0)
+		if raw != "none" {
+			id, err := strconv.ParseUint(raw, 10, 0)
+			if err != nil || id == 0 {
+				return nil, fmt.Errorf("Invalid assignee %q", raw)
+			}
+			assigneeID = uint(id)
+		}
+		opts.AssigneeID = &assigneeID
+	}
+	var err error
+	if opts.DateFrom, err = parseBound(values, "date_from", false); err != nil {
+		return nil, err
+	}
+	if opts.DateBefore, err = parseBound(values, "date_to", true); err != nil {
+		return nil, err
+	}
+	if opts.DueFrom, err = parseBound(values, "due_from", false); err != nil {
+		return nil, err
+	}
+	if opts.DueBefore, err = parseBound(values, "due_to", true); err != nil {
+		return nil, err
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		opts.Limit = limit
+	}
+	if raw := values.Get("total"); raw != "" {
+		if opts.WithTotal, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("Invalid total %q", raw)
+		}
+	}
+	return opts, nil
+}
+
+// parseCursor decodes raw, which must have been made for the same sort.
+func (o *ListOptions) parseCursor(raw string, parse func(json.RawMessage) (interface{}, error)) error {
+	if raw == "" {
+		return nil
+	}
+	c, err := decodeCursor(raw)
+	if err != nil {
+		return err
+	}
+	if c.Sort != o.Sort || c.Desc != o.Desc {
+		return ErrInvalidCursor
+	}
+	if o.afterValue, err = parse(c.Value); err != nil {
+		return ErrInvalidCursor
+	}
+	o.after = c
+	return nil
+}
+
+func (o *ListOptions) filter(q *gorm.DB, userID uint) *gorm.DB {
+	if len(o.Status) > 0 {
+		q = q.Where("status IN ?", o.Status)
+	}
+	if len(o.Priority) > 0 {
+		q = q.Where("priority IN ?", o.Priority)
+	}
+	if len(o.Tags) > 0 {
+		tagged := q.Session(&gorm.Session{NewDB: true}).Table("todo_tags").
+			Select("todo_tags.todo_id").
+			Joins("JOIN tags ON tags.id = todo_tags.tag_id").
+			Where("tags.user_id = ? AND tags.name IN ?", userID, o.Tags)
+		if o.AllTags {
+			tagged = tagged.Group("todo_tags.todo_id").Having("COUNT(DISTINCT tags.name) = ?", len(uniqueStrings(o.Tags)))
+		}
+		q = q.Where("id IN (?)", tagged)
+	}
+	if o.ListID != nil && *o.ListID == 0 {
+		q = q.Where("todos.list_id IS NULL")
+	} else if o.ListID != nil {
+		q = q.Where("todos.list_id = ?", *o.ListID)
+	}
+	if o.AssignedToMe {
+		q = q.Where("todos.assignee_id = ?", userID)
+	} else if o.AssigneeID != nil && *o.AssigneeID == 0 {
+		q = q.Where("todos.assignee_id IS NULL")
+	} else if o.AssigneeID != nil {
+		q = q.Where("todos.assignee_id = ?", *o.AssigneeID)
+	}
+	if o.DateFrom != nil {
+		q = q.Where("date >= ?", *o.DateFrom)
+	}
+	if o.DateBefore != nil {
+		q = q.Where("date < ?", *o.DateBefore)
+	}
+	if o.DueFrom != nil {
+		q = q.Where("due_at >= ?", *o.DueFrom)
+	}
+	if o.DueBefore != nil {
+		q = q.Where("due_at < ?", *o.DueBefore)
+	}
+	return q
+}
+
+func (o *ListOptions) paginate(q *gorm.DB) *gorm.DB {
+	field := sortFields[o.Sort]
+	var columnVars []interface{}
+	if strings.Contains(field.column, "?") {
+		columnVars = []interface{}{noDueDate}
+	}
+	dir, cmp := "ASC", ">"
+	if o.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if o.after != nil {
+		sql := fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", field.column, cmp)
+		var vars []interface{}
+		vars = append(vars, columnVars...)
+		vars = append(vars, o.afterValue)
+		vars = append(vars, columnVars...)
+		vars = append(vars, o.afterValue, o.after.ID)
+		q = q.Where(sql, vars...)
+	}
+	order := fmt.Sprintf("%s %s, id %s", field.column, dir, dir)
+	if o.Sort == "id" {
+		order = "id " + dir
+	}
+	q = q.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: order, Vars: columnVars, WithoutParentheses: true}})
+	return q.Limit(o.Limit + 1)
+}
+
+func encodeCursor(sort string, desc bool, value interface{}, id uint) string {
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(cursor{Sort: sort, Desc: desc, Value: raw, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := new(cursor)
+	if err := json.Unmarshal(data, c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func uniqueStrings(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseBound reads an RFC 3339 timestamp or a YYYY-MM-DD date. An upper
+// bound is returned as the first instant after it, so a date includes the
+// whole day.
+func parseBound(values url.Values, name string, upper bool) (*time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		if upper {
+			t = t.Add(time.Nanosecond)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", name)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
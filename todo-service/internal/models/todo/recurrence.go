@@ -0,0 +1,110 @@
+package todo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/ennemli/todo/todo/pkg/rrule"
+	"gorm.io/gorm"
+)
+
+const MaxOccurrences = 100
+
+// NormalizeRecurrence validates an RRULE and returns it in canonical form.
+func NormalizeRecurrence(value string) (string, error) {
+	rule, err := rrule.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return rule.String(), nil
+}
+
+// current returns when the occurrence t stands for is due.
+func (t *Todo) current() time.Time {
+	if t.DueAt != nil {
+		return *t.DueAt
+	}
+	return *t.RecurrenceStart
+}
+
+// Upcoming returns up to n occurrences of t's series after the current one.
+func (t *Todo) Upcoming(n int) ([]time.Time, error) {
+	occurrences := []time.Time{}
+	if t.Recurrence == "" || t.RecurrenceStart == nil {
+		return occurrences, nil
+	}
+	rule, err := rrule.Parse(t.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	it := rule.IteratorAfter(*t.RecurrenceStart, t.current())
+	for len(occurrences) < n {
+		next, ok := it.Next()
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, next)
+	}
+	return occurrences, nil
+}
+
+// NextOccurrence returns a new open todo for the occurrence following t, or
+// nil when t does not recur or its series is over. The next todo takes over
+// the rule, and its date moves along with the due date.
+func (t *Todo) NextOccurrence() (*Todo, error) {
+	upcoming, err := t.Upcoming(1)
+	if err != nil || len(upcoming) == 0 {
+		return nil, err
+	}
+	dueAt := upcoming[0].UTC()
+	start := *t.RecurrenceStart
+	next := &Todo{
+		Name:            t.Name,
+		Description:     t.Description,
+		UserID:          t.UserID,
+		Status:          StatusOpen,
+		Priority:        t.Priority,
+		DueAt:           &dueAt,
+		Recurrence:      t.Recurrence,
+		RecurrenceStart: &start,
+		ListID:          t.ListID,
+		AssigneeID:      t.AssigneeID,
+	}
+	if !t.Date.IsZero() {
+		next.Date = t.Date.Add(dueAt.Sub(t.current()))
+	}
+	return next, nil
+}
+
+// CompleteOccurrence applies fields to todoItem and creates next in one
+// transaction. next gets copies of todoItem's tags and of its checklist,
+// unchecked, and todoItem hands its rule over to it.
+func (s *store) CompleteOccurrence(ctx context.Context, userID uint, todoItem *Todo, fields map[string]interface{}, next *Todo) (*Todo, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fields["recurrence"] = ""
+		if err := update(tx, userID, todoItem, fields); err != nil {
+			return err
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		if err := feed.Record(tx, next.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(todoItem).UpdateColumn("next_id", next.ID).Error; err != nil {
+			return err
+		}
+		todoItem.NextID = &next.ID
+		if err := tag.NewStoreWith(tx).CopyTags(ctx, todoItem.ID, next.ID); err != nil {
+			return err
+		}
+		return item.NewStoreWith(tx).CopyItems(ctx, todoItem.ID, next.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todoItem, nil
+}
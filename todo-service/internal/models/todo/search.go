@@ -0,0 +1,118 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// searchConfig is the text search configuration of the search_vector column.
+const searchConfig = "english"
+
+const maxQueryLength = 256
+
+var (
+	rankSQL     = fmt.Sprintf("ts_rank(search_vector, websearch_to_tsquery('%s', ?))::float8", searchConfig)
+	snippetSQL  = fmt.Sprintf("ts_headline('%s', concat_ws(' ', name, description), websearch_to_tsquery('%s', ?), 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')", searchConfig, searchConfig)
+	matchSQL    = fmt.Sprintf("search_vector @@ websearch_to_tsquery('%s', ?)", searchConfig)
+	searchIndex = []string{
+		fmt.Sprintf(`ALTER TABLE todos ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('%[1]s', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('%[1]s', coalesce(description, '')), 'B')
+		) STORED`, searchConfig),
+		"CREATE INDEX IF NOT EXISTS idx_todos_search_vector ON todos USING GIN (search_vector)",
+	}
+)
+
+// SearchOptions is a full-text query over the names and descriptions of
+// todos, with the filters and paging of a list. Results are sorted by rank.
+type SearchOptions struct {
+	ListOptions
+	Query string
+}
+
+// SearchResult is a matching todo with its rank and a snippet of its text
+// in which the matching words are wrapped in <mark> tags.
+type SearchResult struct {
+	Todo
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type SearchPage struct {
+	Items      []*SearchResult `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Total      *int64          `json:"total,omitempty"`
+}
+
+// ParseSearchOptions reads q and the list query parameters other than sort.
+func ParseSearchOptions(values url.Values, maxPageSize int) (*SearchOptions, error) {
+	query := strings.TrimSpace(values.Get("q"))
+	if query == "" {
+		return nil, errors.New("q is required")
+	}
+	if len(query) > maxQueryLength {
+		return nil, fmt.Errorf("q must be at most %d characters", maxQueryLength)
+	}
+	if values.Get("sort") != "" {
+		return nil, errors.New("Search results are sorted by rank")
+	}
+	opts, err := parseListOptions(values, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	opts.Sort, opts.Desc = "rank", true
+	if err := opts.parseCursor(values.Get("cursor"), parseFloat); err != nil {
+		return nil, err
+	}
+	return &SearchOptions{ListOptions: *opts, Query: query}, nil
+}
+
+// CursorAfter returns the cursor of the page that starts after result.
+func (o *SearchOptions) CursorAfter(result *SearchResult) string {
+	return encodeCursor(o.Sort, o.Desc, result.Rank, result.ID)
+}
+
+func (s *store) SearchTodos(ctx context.Context, userID uint, opts *SearchOptions) (*SearchPage, error) {
+	q := Readable(s.db.WithContext(ctx).Model(&Todo{}), userID).Where(matchSQL, opts.Query)
+	q = opts.filter(q, userID)
+	page := &SearchPage{Items: []*SearchResult{}}
+	if opts.WithTotal {
+		var total int64
+		if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+	q = q.Session(&gorm.Session{}).Select("todos.*, "+rankSQL+" AS rank, "+snippetSQL+" AS snippet, "+roleSQL, append([]interface{}{opts.Query, opts.Query}, roleVars(userID)...)...)
+	if opts.after != nil {
+		q = q.Where(fmt.Sprintf("(%[1]s < ?) OR (%[1]s = ? AND id < ?)", rankSQL),
+			opts.Query, opts.afterValue, opts.Query, opts.afterValue, opts.after.ID)
+	}
+	if err := q.Order("rank DESC, id DESC").Limit(opts.Limit + 1).Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+	if len(page.Items) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		page.NextCursor = opts.CursorAfter(page.Items[opts.Limit-1])
+	}
+	return page, nil
+}
+
+// migrateSearch adds the search index. Only Postgres has one: on other
+// databases, todos cannot be searched.
+func migrateSearch(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, sql := range searchIndex {
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
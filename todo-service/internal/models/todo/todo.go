@@ -2,27 +2,249 @@ package todo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ennemli/todo/todo/internal/db"
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	StatusOpen       = "open"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+	StatusArchived   = "archived"
+)
+
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// RoleAssignee is the role on a todo of a user who can only see it because
+// it is assigned to them.
+const RoleAssignee = "assignee"
+
+var (
+	ErrReadOnly     = errors.New("Viewers cannot change the todos of a list")
+	ErrListNotFound = errors.New("List not found")
+	ErrAssignee     = errors.New("Assignees cannot delete, move or reassign a todo")
+	ErrStale        = errors.New("The todo was changed by someone else")
+	ErrExternalID   = errors.New("A todo with this external id already exists")
+)
+
+// DeleteOptions say how DeleteTodoById deletes a todo. Cascade deletes its
+// checklist along with it. A Version other than 0 only deletes the todo at
+// that version, failing with ErrStale otherwise.
+type DeleteOptions struct {
+	Cascade bool
+	Version uint
+}
+
+// ItemsError is returned when a todo with a checklist is deleted without
+// cascading.
+type ItemsError struct {
+	Count int64
+}
+
+func (e *ItemsError) Error() string {
+	return fmt.Sprintf("The todo has %d checklist items", e.Count)
+}
+
+// transitions lists the statuses each status can move to. Archived todos
+// have to be reopened before they can be worked on again.
+var transitions = map[string][]string{
+	StatusOpen:       {StatusInProgress, StatusDone, StatusArchived},
+	StatusInProgress: {StatusOpen, StatusDone, StatusArchived},
+	StatusDone:       {StatusOpen, StatusInProgress, StatusArchived},
+	StatusArchived:   {StatusOpen},
+}
+
+func ValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+func ValidPriority(priority int) bool {
+	return priority >= PriorityNone && priority <= PriorityHigh
+}
+
+// CanTransition reports whether a todo in status from may be moved to
+// status to. Staying in the same status is always allowed.
+func CanTransition(from string, to string) bool {
+	if from == "" {
+		from = StatusOpen
+	}
+	if from == to {
+		return ValidStatus(to)
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Store interface {
 	CreateTodo(ctx context.Context, todoItem *Todo) (*Todo, error)
-	GetTodos(ctx context.Context) ([]*Todo, error)
-	GetTodoById(ctx context.Context, id uint) (*Todo, error)
-	DeleteTodoById(ctx context.Context, id uint) (*Todo, error)
-	UpdateTodo(ctx context.Context, todoItem *Todo, fields map[string]interface{}) (*Todo, error)
+	GetTodos(ctx context.Context, userID uint, opts *ListOptions) (*Page, error)
+	SearchTodos(ctx context.Context, userID uint, opts *SearchOptions) (*SearchPage, error)
+	GetTodoById(ctx context.Context, userID uint, id uint) (*Todo, error)
+	DeleteTodoById(ctx context.Context, userID uint, id uint, opts DeleteOptions) (*Todo, error)
+	UpdateTodo(ctx context.Context, userID uint, todoItem *Todo, fields map[string]interface{}) (*Todo, error)
+	CompleteOccurrence(ctx context.Context, userID uint, todoItem *Todo, fields map[string]interface{}, next *Todo) (*Todo, error)
+	GetTrash(ctx context.Context, userID uint) ([]*Todo, error)
+	RestoreTodoById(ctx context.Context, userID uint, id uint) (*Todo, error)
+	PurgeTodoById(ctx context.Context, userID uint, id uint) (*Todo, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+	PurgeUsers(ctx context.Context, before time.Time) (int64, error)
+	GetChanges(ctx context.Context, userID uint, since uint64, limit int) (*ChangePage, error)
+	ExportTodos(ctx context.Context, userID uint, fn func(todos []*Todo) error) error
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+	Decorate(ctx context.Context, userID uint, todos ...*Todo) error
 }
 
-// Todo model
+// Todo model. A todo belongs to the user who created it or, when ListID is
+// set, to the members of that list. It can also be assigned to the user
+// AssigneeID. Role is the role of the user who asked for the todo, owner for
+// todos outside lists. A recurring todo carries an RRULE in Recurrence whose
+// series starts at RecurrenceStart; completing it creates the next
+// occurrence, whose ID is recorded in NextID. ExternalID is the ID another
+// tool gave the todo, unique among the todos of its creator. Version goes up
+// with every update, of its tags and checklist included.
 type Todo struct {
 	gorm.Model
-	Date        time.Time `json:"date,omitempty"`
-	Name        string    `json:"name" gorm:"index,not null"`
-	Description string    `json:"description,omitempty"`
-	UserID      uint      `json:"userid" gorm:"index,not null"`
+	Date            time.Time  `json:"date,omitempty"`
+	Name            string     `json:"name" gorm:"index,not null"`
+	Description     string     `json:"description,omitempty"`
+	UserID          uint       `json:"userid" gorm:"index,not null;uniqueIndex:idx_todos_user_external"`
+	Status          string     `json:"status" gorm:"index;not null;default:open"`
+	Priority        int        `json:"priority" gorm:"not null;default:0"`
+	DueAt           *time.Time `json:"due_at,omitempty" gorm:"index"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	Recurrence      string     `json:"recurrence,omitempty"`
+	RecurrenceStart *time.Time `json:"recurrence_start,omitempty"`
+	NextID          *uint      `json:"next_id,omitempty"`
+	ListID          *uint      `json:"list_id,omitempty" gorm:"index"`
+	AssigneeID      *uint      `json:"assignee_id,omitempty" gorm:"index"`
+	Role            string     `json:"role,omitempty" gorm:"column:access_role;->;-:migration"`
+	Progress        *int       `json:"progress,omitempty" gorm:"-"`
+	Tags            []*tag.Tag `json:"tags,omitempty" gorm:"-"`
+	ExternalID      *string    `json:"external_id,omitempty" gorm:"uniqueIndex:idx_todos_user_external"`
+	Version         uint       `json:"version" gorm:"not null;default:1"`
+}
+
+// CanEdit reports whether the user the todo was loaded for may change it.
+func (t *Todo) CanEdit() bool {
+	return t.Role == "" || t.Role == RoleAssignee || list.CanEdit(t.Role)
+}
+
+// roleSQL picks the role of a user on a todo: the one they have on its list
+// when it lets them change it, then assignee, then viewer.
+const roleSQL = `CASE WHEN todos.list_id IS NULL AND todos.user_id = ? THEN ? ELSE COALESCE(
+	(SELECT role FROM list_members WHERE list_members.list_id = todos.list_id AND list_members.user_id = ? AND role IN ?),
+	CASE WHEN todos.assignee_id = ? THEN ? END, ?) END AS access_role`
+
+func roleVars(userID uint) []interface{} {
+	return []interface{}{userID, list.RoleOwner, userID, []string{list.RoleOwner, list.RoleEditor}, userID, RoleAssignee, list.RoleViewer}
+}
+
+// Readable limits q, a query on todos, to the todos userID can see: those
+// they created outside lists, those of the lists they are a member of and
+// those assigned to them.
+func Readable(q *gorm.DB, userID uint) *gorm.DB {
+	return access(q, userID, true, list.RoleOwner, list.RoleEditor, list.RoleViewer)
+}
+
+// writable is Readable without the lists where userID is a viewer, unless
+// the todo is assigned to them.
+func writable(q *gorm.DB, userID uint) *gorm.DB {
+	return access(q, userID, true, list.RoleOwner, list.RoleEditor)
+}
+
+// owned is writable without the todos userID could only change because they
+// are assigned to them.
+func owned(q *gorm.DB, userID uint) *gorm.DB {
+	return access(q, userID, false, list.RoleOwner, list.RoleEditor)
+}
+
+func access(q *gorm.DB, userID uint, assigned bool, roles ...string) *gorm.DB {
+	sql := "(todos.list_id IS NULL AND todos.user_id = ?) OR todos.list_id IN (SELECT list_id FROM list_members WHERE user_id = ? AND role IN ?)"
+	vars := []interface{}{userID, userID, roles}
+	if assigned {
+		sql += " OR todos.assignee_id = ?"
+		vars = append(vars, userID)
+	}
+	return q.Where("("+sql+")", vars...)
+}
+
+// withRole selects the todos of q along with the role userID has on them.
+func withRole(q *gorm.DB, userID uint) *gorm.DB {
+	return q.Select("todos.*, "+roleSQL, roleVars(userID)...)
+}
+
+// denied tells why userID could not change the todo id: it is either not
+// theirs to see, in a list where they are a viewer or, when the change needs
+// more than writable, only assigned to them.
+func denied(tx *gorm.DB, userID uint, id uint) error {
+	var count int64
+	if err := writable(tx.Model(&Todo{}), userID).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAssignee
+	}
+	if err := Readable(tx.Model(&Todo{}), userID).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReadOnly
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// checkList returns the role of userID in the list listID, failing unless
+// it lets them add todos to it.
+func checkList(tx *gorm.DB, userID uint, listID uint) (string, error) {
+	member := new(list.Member)
+	err := tx.Where("list_id = ? AND user_id = ?", listID, userID).First(member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrListNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !list.CanEdit(member.Role) {
+		return "", ErrReadOnly
+	}
+	return member.Role, nil
+}
+
+// Migrate creates or updates the todos table, its search index and its
+// change feed. Rows written before statuses existed are open with no
+// priority.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Todo{}); err != nil {
+		return err
+	}
+	if err := migrateSearch(db); err != nil {
+		return err
+	}
+	if err := feed.Migrate(db); err != nil {
+		return err
+	}
+	return db.Model(&Todo{}).
+		Where("status IS NULL OR status = ''").
+		Updates(map[string]interface{}{"status": StatusOpen, "priority": PriorityNone}).Error
 }
 
 type store struct {
@@ -35,40 +257,206 @@ func NewStore() Store {
 	}
 }
 
+// Transaction runs fn with a store whose operations all happen in one
+// database transaction, committed when fn returns nil. Transactions started
+// from that store are savepoints: when they fail, only their own operations
+// are rolled back.
+func (s *store) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&store{db: tx})
+	})
+}
+
+// Decorate sets the checklist progress and the tags userID put on todos,
+// read in the store's own transaction when it has one.
+func (s *store) Decorate(ctx context.Context, userID uint, todos ...*Todo) error {
+	return Decorate(ctx, item.NewStoreWith(s.db), tag.NewStoreWith(s.db), userID, todos...)
+}
+
+// Decorate sets the checklist progress and the tags userID put on todos,
+// read from items and tags. Tags are private: the members of a list do not
+// see each other's tags on its todos.
+func Decorate(ctx context.Context, items item.Store, tags tag.Store, userID uint, todos ...*Todo) error {
+	ids := make([]uint, len(todos))
+	for i, t := range todos {
+		ids[i] = t.ID
+	}
+	progress, err := items.Progress(ctx, ids)
+	if err != nil {
+		return err
+	}
+	tagsOf, err := tags.TagsOf(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+	for _, t := range todos {
+		if percent, ok := progress[t.ID]; ok {
+			t.Progress = &percent
+		}
+		t.Tags = tagsOf[t.ID]
+	}
+	return nil
+}
+
+// CreateTodo needs the creator to be an owner or editor of the todo's list,
+// and fails with ErrExternalID when they already created a todo, in the
+// trash or not, with the same ExternalID.
 func (s *store) CreateTodo(ctx context.Context, todoItem *Todo) (*Todo, error) {
-	if err := s.db.WithContext(ctx).Create(todoItem).Error; err != nil {
+	if todoItem.Status == "" {
+		todoItem.Status = StatusOpen
+	}
+	role := list.RoleOwner
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if todoItem.ListID != nil {
+			var err error
+			if role, err = checkList(tx, todoItem.UserID, *todoItem.ListID); err != nil {
+				return err
+			}
+		}
+		created := tx
+		if todoItem.ExternalID != nil {
+			created = created.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "external_id"}},
+				DoNothing: true,
+			})
+		}
+		result := created.Create(todoItem)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrExternalID
+		}
+		return feed.Record(tx, todoItem.ID)
+	})
+	if err != nil {
 		return nil, err
 	}
+	todoItem.Role = role
 	return todoItem, nil
 }
 
-func (s *store) GetTodos(ctx context.Context) ([]*Todo, error) {
-	todoItems := []*Todo{}
-	if err := s.db.WithContext(ctx).Model(&Todo{}).Find(&todoItems).Error; err != nil {
+func (s *store) GetTodos(ctx context.Context, userID uint, opts *ListOptions) (*Page, error) {
+	q := opts.filter(Readable(s.db.WithContext(ctx).Model(&Todo{}), userID), userID)
+	page := &Page{Items: []*Todo{}}
+	if opts.WithTotal {
+		var total int64
+		if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+	if err := withRole(opts.paginate(q.Session(&gorm.Session{})), userID).Find(&page.Items).Error; err != nil {
 		return nil, err
 	}
-	return todoItems, nil
+	if len(page.Items) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		page.NextCursor = opts.CursorAfter(page.Items[opts.Limit-1])
+	}
+	return page, nil
 }
 
-func (s *store) GetTodoById(ctx context.Context, id uint) (*Todo, error) {
+func (s *store) GetTodoById(ctx context.Context, userID uint, id uint) (*Todo, error) {
 	todoItem := new(Todo)
-	if err := s.db.WithContext(ctx).First(todoItem, id).Error; err != nil {
+	if err := withRole(Readable(s.db.WithContext(ctx), userID), userID).First(todoItem, id).Error; err != nil {
 		return nil, err
 	}
 	return todoItem, nil
 }
 
-func (s *store) DeleteTodoById(ctx context.Context, id uint) (*Todo, error) {
+// DeleteTodoById moves a todo to the trash and returns it. A todo with a
+// checklist is only deleted with opts.Cascade, which moves its items to the
+// trash in the same transaction; otherwise it fails with an *ItemsError.
+func (s *store) DeleteTodoById(ctx context.Context, userID uint, id uint, opts DeleteOptions) (*Todo, error) {
 	todoItem := new(Todo)
-	if err := s.db.WithContext(ctx).Delete(todoItem, id).Error; err != nil {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := withRole(owned(tx, userID), userID).Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "todos"}}).
+			First(todoItem, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return denied(tx, userID, id)
+		}
+		if err != nil {
+			return err
+		}
+		items := item.NewStoreWith(tx)
+		count, err := items.CountItems(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 && !opts.Cascade {
+			return &ItemsError{Count: count}
+		}
+		deleted := tx
+		if opts.Version != 0 {
+			deleted = deleted.Where("version = ?", opts.Version)
+		}
+		result := deleted.Delete(todoItem)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStale
+		}
+		if count > 0 {
+			if err := items.DeleteItems(ctx, id); err != nil {
+				return err
+			}
+		}
+		return feed.Record(tx, id)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return todoItem, nil
 }
 
-func (s *store) UpdateTodo(ctx context.Context, todoItem *Todo, fields map[string]interface{}) (*Todo, error) {
-	if err := s.db.WithContext(ctx).Model(todoItem).Updates(fields).Error; err != nil {
+func (s *store) UpdateTodo(ctx context.Context, userID uint, todoItem *Todo, fields map[string]interface{}) (*Todo, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return update(tx, userID, todoItem, fields)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return todoItem, nil
 }
+
+// update applies fields to todoItem when userID may change it. Moving a
+// todo into a list needs the same rights on that list; moving it out of
+// every list makes it a todo of userID. Neither can be done by an assignee,
+// nor can assigning the todo, but they can unassign themselves. The update
+// only applies to the version of todoItem, failing with ErrStale when it was
+// changed since it was read. todoItem is then reloaded, so that it reads as
+// it was stored.
+func update(tx *gorm.DB, userID uint, todoItem *Todo, fields map[string]interface{}) error {
+	allowed := writable
+	if value, ok := fields["list_id"]; ok {
+		allowed = owned
+		if value == nil {
+			fields["user_id"] = userID
+		} else if _, err := checkList(tx, userID, value.(uint)); err != nil {
+			return err
+		}
+	}
+	if value, ok := fields["assignee_id"]; ok && value != nil {
+		allowed = owned
+	}
+	err := allowed(tx.Model(&Todo{}), userID).Select("id").First(new(Todo), todoItem.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return denied(tx, userID, todoItem.ID)
+	}
+	if err != nil {
+		return err
+	}
+	fields["version"] = gorm.Expr("version + 1")
+	result := tx.Model(todoItem).Where("version = ?", todoItem.Version).Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStale
+	}
+	if err := feed.Record(tx, todoItem.ID); err != nil {
+		return err
+	}
+	return withRole(tx, userID).First(todoItem, todoItem.ID).Error
+}
@@ -2,6 +2,7 @@ package todo
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -15,22 +16,85 @@ func (m *MockTodo) CreateTodo(ctx context.Context, todoItem *Todo) (*Todo, error
 	return args.Get(0).(*Todo), args.Error(1)
 }
 
-func (m *MockTodo) GetTodos(ctx context.Context) ([]*Todo, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*Todo), args.Error(1)
+func (m *MockTodo) GetTodos(ctx context.Context, userID uint, opts *ListOptions) (*Page, error) {
+	args := m.Called(ctx, userID, opts)
+	return args.Get(0).(*Page), args.Error(1)
+}
+
+func (m *MockTodo) SearchTodos(ctx context.Context, userID uint, opts *SearchOptions) (*SearchPage, error) {
+	args := m.Called(ctx, userID, opts)
+	return args.Get(0).(*SearchPage), args.Error(1)
+}
+
+func (m *MockTodo) GetTodoById(ctx context.Context, userID uint, id uint) (*Todo, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*Todo), args.Error(1)
+}
+
+func (m *MockTodo) DeleteTodoById(ctx context.Context, userID uint, id uint, opts DeleteOptions) (*Todo, error) {
+	args := m.Called(ctx, userID, id, opts)
+	return args.Get(0).(*Todo), args.Error(1)
 }
 
-func (m *MockTodo) GetTodoById(ctx context.Context, id uint) (*Todo, error) {
-	args := m.Called(ctx, id)
+func (m *MockTodo) UpdateTodo(ctx context.Context, userID uint, todoItem *Todo, fields map[string]interface{}) (*Todo, error) {
+	args := m.Called(ctx, userID, todoItem, fields)
 	return args.Get(0).(*Todo), args.Error(1)
 }
 
-func (m *MockTodo) DeleteTodoById(ctx context.Context, id uint) (*Todo, error) {
-	args := m.Called(ctx, id)
+func (m *MockTodo) CompleteOccurrence(ctx context.Context, userID uint, todoItem *Todo, fields map[string]interface{}, next *Todo) (*Todo, error) {
+	args := m.Called(ctx, userID, todoItem, fields, next)
 	return args.Get(0).(*Todo), args.Error(1)
 }
 
-func (m *MockTodo) UpdateTodo(ctx context.Context, todoItem *Todo, fields map[string]interface{}) (*Todo, error) {
-	args := m.Called(ctx, todoItem, fields)
+func (m *MockTodo) GetTrash(ctx context.Context, userID uint) ([]*Todo, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*Todo), args.Error(1)
+}
+
+func (m *MockTodo) RestoreTodoById(ctx context.Context, userID uint, id uint) (*Todo, error) {
+	args := m.Called(ctx, userID, id)
 	return args.Get(0).(*Todo), args.Error(1)
 }
+
+func (m *MockTodo) PurgeTodoById(ctx context.Context, userID uint, id uint) (*Todo, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*Todo), args.Error(1)
+}
+
+func (m *MockTodo) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTodo) PurgeUsers(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTodo) GetChanges(ctx context.Context, userID uint, since uint64, limit int) (*ChangePage, error) {
+	args := m.Called(ctx, userID, since, limit)
+	return args.Get(0).(*ChangePage), args.Error(1)
+}
+
+// ExportTodos hands the todos set up to fn in one batch, unless an error is
+// set up.
+func (m *MockTodo) ExportTodos(ctx context.Context, userID uint, fn func(todos []*Todo) error) error {
+	args := m.Called(ctx, userID)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	return fn(args.Get(0).([]*Todo))
+}
+
+// Transaction runs fn with the mock itself unless an error is set up.
+func (m *MockTodo) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	if err := m.Called(ctx, fn).Error(0); err != nil {
+		return err
+	}
+	return fn(m)
+}
+
+func (m *MockTodo) Decorate(ctx context.Context, userID uint, todos ...*Todo) error {
+	args := m.Called(ctx, userID, todos)
+	return args.Error(0)
+}
@@ -0,0 +1,219 @@
+package todo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUpdateTodo(T *testing.T) {
+	s := &store{db: openTestDB(T)}
+	ctx := context.Background()
+	owner, other := uint(1000001), uint(1000002)
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	read := func() *Todo {
+		todoItem, err := s.GetTodoById(ctx, owner, created.ID)
+		require.Nil(T, err)
+		return todoItem
+	}
+
+	updated, err := s.UpdateTodo(ctx, owner, read(), map[string]interface{}{"name": "A"})
+	assert.Nil(T, err, "an update that changes nothing still finds the todo")
+	assert.Equal(T, uint(2), updated.Version)
+
+	stale := read()
+	_, err = s.UpdateTodo(ctx, owner, read(), map[string]interface{}{"name": "B"})
+	require.Nil(T, err)
+	_, err = s.UpdateTodo(ctx, owner, stale, map[string]interface{}{"name": "C"})
+	assert.Equal(T, ErrStale, err)
+
+	_, err = s.UpdateTodo(ctx, other, read(), map[string]interface{}{"name": "D"})
+	assert.Equal(T, gorm.ErrRecordNotFound, err)
+	missing := read()
+	missing.ID++
+	_, err = s.UpdateTodo(ctx, owner, missing, map[string]interface{}{"name": "E"})
+	assert.Equal(T, gorm.ErrRecordNotFound, err)
+	assert.Equal(T, "B", read().Name)
+}
+
+func TestDeleteTodoCascade(T *testing.T) {
+	db := openTestDB(T)
+	s := &store{db: db}
+	items := item.NewStoreWith(db)
+	ctx := context.Background()
+	owner := uint(1000001)
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	for _, name := range []string{"x", "y"} {
+		_, err := items.CreateItem(ctx, &item.Item{TodoID: created.ID, Name: name})
+		require.Nil(T, err)
+	}
+
+	_, err = s.DeleteTodoById(ctx, owner, created.ID, DeleteOptions{})
+	assert.Equal(T, &ItemsError{Count: 2}, err)
+	_, err = s.GetTodoById(ctx, owner, created.ID)
+	assert.Nil(T, err, "a refused delete keeps the todo")
+
+	_, err = s.DeleteTodoById(ctx, owner, created.ID, DeleteOptions{Cascade: true})
+	require.Nil(T, err)
+	count, err := items.CountItems(ctx, created.ID)
+	require.Nil(T, err)
+	assert.Equal(T, int64(0), count)
+}
+
+func TestGetTodosByTag(T *testing.T) {
+	db := openTestDB(T)
+	s := &store{db: db}
+	ctx := context.Background()
+	owner, other := uint(1000001), uint(1000002)
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	for _, tagItem := range []*tag.Tag{{UserID: owner, Name: "home"}, {UserID: other, Name: "urgent"}} {
+		require.Nil(T, db.Create(tagItem).Error)
+		require.Nil(T, db.Create(&tag.TodoTag{TodoID: created.ID, TagID: tagItem.ID}).Error)
+	}
+	count := func(tags ...string) int {
+		page, err := s.GetTodos(ctx, owner, &ListOptions{Tags: tags, Sort: "id", Limit: 10})
+		require.Nil(T, err)
+		return len(page.Items)
+	}
+
+	assert.Equal(T, 1, count("home"))
+	assert.Equal(T, 0, count("urgent"), "another user's tag does not match")
+	require.Nil(T, s.Decorate(ctx, owner, created))
+	require.Len(T, created.Tags, 1, "another user's tag is not shown")
+	assert.Equal(T, "home", created.Tags[0].Name)
+}
+
+func TestCompleteOccurrence(T *testing.T) {
+	db := openTestDB(T)
+	s := &store{db: db}
+	items := item.NewStoreWith(db)
+	ctx := context.Background()
+	owner := uint(1000001)
+	dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner, DueAt: &dueAt, Recurrence: "FREQ=DAILY", RecurrenceStart: &dueAt})
+	require.Nil(T, err)
+	home := &tag.Tag{UserID: owner, Name: "home"}
+	require.Nil(T, db.Create(home).Error)
+	require.Nil(T, tag.NewStoreWith(db).AttachTag(ctx, created.ID, home.ID))
+	for _, done := range []bool{true, false} {
+		_, err := items.CreateItem(ctx, &item.Item{TodoID: created.ID, Name: "step", Done: done})
+		require.Nil(T, err)
+	}
+
+	created, err = s.GetTodoById(ctx, owner, created.ID)
+	require.Nil(T, err)
+	next, err := created.NextOccurrence()
+	require.Nil(T, err)
+	completed, err := s.CompleteOccurrence(ctx, owner, created, map[string]interface{}{"status": StatusDone}, next)
+	require.Nil(T, err)
+	assert.Equal(T, next.ID, *completed.NextID)
+
+	tags, err := tag.NewStoreWith(db).TagsOf(ctx, owner, []uint{next.ID})
+	require.Nil(T, err)
+	assert.Len(T, tags[next.ID], 1)
+	copied, err := items.GetItems(ctx, next.ID)
+	require.Nil(T, err)
+	require.Len(T, copied, 2)
+	for _, itemEntry := range copied {
+		assert.False(T, itemEntry.Done)
+	}
+}
+
+func TestDeleteTodoVersion(T *testing.T) {
+	s := &store{db: openTestDB(T)}
+	ctx := context.Background()
+	owner := uint(1000001)
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	_, err = s.UpdateTodo(ctx, owner, created, map[string]interface{}{"name": "B"})
+	require.Nil(T, err)
+
+	_, err = s.DeleteTodoById(ctx, owner, created.ID, DeleteOptions{Version: 1})
+	assert.Equal(T, ErrStale, err)
+	_, err = s.DeleteTodoById(ctx, owner, created.ID, DeleteOptions{Version: 2})
+	assert.Nil(T, err)
+}
+
+func TestRestoreTodoVersion(T *testing.T) {
+	s := &store{db: openTestDB(T)}
+	ctx := context.Background()
+	owner := uint(1000001)
+	created, err := s.CreateTodo(ctx, &Todo{Name: "A", UserID: owner})
+	require.Nil(T, err)
+	deleted, err := s.DeleteTodoById(ctx, owner, created.ID, DeleteOptions{})
+	require.Nil(T, err)
+
+	restored, err := s.RestoreTodoById(ctx, owner, created.ID)
+	require.Nil(T, err)
+	assert.Equal(T, uint(2), restored.Version)
+	_, err = s.UpdateTodo(ctx, owner, deleted, map[string]interface{}{"name": "B"})
+	assert.Equal(T, ErrStale, err, "a version read before the delete is stale")
+	read, err := s.GetTodoById(ctx, owner, created.ID)
+	require.Nil(T, err)
+	assert.Equal(T, uint(2), read.Version)
+}
+
+func TestPurgeUsers(T *testing.T) {
+	db := openTestDB(T)
+	s := &store{db: db}
+	ctx := context.Background()
+	gone, left, kept := uint(1000001), uint(1000002), uint(1000003)
+	require.Nil(T, db.Exec("CREATE TABLE IF NOT EXISTS users (id integer PRIMARY KEY, deleted_at timestamp)").Error)
+	require.Nil(T, db.Exec("INSERT INTO users (id, deleted_at) VALUES (?, ?), (?, NULL), (?, ?)",
+		gone, time.Now().Add(-48*time.Hour), left, kept, time.Now()).Error)
+	lists := list.NewStoreWith(db)
+	shared, err := lists.CreateList(ctx, &list.List{Name: "Shared", CreatedBy: gone})
+	require.Nil(T, err)
+	require.Nil(T, db.Create(&list.Member{ListID: shared.ID, UserID: left, Role: list.RoleEditor}).Error)
+	alone, err := lists.CreateList(ctx, &list.List{Name: "Alone", CreatedBy: gone})
+	require.Nil(T, err)
+	own, err := s.CreateTodo(ctx, &Todo{Name: "Own", UserID: gone})
+	require.Nil(T, err)
+	tagItem := &tag.Tag{UserID: gone, Name: "home"}
+	require.Nil(T, db.Create(tagItem).Error)
+	require.Nil(T, db.Create(&tag.TodoTag{TodoID: own.ID, TagID: tagItem.ID}).Error)
+	inShared, err := s.CreateTodo(ctx, &Todo{Name: "In shared", UserID: gone, ListID: &shared.ID})
+	require.Nil(T, err)
+	inAlone, err := s.CreateTodo(ctx, &Todo{Name: "In alone", UserID: gone, ListID: &alone.ID})
+	require.Nil(T, err)
+	assigned, err := s.CreateTodo(ctx, &Todo{Name: "Assigned", UserID: left, AssigneeID: &gone})
+	require.Nil(T, err)
+	exists := func(model interface{}, query string, args ...interface{}) bool {
+		var count int64
+		require.Nil(T, db.Unscoped().Model(model).Where(query, args...).Count(&count).Error)
+		return count > 0
+	}
+
+	count, err := s.PurgeUsers(ctx, time.Now().Add(-24*time.Hour))
+	require.Nil(T, err)
+	assert.Equal(T, int64(1), count, "only the user in the trash for long enough is released")
+	assert.False(T, exists(&Todo{}, "id IN ?", []uint{own.ID, inAlone.ID}))
+	assert.False(T, exists(&tag.Tag{}, "user_id = ?", gone))
+	assert.False(T, exists(&list.Member{}, "user_id = ?", gone))
+	assert.False(T, exists(&list.List{}, "id = ?", alone.ID), "a list without members goes")
+	assert.True(T, exists(&list.Member{}, "list_id = ? AND user_id = ? AND role = ?", shared.ID, left, list.RoleOwner))
+	handed, err := s.GetTodoById(ctx, left, inShared.ID)
+	require.Nil(T, err)
+	assert.Equal(T, left, handed.UserID)
+	assert.Equal(T, uint(2), handed.Version)
+	unassigned, err := s.GetTodoById(ctx, left, assigned.ID)
+	require.Nil(T, err)
+	assert.Nil(T, unassigned.AssigneeID)
+	assert.False(T, exists(&Todo{}, "user_id = ? OR assignee_id = ?", gone, gone))
+	assert.False(T, exists(&feed.Counter{}, "user_id = ?", gone))
+
+	count, err = s.PurgeUsers(ctx, time.Now().Add(-24*time.Hour))
+	require.Nil(T, err)
+	assert.Equal(T, int64(0), count)
+}
@@ -0,0 +1,303 @@
+package todo
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/models/feed"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"gorm.io/gorm"
+)
+
+// DefaultRetention is how long deleted todos stay in the trash when no
+// retention is configured.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// trashed limits q to the deleted todos userID could have deleted.
+func trashed(q *gorm.DB, userID uint) *gorm.DB {
+	return owned(q.Unscoped(), userID).Where("todos.deleted_at IS NOT NULL")
+}
+
+// GetTrash returns the deleted todos userID can restore, the most recently
+// deleted first.
+func (s *store) GetTrash(ctx context.Context, userID uint) ([]*Todo, error) {
+	todos := []*Todo{}
+	err := withRole(trashed(s.db.WithContext(ctx).Model(&Todo{}), userID), userID).
+		Order("todos.deleted_at DESC, todos.id DESC").
+		Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// RestoreTodoById takes a todo out of the trash along with the checklist
+// items that were deleted with it, as a new version of the todo.
+func (s *store) RestoreTodoById(ctx context.Context, userID uint, id uint) (*Todo, error) {
+	todoItem := new(Todo)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := withRole(trashed(tx, userID), userID).First(todoItem, id).Error; err != nil {
+			return err
+		}
+		deletedAt := todoItem.DeletedAt.Time
+		restored := map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")}
+		if err := tx.Unscoped().Model(todoItem).Updates(restored).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE items SET deleted_at = NULL WHERE todo_id = ? AND deleted_at >= ?", id, deletedAt).Error; err != nil {
+			return err
+		}
+		return feed.Record(tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	todoItem.DeletedAt = gorm.DeletedAt{}
+	todoItem.Version++
+	return todoItem, nil
+}
+
+// PurgeTodoById permanently deletes a todo of the trash.
+func (s *store) PurgeTodoById(ctx context.Context, userID uint, id uint) (*Todo, error) {
+	todoItem := new(Todo)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := trashed(tx, userID).First(todoItem, id).Error; err != nil {
+			return err
+		}
+		return purge(tx, []uint{id})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todoItem, nil
+}
+
+// PurgeTrash permanently deletes the todos and checklist items deleted
+// before before, and returns how many todos it deleted.
+func (s *store) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	var ids []uint
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&Todo{}).Where("deleted_at < ?", before).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM items WHERE deleted_at < ?", before).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return purge(tx, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// purge deletes the todos ids for good, with their tags and checklists.
+// Todos that were followed by one of them no longer are.
+func purge(tx *gorm.DB, ids []uint) error {
+	if err := tx.Exec("DELETE FROM todo_tags WHERE todo_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM items WHERE todo_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("UPDATE todos SET next_id = NULL WHERE next_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(&Todo{}, ids).Error; err != nil {
+		return err
+	}
+	return feed.Forget(tx, ids...)
+}
+
+// hasData matches the users the service holds data of.
+const hasData = "(EXISTS (SELECT 1 FROM todos WHERE todos.user_id = users.id OR todos.assignee_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM list_members WHERE list_members.user_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM list_invitations WHERE list_invitations.user_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM tags WHERE tags.user_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM feed_counters WHERE feed_counters.user_id = users.id))"
+
+// PurgeUsers deletes the data of the users deleted before before, which the
+// user service waits for to purge them, and returns how many users it
+// released.
+func (s *store) PurgeUsers(ctx context.Context, before time.Time) (int64, error) {
+	var userIDs []uint
+	err := s.db.WithContext(ctx).Table("users").Where("deleted_at < ?", before).Where(hasData).Order("id").Pluck("id", &userIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, userID := range userIDs {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return release(tx, userID)
+		})
+		if err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(len(userIDs)), nil
+}
+
+// release deletes the data of userID. They leave their lists, and the todos
+// they created in a list pass to the owner of the list who joined it first.
+// The todos assigned to them are unassigned, and their other todos are
+// purged with their tags and feed.
+func release(tx *gorm.DB, userID uint) error {
+	var listIDs []uint
+	if err := tx.Model(&list.Member{}).Where("user_id = ?", userID).Order("list_id").Pluck("list_id", &listIDs).Error; err != nil {
+		return err
+	}
+	for _, listID := range listIDs {
+		if err := leave(tx, listID, userID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&list.Invitation{}).Error; err != nil {
+		return err
+	}
+
+	var left []uint
+	err := tx.Unscoped().Model(&Todo{}).Distinct("list_id").
+		Where("user_id = ? AND list_id IS NOT NULL", userID).Order("list_id").Pluck("list_id", &left).Error
+	if err != nil {
+		return err
+	}
+	for _, listID := range left {
+		if err := handOver(tx, listID, userID); err != nil {
+			return err
+		}
+	}
+
+	var assigned []uint
+	if err := tx.Unscoped().Model(&Todo{}).Where("assignee_id = ?", userID).Pluck("id", &assigned).Error; err != nil {
+		return err
+	}
+	if len(assigned) > 0 {
+		unassigned := map[string]interface{}{"assignee_id": nil, "version": gorm.Expr("version + 1")}
+		if err := tx.Unscoped().Model(&Todo{}).Where("id IN ?", assigned).Updates(unassigned).Error; err != nil {
+			return err
+		}
+		if err := feed.Record(tx, assigned...); err != nil {
+			return err
+		}
+	}
+
+	var own []uint
+	if err := tx.Unscoped().Model(&Todo{}).Where("user_id = ?", userID).Pluck("id", &own).Error; err != nil {
+		return err
+	}
+	if len(own) > 0 {
+		if err := purge(tx, own); err != nil {
+			return err
+		}
+	}
+	if err := tx.Exec("DELETE FROM todo_tags WHERE tag_id IN (SELECT id FROM tags WHERE user_id = ?)", userID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM tags WHERE user_id = ?", userID).Error; err != nil {
+		return err
+	}
+	return feed.Drop(tx, userID)
+}
+
+// leave takes userID out of the list. A list they were the last member of
+// is deleted with its todos; one they were the last owner of passes to the
+// member who joined it first.
+func leave(tx *gorm.DB, listID uint, userID uint) error {
+	others := []*list.Member{}
+	if err := tx.Where("list_id = ? AND user_id <> ?", listID, userID).Order("created_at, user_id").Find(&others).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("list_id = ? AND user_id = ?", listID, userID).Delete(&list.Member{}).Error; err != nil {
+		return err
+	}
+	if len(others) == 0 {
+		var ids []uint
+		if err := tx.Unscoped().Model(&Todo{}).Where("list_id = ?", listID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			if err := purge(tx, ids); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("list_id = ?", listID).Delete(&list.Invitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&list.List{}, listID).Error
+	}
+	owned := false
+	for _, member := range others {
+		owned = owned || member.Role == list.RoleOwner
+	}
+	if !owned {
+		heir := others[0]
+		err := tx.Model(&list.Member{}).Where("list_id = ? AND user_id = ?", listID, heir.UserID).Update("role", list.RoleOwner).Error
+		if err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Unscoped().Model(&Todo{}).Where("list_id = ?", listID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		return feed.Reach(tx, heir.UserID, ids...)
+	}
+	return nil
+}
+
+// handOver makes the owner of the list who joined it first the user of the
+// todos userID created in it. Without owners, the todos stay theirs.
+func handOver(tx *gorm.DB, listID uint, userID uint) error {
+	var owners []uint
+	err := tx.Model(&list.Member{}).Where("list_id = ? AND role = ?", listID, list.RoleOwner).
+		Order("created_at, user_id").Limit(1).Pluck("user_id", &owners).Error
+	if err != nil || len(owners) == 0 {
+		return err
+	}
+	var ids []uint
+	err = tx.Unscoped().Model(&Todo{}).Where("list_id = ? AND user_id = ?", listID, userID).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	handed := map[string]interface{}{"user_id": owners[0], "version": gorm.Expr("version + 1")}
+	if err := tx.Unscoped().Model(&Todo{}).Where("id IN ?", ids).Updates(handed).Error; err != nil {
+		return err
+	}
+	return feed.Record(tx, ids...)
+}
+
+// Purge purges the todos that have been in the trash for longer than
+// retention, and the data of the users that have been in the trash of the
+// user service for as long, every interval until ctx is done. Retention is
+// 30 days and interval an hour when they are not set.
+func Purge(ctx context.Context, store Store, retention time.Duration, interval time.Duration) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		before := time.Now().Add(-retention)
+		if count, err := store.PurgeTrash(ctx, before); err != nil {
+			log.Printf("trash: %v", err)
+		} else if count > 0 {
+			log.Printf("trash: purged %d todos", count)
+		}
+		if count, err := store.PurgeUsers(ctx, before); err != nil {
+			log.Printf("trash: %v", err)
+		} else if count > 0 {
+			log.Printf("trash: deleted the data of %d purged users", count)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,22 +2,82 @@ package routing
 
 import (
 	"github.com/ennemli/todo/todo/configs"
+	assigneehandlers "github.com/ennemli/todo/todo/internal/handlers/assignee"
+	itemhandlers "github.com/ennemli/todo/todo/internal/handlers/item"
+	listhandlers "github.com/ennemli/todo/todo/internal/handlers/list"
+	taghandlers "github.com/ennemli/todo/todo/internal/handlers/tag"
 	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
 	"github.com/ennemli/todo/todo/internal/models/todo"
 	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/internal/users"
 	"github.com/go-chi/chi/v5"
 )
 
-func InitRouting(store todo.Store) {
+func InitRouting(store todo.Store, items item.Store, tags tag.Store, lists list.Store, userService users.Client) {
 	r := server.GetRouter()
-	todoHandlers := handlers.NewTodoHandlers(store)
+	todoHandlers := handlers.NewTodoHandlers(store, items, tags)
+	itemHandlers := itemhandlers.NewItemHandlers(store, items)
+	tagHandlers := taghandlers.NewTagHandlers(store, tags)
+	listHandlers := listhandlers.NewListHandlers(lists, userService)
+	assigneeHandlers := assigneehandlers.NewAssigneeHandlers(store, userService)
 	r.Route(configs.GetConfig().Service.API_ENDPOINT, func(r chi.Router) {
+		r.Use(middlewares.WithIdentity)
 		r.Get("/", todoHandlers.GetTodos)
 		r.Post("/", todoHandlers.CreateTodo)
+		r.Get("/search", todoHandlers.SearchTodos)
+		r.Post("/batch", todoHandlers.Batch)
+		r.Get("/changes", todoHandlers.GetChanges)
+		r.Get("/export", todoHandlers.Export)
+		r.Post("/import", todoHandlers.Import)
+		r.Get("/trash", todoHandlers.GetTrash)
+		r.Delete("/trash/{id}", todoHandlers.PurgeTodoById)
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/", tagHandlers.GetTags)
+			r.Post("/", tagHandlers.CreateTag)
+			r.Get("/{tagId}", tagHandlers.GetTagById)
+			r.Put("/{tagId}", tagHandlers.UpdateTag)
+			r.Delete("/{tagId}", tagHandlers.DeleteTagById)
+		})
+		r.Route("/lists", func(r chi.Router) {
+			r.Get("/", listHandlers.GetLists)
+			r.Post("/", listHandlers.CreateList)
+			r.Get("/invitations", listHandlers.GetMyInvitations)
+			r.Post("/invitations/{invitationId}/accept", listHandlers.AcceptInvitation)
+			r.Post("/invitations/{invitationId}/decline", listHandlers.DeclineInvitation)
+			r.Route("/{listId}", func(r chi.Router) {
+				r.Get("/", listHandlers.GetListById)
+				r.Put("/", listHandlers.UpdateList)
+				r.Delete("/", listHandlers.DeleteListById)
+				r.Get("/members", listHandlers.GetMembers)
+				r.Put("/members/{userId}", listHandlers.SetRole)
+				r.Delete("/members/{userId}", listHandlers.RemoveMember)
+				r.Get("/invitations", listHandlers.GetInvitations)
+				r.Post("/invitations", listHandlers.CreateInvitation)
+				r.Delete("/invitations/{invitationId}", listHandlers.DeleteInvitationById)
+			})
+		})
 		r.Route("/{id}", func(r chi.Router) {
 			r.Get("/", todoHandlers.GetTodoById)
 			r.Delete("/", todoHandlers.DeleteTodoById)
 			r.Put("/", todoHandlers.UpdateTodo)
+			r.Get("/occurrences", todoHandlers.GetOccurrences)
+			r.Post("/restore", todoHandlers.RestoreTodoById)
+			r.Route("/items", func(r chi.Router) {
+				r.Get("/", itemHandlers.GetItems)
+				r.Post("/", itemHandlers.CreateItem)
+				r.Put("/order", itemHandlers.ReorderItems)
+				r.Put("/{itemId}", itemHandlers.UpdateItem)
+				r.Post("/{itemId}/toggle", itemHandlers.ToggleItem)
+				r.Delete("/{itemId}", itemHandlers.DeleteItemById)
+			})
+			r.Post("/tags/{tagId}", tagHandlers.AttachTag)
+			r.Delete("/tags/{tagId}", tagHandlers.DetachTag)
+			r.Put("/assignee", assigneeHandlers.Assign)
+			r.Delete("/assignee", assigneeHandlers.Unassign)
 		})
 	})
 }
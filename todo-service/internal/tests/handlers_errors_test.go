@@ -21,13 +21,14 @@ func TestTimeOut(T *testing.T) {
 	r := server.GetRouter()
 	r.Use(middlewares.SetTimeOut(errors.TimeoutDuration))
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
-	r.Get("/", todoHandlers.GetTodos)
-	mt.On("GetTodos", mock.Anything).Return([]*todo.Todo{}, nil).Run(func(args mock.Arguments) {
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	r.With(middlewares.WithIdentity).Get("/", todoHandlers.GetTodos)
+	mt.On("GetTodos", mock.Anything, testUserID, mock.Anything).Return(&todo.Page{Items: []*todo.Todo{}}, nil).Run(func(args mock.Arguments) {
 		time.Sleep(errors.TimeoutDuration + time.Second)
 	})
 	req, _ := http.NewRequest("GET", "/", nil)
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	res := MakeRequest(req)
 
@@ -42,21 +43,22 @@ func TestTimeOut(T *testing.T) {
 func TestUpdateExtraFields(T *testing.T) {
 	InitServe()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	updatedFields := map[string]interface{}{
 		"name":       "Updated Todo",
 		"extraFiedl": 12,
 	}
-	mt.On("GetTodoById", mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
+	mt.On("GetTodoById", mock.Anything, mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
 
-	mt.On("UpdateTodo", mock.Anything, mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
+	mt.On("UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
 
 	reqBody, _ := json.Marshal(updatedFields)
 	req, _ := http.NewRequest("PUT", "/1", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	r := server.GetRouter()
-	r.Put("/{id}", todoHandlers.UpdateTodo)
+	r.With(middlewares.WithIdentity).Put("/{id}", todoHandlers.UpdateTodo)
 
 	res := MakeRequest(req)
 
@@ -72,21 +74,22 @@ func TestUpdateExtraFields(T *testing.T) {
 func TestUpdateUserId(T *testing.T) {
 	InitServe()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	updatedFields := map[string]interface{}{
 		"name":   "Updated Todo",
 		"userid": 12,
 	}
-	mt.On("GetTodoById", mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
+	mt.On("GetTodoById", mock.Anything, mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
 
-	mt.On("UpdateTodo", mock.Anything, mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
+	mt.On("UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&todo.Todo{}, nil)
 
 	reqBody, _ := json.Marshal(updatedFields)
 	req, _ := http.NewRequest("PUT", "/1", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	r := server.GetRouter()
-	r.Put("/{id}", todoHandlers.UpdateTodo)
+	r.With(middlewares.WithIdentity).Put("/{id}", todoHandlers.UpdateTodo)
 
 	res := MakeRequest(req)
 
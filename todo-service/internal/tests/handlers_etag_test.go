@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// currentETag returns the ETag GET /1 answers with.
+func currentETag(T *testing.T) string {
+	req, _ := http.NewRequest("GET", "/1", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code)
+	tag := res.Header().Get("ETag")
+	assert.NotEmpty(T, tag)
+	return tag
+}
+
+func TestIfNoneMatch(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(versionedTodo(1), nil)
+	tag := currentETag(T)
+	assert.Equal(T, `"1.1"`, tag, "the ETag follows the version")
+
+	tt := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{"Current", tag, http.StatusNotModified},
+		{"Weak", "W/" + tag, http.StatusNotModified},
+		{"Listed", `"other", ` + tag, http.StatusNotModified},
+		{"Any", "*", http.StatusNotModified},
+		{"Other", `"other"`, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("GET", "/1", nil)
+			SetUser(req, testUserID)
+			req.Header.Set("If-None-Match", tc.header)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+			assert.Equal(T, tag, res.Header().Get("ETag"))
+			if tc.expected == http.StatusNotModified {
+				assert.Empty(T, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestIfMatch(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(versionedTodo(1), nil)
+	mt.On("UpdateTodo", mock.Anything, testUserID, mock.Anything, mock.Anything).Return(versionedTodo(2), nil)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{Version: 1}).Return(versionedTodo(1), nil)
+	tag := currentETag(T)
+
+	tt := []struct {
+		name     string
+		method   string
+		header   string
+		expected int
+	}{
+		{"UpdateStale", "PUT", `"stale"`, http.StatusPreconditionFailed},
+		{"UpdateWeak", "PUT", "W/" + tag, http.StatusPreconditionFailed},
+		{"DeleteStale", "DELETE", `"stale"`, http.StatusPreconditionFailed},
+		{"Update", "PUT", tag, http.StatusOK},
+		{"UpdateAny", "PUT", "*", http.StatusOK},
+		{"Delete", "DELETE", tag, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(`{"name":"Renamed"}`))
+			SetUser(req, testUserID)
+			req.Header.Set("If-Match", tc.header)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+			if tc.method == "PUT" && tc.expected == http.StatusOK {
+				assert.NotEmpty(T, res.Header().Get("ETag"))
+				assert.NotEqual(T, tag, res.Header().Get("ETag"))
+			}
+		})
+	}
+	mt.AssertNumberOfCalls(T, "UpdateTodo", 2)
+	mt.AssertNumberOfCalls(T, "DeleteTodoById", 1)
+}
+
+func TestConcurrentUpdate(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(versionedTodo(1), nil)
+	mt.On("UpdateTodo", mock.Anything, testUserID, mock.Anything, mock.Anything).Return((*todo.Todo)(nil), todo.ErrStale)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{Version: 1}).Return((*todo.Todo)(nil), todo.ErrStale)
+	tag := currentETag(T)
+
+	tt := []struct {
+		name     string
+		method   string
+		header   string
+		expected int
+	}{
+		{"WithoutPrecondition", "PUT", "", http.StatusConflict},
+		{"WithPrecondition", "PUT", tag, http.StatusPreconditionFailed},
+		{"DeleteWithPrecondition", "DELETE", tag, http.StatusPreconditionFailed},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(`{"name":"Renamed"}`))
+			SetUser(req, testUserID)
+			if tc.header != "" {
+				req.Header.Set("If-Match", tc.header)
+			}
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestPercent(T *testing.T) {
+	tt := []struct {
+		done     int
+		total    int
+		expected int
+	}{
+		{0, 0, 0},
+		{0, 3, 0},
+		{1, 3, 33},
+		{2, 3, 66},
+		{3, 3, 100},
+	}
+	for _, tc := range tt {
+		assert.Equal(T, tc.expected, item.Percent(tc.done, tc.total))
+	}
+}
+
+func TestCreateItem(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	route(mocks{todos: mt, items: mi})
+	ownTodo(mt, 1)
+	mi.On("GetItems", mock.Anything, uint(1)).Return([]*item.Item{}, nil)
+	mi.On("CreateItem", mock.Anything, mock.MatchedBy(func(i *item.Item) bool {
+		return i.TodoID == 1 && i.Name == "Buy milk" && i.ID == 0
+	})).Return(&item.Item{TodoID: 1, Name: "Buy milk", Position: 0}, nil)
+
+	req, _ := http.NewRequest("POST", "/1/items", bytes.NewBufferString(`{"name":" Buy milk ","todo_id":2,"ID":9}`))
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusCreated, res.Code)
+	var created item.Item
+	err := json.NewDecoder(res.Body).Decode(&created)
+	assert.Nil(T, err)
+	assert.Equal(T, uint(1), created.TodoID)
+
+	req, _ = http.NewRequest("POST", "/1/items", bytes.NewBufferString(`{"name":"  "}`))
+	SetUser(req, testUserID)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusBadRequest, res.Code)
+	mi.AssertNumberOfCalls(T, "CreateItem", 1)
+}
+
+func TestItemsOfAnotherUsersTodo(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	route(mocks{todos: mt, items: mi})
+	ownTodo(mt, 1)
+
+	tt := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/1/items", ""},
+		{"POST", "/1/items", `{"name":"Step"}`},
+		{"PUT", "/1/items/order", `{"ids":[1]}`},
+		{"PUT", "/1/items/1", `{"done":true}`},
+		{"POST", "/1/items/1/toggle", ""},
+		{"DELETE", "/1/items/1", ""},
+	}
+	for _, tc := range tt {
+		T.Run(tc.method+tc.path, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, otherUserID)
+			res := MakeRequest(req)
+
+			assert.Equal(T, http.StatusNotFound, res.Code)
+			var errorResponse errors.ErrorResponse
+			err := json.NewDecoder(res.Body).Decode(&errorResponse)
+			assert.Nil(T, err)
+			assert.Equal(T, "Todo with ID 1 not found", errorResponse.Message)
+		})
+	}
+	assert.Empty(T, mi.Calls)
+}
+
+func TestToggleAndUpdateItem(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	route(mocks{todos: mt, items: mi})
+	ownTodo(mt, 1)
+	existing := &item.Item{TodoID: 1, Name: "Step", Done: true}
+	existing.ID = 4
+	mi.On("GetItemById", mock.Anything, uint(1), uint(4)).Return(existing, nil)
+	mi.On("GetItemById", mock.Anything, uint(1), uint(5)).Return((*item.Item)(nil), gorm.ErrRecordNotFound)
+	mi.On("UpdateItem", mock.Anything, existing, map[string]interface{}{"done": false}).Return(existing, nil)
+	mi.On("UpdateItem", mock.Anything, existing, map[string]interface{}{"name": "Renamed"}).Return(existing, nil)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Toggle", "POST", "/1/items/4/toggle", "", http.StatusOK},
+		{"Rename", "PUT", "/1/items/4", `{"name":" Renamed"}`, http.StatusOK},
+		{"UnknownItem", "POST", "/1/items/5/toggle", "", http.StatusNotFound},
+		{"ExtraField", "PUT", "/1/items/4", `{"todo_id":2}`, http.StatusBadRequest},
+		{"DoneNotABool", "PUT", "/1/items/4", `{"done":"yes"}`, http.StatusBadRequest},
+		{"BlankName", "PUT", "/1/items/4", `{"name":""}`, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mi.AssertNumberOfCalls(T, "UpdateItem", 2)
+}
+
+func TestReorderItems(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	route(mocks{todos: mt, items: mi})
+	ownTodo(mt, 1)
+	mi.On("ReorderItems", mock.Anything, uint(1), []uint{3, 1, 2}).Return([]*item.Item{{Name: "C"}, {Name: "A"}, {Name: "B"}}, nil)
+	mi.On("ReorderItems", mock.Anything, uint(1), []uint{3, 1}).Return(([]*item.Item)(nil), item.ErrOrderMismatch)
+
+	req, _ := http.NewRequest("PUT", "/1/items/order", bytes.NewBufferString(`{"ids":[3,1,2]}`))
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code)
+	var items []*item.Item
+	err := json.NewDecoder(res.Body).Decode(&items)
+	assert.Nil(T, err)
+	assert.Equal(T, "C", items[0].Name)
+
+	req, _ = http.NewRequest("PUT", "/1/items/order", bytes.NewBufferString(`{"ids":[3,1]}`))
+	SetUser(req, testUserID)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusBadRequest, res.Code)
+	mi.AssertExpectations(T)
+}
+
+func TestDeleteTodoWithItems(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	route(mocks{todos: mt, items: mi})
+	deleted := newTodo(1)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{}).Return((*todo.Todo)(nil), &todo.ItemsError{Count: 2})
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{Cascade: true}).Return(deleted, nil)
+
+	req, _ := http.NewRequest("DELETE", "/1", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	assert.Equal(T, http.StatusConflict, res.Code)
+	assert.Contains(T, res.Body.String(), "2 checklist items")
+
+	req, _ = http.NewRequest("DELETE", "/1?cascade=maybe", nil)
+	SetUser(req, testUserID)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusBadRequest, res.Code)
+
+	req, _ = http.NewRequest("DELETE", "/1?cascade=true", nil)
+	SetUser(req, testUserID)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code)
+	mt.AssertExpectations(T)
+	mi.AssertExpectations(T)
+}
+
+func TestTodoProgress(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	route(mocks{todos: mt, items: mi})
+	first := &todo.Todo{Name: "With checklist"}
+	first.ID = 1
+	second := &todo.Todo{Name: "Without checklist"}
+	second.ID = 2
+	mt.On("GetTodos", mock.Anything, testUserID, mock.Anything).Return(&todo.Page{Items: []*todo.Todo{first, second}}, nil)
+	mi.On("Progress", mock.Anything, []uint{1, 2}).Return(map[uint]int{1: 50}, nil)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&page)
+	assert.Nil(T, err)
+	assert.Equal(T, float64(50), page.Items[0]["progress"])
+	assert.NotContains(T, page.Items[1], "progress")
+	mi.AssertExpectations(T)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseListOptions(T *testing.T) {
+	opts, err := todo.ParseListOptions(url.Values{
+		"status":    {"open,in_progress"},
+		"priority":  {"2", "3"},
+		"date_from": {"2024-05-01"},
+		"date_to":   {"2024-05-31"},
+		"due_to":    {"2024-06-01T12:00:00Z"},
+		"sort":      {"-due_at"},
+		"limit":     {"20"},
+		"total":     {"true"},
+	}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, []string{todo.StatusOpen, todo.StatusInProgress}, opts.Status)
+	assert.Equal(T, []int{2, 3}, opts.Priority)
+	assert.Equal(T, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), *opts.DateFrom)
+	assert.Equal(T, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), *opts.DateBefore)
+	assert.Nil(T, opts.DueFrom)
+	assert.Equal(T, time.Date(2024, 6, 1, 12, 0, 0, 1, time.UTC), *opts.DueBefore)
+	assert.Equal(T, "due_at", opts.Sort)
+	assert.True(T, opts.Desc)
+	assert.Equal(T, 20, opts.Limit)
+	assert.True(T, opts.WithTotal)
+
+	opts, err = todo.ParseListOptions(url.Values{}, 10)
+	assert.Nil(T, err)
+	assert.Equal(T, "id", opts.Sort)
+	assert.False(T, opts.Desc)
+	assert.Equal(T, 10, opts.Limit)
+}
+
+func TestParseListOptionsErrors(T *testing.T) {
+	tt := []struct {
+		name   string
+		values url.Values
+	}{
+		{"UnknownStatus", url.Values{"status": {"open,closed"}}},
+		{"PriorityOutOfRange", url.Values{"priority": {"7"}}},
+		{"PriorityNotANumber", url.Values{"priority": {"high"}}},
+		{"BadDate", url.Values{"date_from": {"yesterday"}}},
+		{"UnknownSortField", url.Values{"sort": {"password"}}},
+		{"LimitTooLarge", url.Values{"limit": {"101"}}},
+		{"ZeroLimit", url.Values{"limit": {"0"}}},
+		{"BadTotal", url.Values{"total": {"maybe"}}},
+		{"GarbageCursor", url.Values{"cursor": {"not-a-cursor"}}},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			_, err := todo.ParseListOptions(tc.values, 100)
+			assert.NotNil(T, err)
+		})
+	}
+}
+
+func TestCursorRoundTrip(T *testing.T) {
+	due := time.Date(2024, 5, 24, 9, 57, 38, 0, time.UTC)
+	last := &todo.Todo{Name: "Task", DueAt: &due}
+	last.ID = 42
+
+	opts, err := todo.ParseListOptions(url.Values{"sort": {"-due_at"}}, 100)
+	assert.Nil(T, err)
+	cursor := opts.CursorAfter(last)
+	assert.NotEmpty(T, cursor)
+
+	_, err = todo.ParseListOptions(url.Values{"sort": {"-due_at"}, "cursor": {cursor}}, 100)
+	assert.Nil(T, err)
+
+	_, err = todo.ParseListOptions(url.Values{"sort": {"due_at"}, "cursor": {cursor}}, 100)
+	assert.Equal(T, todo.ErrInvalidCursor, err)
+	_, err = todo.ParseListOptions(url.Values{"sort": {"name"}, "cursor": {cursor}}, 100)
+	assert.Equal(T, todo.ErrInvalidCursor, err)
+}
+
+func TestGetTodosPage(T *testing.T) {
+	InitServe()
+	viper.Set("MAX_PAGE_SIZE", 25)
+	T.Cleanup(func() { viper.Set("MAX_PAGE_SIZE", 0) })
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	total := int64(3)
+	mt.On("GetTodos", mock.Anything, testUserID, mock.MatchedBy(func(opts *todo.ListOptions) bool {
+		return opts.Limit == 2 && opts.Sort == "priority" && opts.Desc &&
+			len(opts.Status) == 1 && opts.Status[0] == todo.StatusDone && opts.WithTotal
+	})).Return(&todo.Page{Items: []*todo.Todo{{Name: "A"}, {Name: "B"}}, NextCursor: "next", Total: &total}, nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Get("/", todoHandlers.GetTodos)
+
+	req, _ := http.NewRequest("GET", "/?status=done&sort=-priority&limit=2&total=true", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var page todo.Page
+	err := json.NewDecoder(res.Body).Decode(&page)
+	assert.Nil(T, err)
+	assert.Equal(T, 2, len(page.Items))
+	assert.Equal(T, "next", page.NextCursor)
+	assert.Equal(T, int64(3), *page.Total)
+	mt.AssertExpectations(T)
+
+	req, _ = http.NewRequest("GET", "/?limit=26", nil)
+	SetUser(req, testUserID)
+	res = MakeRequest(req)
+
+	assert.Equal(T, http.StatusBadRequest, res.Code)
+	var errorResponse errors.ErrorResponse
+	err = json.NewDecoder(res.Body).Decode(&errorResponse)
+	assert.Nil(T, err)
+	assert.Equal(T, "limit must be between 1 and 25", errorResponse.Message)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCreateList(T *testing.T) {
+	InitServe()
+	ml := new(list.MockList)
+	route(mocks{lists: ml})
+	ml.On("CreateList", mock.Anything, &list.List{Name: "Groceries", CreatedBy: testUserID}).Return(&list.List{ID: 1, Name: "Groceries", Role: list.RoleOwner}, nil)
+
+	tt := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Created", `{"name":" Groceries ","created_by":2,"role":"viewer"}`, http.StatusCreated},
+		{"BlankName", `{"name":""}`, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("POST", "/lists", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	ml.AssertNumberOfCalls(T, "CreateList", 1)
+}
+
+func TestOnlyOwnersManageLists(T *testing.T) {
+	InitServe()
+	ml := new(list.MockList)
+	route(mocks{lists: ml})
+	memberOf(ml, 1, list.RoleEditor)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		user     uint
+		body     string
+		expected int
+	}{
+		{"Read", "GET", "/lists/1", testUserID, "", http.StatusOK},
+		{"NotAMember", "GET", "/lists/1", otherUserID, "", http.StatusNotFound},
+		{"Rename", "PUT", "/lists/1", testUserID, `{"name":"Mine"}`, http.StatusForbidden},
+		{"Delete", "DELETE", "/lists/1", testUserID, "", http.StatusForbidden},
+		{"SetRole", "PUT", "/lists/1/members/2", testUserID, `{"role":"owner"}`, http.StatusForbidden},
+		{"RemoveOtherMember", "DELETE", "/lists/1/members/2", testUserID, "", http.StatusForbidden},
+		{"Invite", "POST", "/lists/1/invitations", testUserID, `{"user_id":3}`, http.StatusForbidden},
+		{"RevokeInvitation", "DELETE", "/lists/1/invitations/1", testUserID, "", http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, tc.user)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
+
+func TestManageMembers(T *testing.T) {
+	InitServe()
+	ml := new(list.MockList)
+	route(mocks{lists: ml})
+	memberOf(ml, 1, list.RoleOwner)
+	memberOf(ml, 2, list.RoleViewer)
+	ml.On("SetRole", mock.Anything, uint(1), uint(3), list.RoleEditor).Return(&list.Member{ListID: 1, UserID: 3, Role: list.RoleEditor}, nil)
+	ml.On("SetRole", mock.Anything, uint(1), testUserID, list.RoleViewer).Return((*list.Member)(nil), list.ErrLastOwner)
+	ml.On("SetRole", mock.Anything, uint(1), uint(4), list.RoleViewer).Return((*list.Member)(nil), gorm.ErrRecordNotFound)
+	ml.On("RemoveMember", mock.Anything, uint(1), uint(3)).Return(nil)
+	ml.On("RemoveMember", mock.Anything, uint(1), testUserID).Return(list.ErrLastOwner)
+	ml.On("RemoveMember", mock.Anything, uint(2), testUserID).Return(nil)
+	ml.On("DeleteListById", mock.Anything, uint(1)).Return((*list.List)(nil), list.ErrListNotEmpty)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Promote", "PUT", "/lists/1/members/3", `{"role":"editor"}`, http.StatusOK},
+		{"InvalidRole", "PUT", "/lists/1/members/3", `{"role":"admin"}`, http.StatusBadRequest},
+		{"DemoteLastOwner", "PUT", "/lists/1/members/1", `{"role":"viewer"}`, http.StatusConflict},
+		{"NotAMember", "PUT", "/lists/1/members/4", `{"role":"viewer"}`, http.StatusNotFound},
+		{"Remove", "DELETE", "/lists/1/members/3", "", http.StatusNoContent},
+		{"LastOwnerLeaves", "DELETE", "/lists/1/members/1", "", http.StatusConflict},
+		{"ViewerLeaves", "DELETE", "/lists/2/members/1", "", http.StatusNoContent},
+		{"DeleteListWithTodos", "DELETE", "/lists/1", "", http.StatusConflict},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
+
+func TestInvitations(T *testing.T) {
+	InitServe()
+	ml := new(list.MockList)
+	mu := new(users.MockClient)
+	route(mocks{lists: ml, users: mu})
+	memberOf(ml, 1, list.RoleOwner)
+	mu.On("GetUser", mock.Anything, uint(3)).Return(&users.User{ID: 3, Name: "Casca"}, nil)
+	mu.On("GetUser", mock.Anything, uint(4)).Return(&users.User{ID: 4, Name: "Judeau"}, nil)
+	mu.On("GetUser", mock.Anything, uint(8)).Return((*users.User)(nil), users.ErrUserNotFound)
+	mu.On("GetUser", mock.Anything, uint(9)).Return((*users.User)(nil), users.ErrUnavailable)
+	ml.On("CreateInvitation", mock.Anything, &list.Invitation{ListID: 1, UserID: 3, Role: list.RoleViewer, InvitedBy: testUserID}).Return(&list.Invitation{ID: 5}, nil)
+	ml.On("CreateInvitation", mock.Anything, &list.Invitation{ListID: 1, UserID: 4, Role: list.RoleEditor, InvitedBy: testUserID}).Return((*list.Invitation)(nil), list.ErrAlreadyMember)
+	mine := &list.Invitation{ID: 6, ListID: 1, UserID: testUserID, Role: list.RoleEditor}
+	ml.On("GetInvitationById", mock.Anything, uint(6)).Return(mine, nil)
+	ml.On("GetInvitationById", mock.Anything, uint(7)).Return(&list.Invitation{ID: 7, ListID: 2, UserID: otherUserID}, nil)
+	ml.On("AcceptInvitation", mock.Anything, mine).Return(&list.Member{ListID: 1, UserID: testUserID, Role: list.RoleEditor}, nil)
+	ml.On("DeleteInvitationById", mock.Anything, uint(6)).Return(nil)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Invite", "POST", "/lists/1/invitations", `{"user_id":3,"invited_by":9}`, http.StatusCreated},
+		{"InviteMember", "POST", "/lists/1/invitations", `{"user_id":4,"role":"editor"}`, http.StatusConflict},
+		{"InviteNobody", "POST", "/lists/1/invitations", `{"role":"editor"}`, http.StatusBadRequest},
+		{"InviteUnknownUser", "POST", "/lists/1/invitations", `{"user_id":8}`, http.StatusNotFound},
+		{"UserServiceDown", "POST", "/lists/1/invitations", `{"user_id":9}`, http.StatusServiceUnavailable},
+		{"InvalidRole", "POST", "/lists/1/invitations", `{"user_id":3,"role":"admin"}`, http.StatusBadRequest},
+		{"Accept", "POST", "/lists/invitations/6/accept", "", http.StatusOK},
+		{"AcceptSomeoneElses", "POST", "/lists/invitations/7/accept", "", http.StatusNotFound},
+		{"Decline", "POST", "/lists/invitations/6/decline", "", http.StatusNoContent},
+		{"RevokeOtherListsInvitation", "DELETE", "/lists/1/invitations/7", "", http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	ml.AssertNumberOfCalls(T, "AcceptInvitation", 1)
+	ml.AssertNumberOfCalls(T, "DeleteInvitationById", 1)
+}
+
+func TestViewersCannotChangeTodos(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mi := new(item.MockItem)
+	mg := new(tag.MockTag)
+	route(mocks{todos: mt, items: mi, tags: mg})
+	listID := uint(3)
+	shared := &todo.Todo{Name: "Milk", ListID: &listID, Role: list.RoleViewer}
+	shared.ID = 1
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(shared, nil)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{}).Return((*todo.Todo)(nil), todo.ErrReadOnly)
+	mi.On("GetItems", mock.Anything, uint(1)).Return([]*item.Item{}, nil)
+	ownTag(mg, 2)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Update", "PUT", "/1", `{"name":"Oat milk"}`, http.StatusForbidden},
+		{"Delete", "DELETE", "/1", "", http.StatusForbidden},
+		{"ReadItems", "GET", "/1/items", "", http.StatusOK},
+		{"AddItem", "POST", "/1/items", `{"name":"Step"}`, http.StatusForbidden},
+		{"Reorder", "PUT", "/1/items/order", `{"ids":[1]}`, http.StatusForbidden},
+		{"ToggleItem", "POST", "/1/items/1/toggle", "", http.StatusForbidden},
+		{"AttachTag", "POST", "/1/tags/2", "", http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mt.AssertNotCalled(T, "UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mg.AssertNotCalled(T, "AttachTag", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTodosInLists(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("CreateTodo", mock.Anything, mock.MatchedBy(func(t *todo.Todo) bool { return *t.ListID == 3 })).Return((*todo.Todo)(nil), todo.ErrListNotFound)
+	mt.On("CreateTodo", mock.Anything, mock.MatchedBy(func(t *todo.Todo) bool { return *t.ListID == 4 })).Return((*todo.Todo)(nil), todo.ErrReadOnly)
+	existing := ownTodo(mt, 1)
+	mt.On("UpdateTodo", mock.Anything, testUserID, existing, map[string]interface{}{"list_id": uint(3)}).Return((*todo.Todo)(nil), todo.ErrListNotFound)
+	mt.On("UpdateTodo", mock.Anything, testUserID, existing, map[string]interface{}{"list_id": nil}).Return(existing, nil)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"CreateInUnknownList", "POST", "/", `{"name":"Milk","list_id":3}`, http.StatusNotFound},
+		{"CreateInViewedList", "POST", "/", `{"name":"Milk","list_id":4}`, http.StatusForbidden},
+		{"MoveToUnknownList", "PUT", "/1", `{"list_id":3}`, http.StatusNotFound},
+		{"MoveOutOfList", "PUT", "/1", `{"list_id":null}`, http.StatusOK},
+		{"InvalidList", "PUT", "/1", `{"list_id":"groceries"}`, http.StatusBadRequest},
+		{"FractionalList", "PUT", "/1", `{"list_id":1.5}`, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
+
+func TestListFilter(T *testing.T) {
+	opts, err := todo.ParseListOptions(url.Values{"list": {"none"}}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, uint(0), *opts.ListID)
+
+	opts, err = todo.ParseListOptions(url.Values{"list": {"3"}}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, uint(3), *opts.ListID)
+
+	opts, err = todo.ParseListOptions(url.Values{}, 100)
+	assert.Nil(T, err)
+	assert.Nil(T, opts.ListID)
+
+	for _, raw := range []string{"0", "-1", "groceries"} {
+		_, err = todo.ParseListOptions(url.Values{"list": {raw}}, 100)
+		assert.NotNil(T, err, raw)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func postBatch(body string) (int, *handlers.BatchResult) {
+	req, _ := http.NewRequest("POST", "/batch", bytes.NewBufferString(body))
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	result := new(handlers.BatchResult)
+	json.NewDecoder(res.Body).Decode(result)
+	return res.Code, result
+}
+
+func statuses(result *handlers.BatchResult) []int {
+	codes := make([]int, len(result.Results))
+	for i, r := range result.Results {
+		codes[i] = r.Status
+	}
+	return codes
+}
+
+func TestBatch(T *testing.T) {
+	InitServe()
+	mt := batchMock()
+	route(mocks{todos: mt})
+
+	status, result := postBatch(`{"operations":[
+		{"op":"create","body":{"name":"New"}},
+		{"op":"update","id":1,"body":{"name":"Renamed"}},
+		{"op":"delete","id":2}
+	]}`)
+
+	assert.Equal(T, http.StatusOK, status)
+	assert.Equal(T, []int{http.StatusOK, http.StatusOK, http.StatusOK}, statuses(result))
+	assert.Equal(T, uint(3), result.Results[0].Todo.ID)
+	assert.Equal(T, uint(2), result.Results[1].Todo.Version)
+	assert.Equal(T, `"1.2"`, result.Results[1].ETag)
+	assert.True(T, result.Results[2].Todo.DeletedAt.Valid)
+	mt.AssertNumberOfCalls(T, "Transaction", 1)
+	mt.AssertCalled(T, "Decorate", mock.Anything, testUserID, mock.MatchedBy(func(todos []*todo.Todo) bool {
+		return len(todos) == 2 && todos[0].ID == 3 && todos[1].ID == 1
+	}))
+}
+
+func TestBatchModes(T *testing.T) {
+	ops := `[
+		{"op":"create","body":{"name":"New"}},
+		{"op":"update","id":9,"body":{"name":"Renamed"}},
+		{"op":"delete","id":2}
+	]`
+	tt := []struct {
+		name         string
+		mode         string
+		status       int
+		statuses     []int
+		transactions int
+		deletes      int
+	}{
+		{"Atomic", "atomic", http.StatusNotFound, []int{http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}, 1, 0},
+		{"Partial", "partial", http.StatusOK, []int{http.StatusOK, http.StatusNotFound, http.StatusOK}, 4, 1},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			mt := batchMock()
+			route(mocks{todos: mt})
+
+			status, result := postBatch(fmt.Sprintf(`{"mode":%q,"operations":%s}`, tc.mode, ops))
+
+			assert.Equal(T, tc.status, status)
+			assert.Equal(T, tc.statuses, statuses(result))
+			mt.AssertNumberOfCalls(T, "Transaction", tc.transactions)
+			mt.AssertNumberOfCalls(T, "DeleteTodoById", tc.deletes)
+		})
+	}
+}
+
+func TestBatchCascade(T *testing.T) {
+	InitServe()
+	mt := batchMock()
+	mi := newItemMock()
+	route(mocks{todos: mt, items: mi})
+
+	status, result := postBatch(`{"operations":[{"op":"delete","id":2,"cascade":true}]}`)
+
+	assert.Equal(T, http.StatusOK, status)
+	assert.Equal(T, []int{http.StatusOK}, statuses(result))
+	mi.AssertNotCalled(T, "DeleteItems", mock.Anything, mock.Anything)
+	mt.AssertCalled(T, "DeleteTodoById", mock.Anything, testUserID, uint(2), todo.DeleteOptions{Cascade: true})
+}
+
+func TestBatchErrors(T *testing.T) {
+	tooMany := `{"op":"delete","id":2}` + strings.Repeat(`,{"op":"delete","id":2}`, handlers.MaxBatchSize)
+	tt := []struct {
+		name     string
+		body     string
+		status   int
+		statuses []int
+	}{
+		{"InvalidPayload", `{"operations":`, http.StatusBadRequest, nil},
+		{"InvalidMode", `{"mode":"some","operations":[{"op":"delete","id":2}]}`, http.StatusBadRequest, nil},
+		{"Empty", `{"operations":[]}`, http.StatusBadRequest, nil},
+		{"TooMany", `{"operations":[` + tooMany + `]}`, http.StatusBadRequest, nil},
+		{"InvalidOp", `{"mode":"partial","operations":[{"op":"purge","id":2},{"op":"update","id":1}]}`, http.StatusOK, []int{http.StatusBadRequest, http.StatusBadRequest}},
+		{"StalePrecondition", `{"operations":[{"op":"update","id":1,"if_match":"\"stale\"","body":{"name":"Renamed"}}]}`, http.StatusPreconditionFailed, []int{http.StatusPreconditionFailed}},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			mt := batchMock()
+			route(mocks{todos: mt})
+
+			status, result := postBatch(tc.body)
+
+			assert.Equal(T, tc.status, status)
+			if tc.statuses != nil {
+				assert.Equal(T, tc.statuses, statuses(result))
+			}
+			mt.AssertNotCalled(T, "UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mt.AssertNotCalled(T, "DeleteTodoById", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestBatchTransactionFails(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mt.On("Transaction", mock.Anything, mock.Anything).Return(fmt.Errorf("connection lost"))
+	route(mocks{todos: mt})
+
+	status, _ := postBatch(`{"operations":[{"op":"delete","id":2}]}`)
+
+	assert.Equal(T, http.StatusInternalServerError, status)
+	mt.AssertNotCalled(T, "DeleteTodoById", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/errors"
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+const otherUserID = uint(2)
+
+func TestMissingIdentity(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Get("/", todoHandlers.GetTodos)
+
+	tt := []struct {
+		name   string
+		header string
+	}{
+		{"NoHeader", ""},
+		{"NotANumber", "abc"},
+		{"Zero", "0"},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set("X-User-Id", tc.header)
+			}
+			res := MakeRequest(req)
+			assert.Equal(T, http.StatusUnauthorized, res.Code)
+		})
+	}
+	mt.AssertNotCalled(T, "GetTodos", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetTodosScopedToCaller(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	mt.On("GetTodos", mock.Anything, otherUserID, mock.Anything).Return(&todo.Page{Items: []*todo.Todo{}}, nil)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	SetUser(req, otherUserID)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Get("/", todoHandlers.GetTodos)
+
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	mt.AssertExpectations(T)
+}
+
+func TestCreateTodoIgnoresBodyUserId(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	mt.On("CreateTodo", mock.Anything, mock.MatchedBy(func(t *todo.Todo) bool {
+		return t.UserID == testUserID
+	})).Return(&todo.Todo{Name: "Task", UserID: testUserID}, nil)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"name":   "Task",
+		"userid": otherUserID,
+	})
+	req, _ := http.NewRequest("POST", "/", bytes.NewBuffer(reqBody))
+	SetUser(req, testUserID)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Post("/", todoHandlers.CreateTodo)
+
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	mt.AssertExpectations(T)
+}
+
+func TestCrossUserAccess(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	mt.On("GetTodoById", mock.Anything, otherUserID, uint(1)).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+	mt.On("DeleteTodoById", mock.Anything, otherUserID, uint(1), todo.DeleteOptions{}).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Get("/{id}", todoHandlers.GetTodoById)
+	r.With(middlewares.WithIdentity).Put("/{id}", todoHandlers.UpdateTodo)
+	r.With(middlewares.WithIdentity).Delete("/{id}", todoHandlers.DeleteTodoById)
+
+	tt := []struct {
+		method string
+		body   []byte
+	}{
+		{"GET", nil},
+		{"PUT", []byte(`{"name":"Stolen"}`)},
+		{"DELETE", nil},
+	}
+	for _, tc := range tt {
+		T.Run(tc.method, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBuffer(tc.body))
+			SetUser(req, otherUserID)
+
+			res := MakeRequest(req)
+
+			assert.Equal(T, http.StatusNotFound, res.Code)
+			var errorResponse errors.ErrorResponse
+			err := json.NewDecoder(res.Body).Decode(&errorResponse)
+			assert.Nil(T, err)
+			assert.Equal(T, "Todo with ID 1 not found", errorResponse.Message)
+		})
+	}
+	mt.AssertExpectations(T)
+	mt.AssertNotCalled(T, "UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
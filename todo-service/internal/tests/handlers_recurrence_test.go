@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var seriesStart = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+func recurringTodo(rule string) *todo.Todo {
+	dueAt := seriesStart
+	start := seriesStart
+	existing := &todo.Todo{Name: "Water plants", UserID: testUserID, Status: todo.StatusOpen, Priority: todo.PriorityLow, DueAt: &dueAt, Recurrence: rule, RecurrenceStart: &start}
+	existing.ID = 1
+	return existing
+}
+
+func TestNextOccurrence(T *testing.T) {
+	existing := recurringTodo("FREQ=WEEKLY;BYDAY=MO,TH")
+	existing.Date = seriesStart.Add(-24 * time.Hour)
+
+	next, err := existing.NextOccurrence()
+	assert.Nil(T, err)
+	assert.Equal(T, time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC), *next.DueAt)
+	assert.Equal(T, time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), next.Date)
+	assert.Equal(T, todo.StatusOpen, next.Status)
+	assert.Equal(T, existing.Recurrence, next.Recurrence)
+	assert.Equal(T, seriesStart, *next.RecurrenceStart)
+
+	last := recurringTodo("FREQ=DAILY;COUNT=2")
+	dueAt := seriesStart.AddDate(0, 0, 1)
+	last.DueAt = &dueAt
+	next, err = last.NextOccurrence()
+	assert.Nil(T, err)
+	assert.Nil(T, next)
+}
+
+func TestCreateRecurringTodo(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("CreateTodo", mock.Anything, mock.MatchedBy(func(t *todo.Todo) bool {
+		return t.Recurrence == "FREQ=WEEKLY;BYDAY=MO" && t.RecurrenceStart != nil && t.RecurrenceStart.Equal(seriesStart) && t.NextID == nil
+	})).Return(&todo.Todo{Name: "Water plants"}, nil)
+
+	tt := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Created", `{"name":"Water plants","due_at":"2024-01-01T09:00:00Z","recurrence":"RRULE:freq=weekly;byday=mo","recurrence_start":"2000-01-01T00:00:00Z","next_id":7}`, http.StatusOK},
+		{"NoDueAt", `{"name":"Water plants","recurrence":"FREQ=DAILY"}`, http.StatusBadRequest},
+		{"InvalidRule", `{"name":"Water plants","due_at":"2024-01-01T09:00:00Z","recurrence":"FREQ=HOURLY"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "CreateTodo", 1)
+}
+
+func TestUpdateRecurrence(T *testing.T) {
+	InitServe()
+
+	tt := []struct {
+		name     string
+		existing *todo.Todo
+		body     string
+		expected int
+		fields   map[string]interface{}
+	}{
+		{"SetRule", recurringTodo(""), `{"recurrence":"FREQ=MONTHLY;BYDAY=-1FR"}`, http.StatusOK, map[string]interface{}{"recurrence": "FREQ=MONTHLY;BYDAY=-1FR", "recurrence_start": seriesStart}},
+		{"SetRuleAndDueAt", &todo.Todo{Name: "Task"}, `{"recurrence":"FREQ=DAILY","due_at":"2024-01-01T10:00:00+01:00"}`, http.StatusOK, map[string]interface{}{"recurrence": "FREQ=DAILY", "recurrence_start": seriesStart, "due_at": seriesStart}},
+		{"ClearRule", recurringTodo("FREQ=DAILY"), `{"recurrence":null}`, http.StatusOK, map[string]interface{}{"recurrence": "", "recurrence_start": nil}},
+		{"RuleWithoutDueAt", &todo.Todo{Name: "Task"}, `{"recurrence":"FREQ=DAILY"}`, http.StatusBadRequest, nil},
+		{"InvalidRule", recurringTodo(""), `{"recurrence":"FREQ=DAILY;COUNT=0"}`, http.StatusBadRequest, nil},
+		{"RuleNotAString", recurringTodo(""), `{"recurrence":1}`, http.StatusBadRequest, nil},
+		{"ClearDueAtOfRecurringTodo", recurringTodo("FREQ=DAILY"), `{"due_at":null}`, http.StatusBadRequest, nil},
+		{"RecurrenceStartIsReadOnly", recurringTodo("FREQ=DAILY"), `{"recurrence_start":"2024-01-01T00:00:00Z"}`, http.StatusBadRequest, nil},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			mt := new(todo.MockTodo)
+			route(mocks{todos: mt})
+			mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(tc.existing, nil)
+			mt.On("UpdateTodo", mock.Anything, testUserID, tc.existing, tc.fields).Return(tc.existing, nil)
+
+			req, _ := http.NewRequest("PUT", "/1", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+
+			assert.Equal(T, tc.expected, res.Code)
+			if tc.expected == http.StatusOK {
+				mt.AssertExpectations(T)
+			} else {
+				mt.AssertNotCalled(T, "UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestCompleteRecurringTodo(T *testing.T) {
+	InitServe()
+
+	tt := []struct {
+		name string
+		rule string
+		next *time.Time
+	}{
+		{"SchedulesNext", "FREQ=WEEKLY", &[]time.Time{seriesStart.AddDate(0, 0, 7)}[0]},
+		{"SeriesOver", "FREQ=WEEKLY;COUNT=1", nil},
+		{"NotRecurring", "", nil},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			mt := new(todo.MockTodo)
+			route(mocks{todos: mt})
+			existing := recurringTodo(tc.rule)
+			mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(existing, nil)
+			mt.On("UpdateTodo", mock.Anything, testUserID, existing, mock.Anything).Return(existing, nil)
+			mt.On("CompleteOccurrence", mock.Anything, testUserID, existing, mock.Anything, mock.MatchedBy(func(next *todo.Todo) bool {
+				return next.Name == existing.Name && next.Priority == existing.Priority && next.Status == todo.StatusOpen &&
+					next.DueAt.Equal(*tc.next) && next.Recurrence == tc.rule
+			})).Return(existing, nil)
+
+			req, _ := http.NewRequest("PUT", "/1", bytes.NewBufferString(`{"status":"done"}`))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+
+			assert.Equal(T, http.StatusOK, res.Code)
+			if tc.next != nil {
+				mt.AssertNumberOfCalls(T, "CompleteOccurrence", 1)
+				mt.AssertNotCalled(T, "UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				mt.AssertNumberOfCalls(T, "UpdateTodo", 1)
+				mt.AssertNotCalled(T, "CompleteOccurrence", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetOccurrences(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	existing := recurringTodo("FREQ=MONTHLY;BYMONTHDAY=-1")
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(existing, nil)
+	plain := &todo.Todo{Name: "Task"}
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(2)).Return(plain, nil)
+
+	req, _ := http.NewRequest("GET", "/1/occurrences?limit=3", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code)
+	var preview handlers.Occurrences
+	err := json.NewDecoder(res.Body).Decode(&preview)
+	assert.Nil(T, err)
+	assert.Equal(T, "FREQ=MONTHLY;BYMONTHDAY=-1", preview.Recurrence)
+	assert.Equal(T, []time.Time{
+		time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC),
+	}, preview.Occurrences)
+
+	tt := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"NotRecurring", "/2/occurrences", http.StatusConflict},
+		{"ZeroLimit", "/1/occurrences?limit=0", http.StatusBadRequest},
+		{"LimitTooHigh", "/1/occurrences?limit=101", http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
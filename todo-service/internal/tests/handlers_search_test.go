@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseSearchOptions(T *testing.T) {
+	opts, err := todo.ParseSearchOptions(url.Values{"q": {"  buy milk "}, "status": {"open"}, "limit": {"5"}}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, "buy milk", opts.Query)
+	assert.Equal(T, []string{todo.StatusOpen}, opts.Status)
+	assert.Equal(T, 5, opts.Limit)
+
+	result := &todo.SearchResult{Rank: 0.25}
+	result.ID = 7
+	cursor := opts.CursorAfter(result)
+	_, err = todo.ParseSearchOptions(url.Values{"q": {"buy milk"}, "cursor": {cursor}}, 100)
+	assert.Nil(T, err)
+
+	listOpts, _ := todo.ParseListOptions(url.Values{}, 100)
+	_, err = todo.ParseSearchOptions(url.Values{"q": {"milk"}, "cursor": {listOpts.CursorAfter(&result.Todo)}}, 100)
+	assert.Equal(T, todo.ErrInvalidCursor, err)
+
+	tt := []struct {
+		name   string
+		values url.Values
+	}{
+		{"MissingQuery", url.Values{}},
+		{"BlankQuery", url.Values{"q": {"   "}}},
+		{"LongQuery", url.Values{"q": {strings.Repeat("a", 257)}}},
+		{"Sort", url.Values{"q": {"milk"}, "sort": {"name"}}},
+		{"BadFilter", url.Values{"q": {"milk"}, "priority": {"9"}}},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			_, err := todo.ParseSearchOptions(tc.values, 100)
+			assert.NotNil(T, err)
+		})
+	}
+}
+
+func TestSearchTodos(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	result := &todo.SearchResult{Rank: 0.6, Snippet: "Buy <mark>milk</mark>"}
+	result.Name = "Buy milk"
+	mt.On("SearchTodos", mock.Anything, otherUserID, mock.MatchedBy(func(opts *todo.SearchOptions) bool {
+		return opts.Query == "milk" && opts.Limit == 10
+	})).Return(&todo.SearchPage{Items: []*todo.SearchResult{result}}, nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Get("/search", todoHandlers.SearchTodos)
+
+	req, _ := http.NewRequest("GET", "/search?q=milk&limit=10", nil)
+	SetUser(req, otherUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var page todo.SearchPage
+	err := json.NewDecoder(res.Body).Decode(&page)
+	assert.Nil(T, err)
+	assert.Equal(T, 1, len(page.Items))
+	assert.Equal(T, "Buy milk", page.Items[0].Name)
+	assert.Equal(T, "Buy <mark>milk</mark>", page.Items[0].Snippet)
+	assert.Empty(T, page.NextCursor)
+
+	req, _ = http.NewRequest("GET", "/search", nil)
+	SetUser(req, otherUserID)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusBadRequest, res.Code)
+
+	req, _ = http.NewRequest("GET", "/search?q=milk", nil)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusUnauthorized, res.Code)
+	mt.AssertNumberOfCalls(T, "SearchTodos", 1)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCanTransition(T *testing.T) {
+	tt := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{todo.StatusOpen, todo.StatusInProgress, true},
+		{todo.StatusOpen, todo.StatusDone, true},
+		{todo.StatusInProgress, todo.StatusDone, true},
+		{todo.StatusDone, todo.StatusOpen, true},
+		{todo.StatusDone, todo.StatusArchived, true},
+		{todo.StatusArchived, todo.StatusOpen, true},
+		{todo.StatusArchived, todo.StatusDone, false},
+		{todo.StatusArchived, todo.StatusInProgress, false},
+		{todo.StatusOpen, "closed", false},
+		{"", todo.StatusDone, true},
+		{todo.StatusDone, todo.StatusDone, true},
+	}
+	for _, tc := range tt {
+		T.Run(fmt.Sprintf("%s_%s", tc.from, tc.to), func(T *testing.T) {
+			assert.Equal(T, tc.expected, todo.CanTransition(tc.from, tc.to))
+		})
+	}
+}
+
+func TestCreateDoneTodoSetsCompletedAt(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	mt.On("CreateTodo", mock.Anything, mock.MatchedBy(func(t *todo.Todo) bool {
+		return t.Status == todo.StatusDone && t.Priority == todo.PriorityHigh && t.CompletedAt != nil
+	})).Return(&todo.Todo{Name: "Task", Status: todo.StatusDone}, nil)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"name":         "Task",
+		"status":       "done",
+		"priority":     3,
+		"completed_at": "2000-01-01T00:00:00Z",
+	})
+	req, _ := http.NewRequest("POST", "/", bytes.NewBuffer(reqBody))
+	SetUser(req, testUserID)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Post("/", todoHandlers.CreateTodo)
+
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	mt.AssertExpectations(T)
+}
+
+func TestCreateTodoValidation(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Post("/", todoHandlers.CreateTodo)
+
+	tt := []struct {
+		name string
+		body string
+	}{
+		{"UnknownStatus", `{"name":"Task","status":"closed"}`},
+		{"PriorityTooHigh", `{"name":"Task","priority":4}`},
+		{"NegativePriority", `{"name":"Task","priority":-1}`},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, http.StatusBadRequest, res.Code)
+		})
+	}
+	mt.AssertNotCalled(T, "CreateTodo", mock.Anything, mock.Anything)
+}
+
+func TestUpdateStatus(T *testing.T) {
+	InitServe()
+	r := server.GetRouter()
+
+	tt := []struct {
+		name      string
+		current   string
+		body      string
+		expected  int
+		completed interface{}
+	}{
+		{"OpenToDone", todo.StatusOpen, `{"status":"done"}`, http.StatusOK, "set"},
+		{"LegacyToInProgress", "", `{"status":"in_progress"}`, http.StatusOK, "absent"},
+		{"ReopenDone", todo.StatusDone, `{"status":"open"}`, http.StatusOK, nil},
+		{"DoneStaysDone", todo.StatusDone, `{"status":"done","name":"Renamed"}`, http.StatusOK, "absent"},
+		{"ArchivedToDone", todo.StatusArchived, `{"status":"done"}`, http.StatusConflict, "absent"},
+		{"UnknownStatus", todo.StatusOpen, `{"status":"closed"}`, http.StatusBadRequest, "absent"},
+		{"StatusNotAString", todo.StatusOpen, `{"status":1}`, http.StatusBadRequest, "absent"},
+		{"Priority", todo.StatusOpen, `{"priority":2}`, http.StatusOK, "absent"},
+		{"FractionalPriority", todo.StatusOpen, `{"priority":1.5}`, http.StatusBadRequest, "absent"},
+		{"DueAt", todo.StatusOpen, `{"due_at":"2030-01-02T15:04:05+01:00"}`, http.StatusOK, "absent"},
+		{"ClearDueAt", todo.StatusOpen, `{"due_at":null}`, http.StatusOK, "absent"},
+		{"InvalidDueAt", todo.StatusOpen, `{"due_at":"tomorrow"}`, http.StatusBadRequest, "absent"},
+		{"CompletedAtIsReadOnly", todo.StatusDone, `{"completed_at":null}`, http.StatusBadRequest, "absent"},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			mt := new(todo.MockTodo)
+			todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
+			r.With(middlewares.WithIdentity).Put("/{id}", todoHandlers.UpdateTodo)
+			existing := &todo.Todo{Name: "Task", Status: tc.current}
+			existing.ID = 1
+			mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(existing, nil)
+			var fields map[string]interface{}
+			mt.On("UpdateTodo", mock.Anything, testUserID, existing, mock.Anything).Return(existing, nil).Run(func(args mock.Arguments) {
+				fields = args.Get(3).(map[string]interface{})
+			})
+
+			req, _ := http.NewRequest("PUT", "/1", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+
+			assert.Equal(T, tc.expected, res.Code)
+			if tc.expected != http.StatusOK {
+				mt.AssertNotCalled(T, "UpdateTodo", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			completedAt, ok := fields["completed_at"]
+			switch tc.completed {
+			case "set":
+				assert.IsType(T, time.Time{}, completedAt)
+			case "absent":
+				assert.False(T, ok)
+			default:
+				assert.True(T, ok)
+				assert.Nil(T, completedAt)
+			}
+			if tc.name == "Priority" {
+				assert.Equal(T, 2, fields["priority"])
+			}
+			if tc.name == "DueAt" {
+				assert.Equal(T, time.Date(2030, 1, 2, 14, 4, 5, 0, time.UTC), fields["due_at"])
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetChanges(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(versionedTodo(1), nil)
+	mt.On("GetChanges", mock.Anything, testUserID, uint64(7), todo.DefaultPageSize).Return(&todo.ChangePage{
+		Changes: []*todo.Change{
+			{Seq: 8, ID: 1, Todo: versionedTodo(1)},
+			{Seq: 9, ID: 2, Deleted: true},
+		},
+		SyncToken: "9",
+	}, nil)
+	tag := currentETag(T)
+
+	req, _ := http.NewRequest("GET", "/changes?since=7", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	page := new(todo.ChangePage)
+	json.NewDecoder(res.Body).Decode(page)
+	assert.Equal(T, "9", page.SyncToken)
+	assert.Len(T, page.Changes, 2)
+	assert.Equal(T, tag, page.Changes[0].ETag, "the ETag GET /1 answers with")
+	assert.True(T, page.Changes[1].Deleted)
+	assert.Empty(T, page.Changes[1].ETag)
+}
+
+func TestGetChangesErrors(T *testing.T) {
+	tt := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{"Expired", "?since=3", http.StatusGone},
+		{"InvalidToken", "?since=abc", http.StatusBadRequest},
+		{"InvalidLimit", "?limit=0", http.StatusBadRequest},
+		{"LimitTooLarge", "?limit=100000", http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			mt := new(todo.MockTodo)
+			route(mocks{todos: mt})
+			mt.On("GetChanges", mock.Anything, testUserID, uint64(3), mock.Anything).Return((*todo.ChangePage)(nil), todo.ErrSyncTokenExpired)
+
+			req, _ := http.NewRequest("GET", "/changes"+tc.query, nil)
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
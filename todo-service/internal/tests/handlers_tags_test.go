@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCreateTag(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mg := new(tag.MockTag)
+	route(mocks{todos: mt, tags: mg})
+	mg.On("CreateTag", mock.Anything, &tag.Tag{UserID: testUserID, Name: "work", Color: "#ff8800"}).Return(&tag.Tag{ID: 1, UserID: testUserID, Name: "work"}, nil)
+	mg.On("CreateTag", mock.Anything, &tag.Tag{UserID: testUserID, Name: "home"}).Return((*tag.Tag)(nil), tag.ErrDuplicateTag)
+
+	tt := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Created", `{"name":" work ","color":"#ff8800","userid":2,"usage":5}`, http.StatusCreated},
+		{"Duplicate", `{"name":"home"}`, http.StatusConflict},
+		{"BlankName", `{"name":" "}`, http.StatusBadRequest},
+		{"Comma", `{"name":"a,b"}`, http.StatusBadRequest},
+		{"LongName", `{"name":"abcdefghijklmnopqrstuvwxyz0123456"}`, http.StatusBadRequest},
+		{"BadColor", `{"name":"x","color":"red"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("POST", "/tags", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mg.AssertExpectations(T)
+}
+
+func TestGetTagsWithUsage(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mg := new(tag.MockTag)
+	route(mocks{todos: mt, tags: mg})
+	mg.On("GetTags", mock.Anything, testUserID).Return([]*tag.Tag{{ID: 1, Name: "home", Usage: 0}, {ID: 2, Name: "work", Usage: 3}}, nil)
+
+	req, _ := http.NewRequest("GET", "/tags", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var tags []*tag.Tag
+	err := json.NewDecoder(res.Body).Decode(&tags)
+	assert.Nil(T, err)
+	assert.Equal(T, int64(3), tags[1].Usage)
+}
+
+func TestUpdateAndDeleteTag(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mg := new(tag.MockTag)
+	route(mocks{todos: mt, tags: mg})
+	existing := ownTag(mg, 1)
+	mg.On("UpdateTag", mock.Anything, existing, map[string]interface{}{"name": "office"}).Return(existing, nil)
+	mg.On("DeleteTagById", mock.Anything, otherUserID, uint(1)).Return((*tag.Tag)(nil), gorm.ErrRecordNotFound)
+
+	tt := []struct {
+		name     string
+		method   string
+		user     uint
+		body     string
+		expected int
+	}{
+		{"Rename", "PUT", testUserID, `{"name":"office "}`, http.StatusOK},
+		{"ExtraField", "PUT", testUserID, `{"userid":2}`, http.StatusBadRequest},
+		{"ColorNotAString", "PUT", testUserID, `{"color":12}`, http.StatusBadRequest},
+		{"OtherUsersTag", "PUT", otherUserID, `{"name":"mine"}`, http.StatusNotFound},
+		{"DeleteOtherUsersTag", "DELETE", otherUserID, "", http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/tags/1", bytes.NewBufferString(tc.body))
+			SetUser(req, tc.user)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mg.AssertNumberOfCalls(T, "UpdateTag", 1)
+}
+
+func TestAttachAndDetachTag(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mg := new(tag.MockTag)
+	route(mocks{todos: mt, tags: mg})
+	ownTodo(mt, 5)
+	ownTag(mg, 1)
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(6)).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+	mg.On("AttachTag", mock.Anything, uint(5), uint(1)).Return(nil)
+	mg.On("DetachTag", mock.Anything, uint(5), uint(1)).Return(nil)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		user     uint
+		expected int
+	}{
+		{"Attach", "POST", "/5/tags/1", testUserID, http.StatusNoContent},
+		{"Detach", "DELETE", "/5/tags/1", testUserID, http.StatusNoContent},
+		{"OtherUsersTodo", "POST", "/6/tags/1", testUserID, http.StatusNotFound},
+		{"OtherUsersTag", "POST", "/5/tags/1", otherUserID, http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			SetUser(req, tc.user)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mg.AssertNumberOfCalls(T, "AttachTag", 1)
+	mg.AssertNumberOfCalls(T, "DetachTag", 1)
+}
+
+func TestTagFilters(T *testing.T) {
+	opts, err := todo.ParseListOptions(url.Values{"tag": {"work,home", "errands"}, "tag_mode": {"all"}}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, []string{"work", "home", "errands"}, opts.Tags)
+	assert.True(T, opts.AllTags)
+
+	opts, err = todo.ParseListOptions(url.Values{"tag": {"work"}}, 100)
+	assert.Nil(T, err)
+	assert.False(T, opts.AllTags)
+
+	_, err = todo.ParseListOptions(url.Values{"tag": {"work"}, "tag_mode": {"some"}}, 100)
+	assert.NotNil(T, err)
+}
+
+func TestTodosCarryTags(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mg := new(tag.MockTag)
+	route(mocks{todos: mt, tags: mg})
+	existing := ownTodo(mt, 5)
+	mg.On("TagsOf", mock.Anything, testUserID, []uint{5}).Return(map[uint][]*tag.Tag{5: {{ID: 1, Name: "work"}}}, nil)
+
+	req, _ := http.NewRequest("GET", "/5", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var got todo.Todo
+	err := json.NewDecoder(res.Body).Decode(&got)
+	assert.Nil(T, err)
+	assert.Equal(T, existing.ID, got.ID)
+	assert.Equal(T, "work", got.Tags[0].Name)
+}
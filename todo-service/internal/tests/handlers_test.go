@@ -10,8 +10,12 @@ import (
 	"time"
 
 	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/tag"
 	"github.com/ennemli/todo/todo/internal/models/todo"
 	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/pkg/identity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -27,14 +31,35 @@ func InitServe() {
 	server.NewServer()
 }
 
+const testUserID = uint(1)
+
+func SetUser(req *http.Request, userID uint) {
+	req.Header.Set(identity.HeaderUserID, fmt.Sprintf("%d", userID))
+}
+
+// newItemMock returns an item store in which no todo has a checklist.
+func newItemMock() *item.MockItem {
+	mi := new(item.MockItem)
+	mi.On("Progress", mock.Anything, mock.Anything).Return(map[uint]int{}, nil).Maybe()
+	return mi
+}
+
+// newTagMock returns a tag store in which no todo has tags.
+func newTagMock() *tag.MockTag {
+	mg := new(tag.MockTag)
+	mg.On("TagsOf", mock.Anything, mock.Anything, mock.Anything).Return(map[uint][]*tag.Tag{}, nil).Maybe()
+	return mg
+}
+
 func TestCreateTodo(T *testing.T) {
 	InitServe()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	expectedTodo := &todo.Todo{
 		Date:        time.Date(2024, 5, 24, 9, 57, 38, 0, time.UTC),
 		Name:        "Task 5",
 		Description: "Do Task 1",
+		UserID:      testUserID,
 	}
 
 	mt.On("CreateTodo", mock.Anything, expectedTodo).Return(expectedTodo, nil)
@@ -42,9 +67,10 @@ func TestCreateTodo(T *testing.T) {
 	reqBody, _ := json.Marshal(expectedTodo)
 	req, _ := http.NewRequest("POST", "/", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	r := server.GetRouter()
-	r.Post("/", todoHandlers.CreateTodo)
+	r.With(middlewares.WithIdentity).Post("/", todoHandlers.CreateTodo)
 
 	res := MakeRequest(req)
 
@@ -64,29 +90,30 @@ func TestGetTodos(T *testing.T) {
 	InitServe()
 	r := server.GetRouter()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	expectedTodos := []*todo.Todo{
 		{Name: "Task 1", Description: "Description 1"},
 		{Name: "Task 2", Description: "Description 2"},
 	}
 	expectedTodos[0].ID = 1
 	expectedTodos[1].ID = 2
-	mt.On("GetTodos", mock.Anything).Return(expectedTodos, nil)
+	mt.On("GetTodos", mock.Anything, testUserID, mock.Anything).Return(&todo.Page{Items: expectedTodos}, nil)
 
 	req, _ := http.NewRequest("GET", "/", nil)
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
-	r.Get("/", todoHandlers.GetTodos)
+	r.With(middlewares.WithIdentity).Get("/", todoHandlers.GetTodos)
 
 	res := MakeRequest(req)
 
 	assert.Equal(T, http.StatusOK, res.Code)
 
-	var todos []*todo.Todo
-	err := json.NewDecoder(res.Body).Decode(&todos)
+	var page todo.Page
+	err := json.NewDecoder(res.Body).Decode(&page)
 	assert.Nil(T, err)
 
-	assert.Equal(T, len(expectedTodos), len(todos))
+	assert.Equal(T, len(expectedTodos), len(page.Items))
 
 	mt.AssertExpectations(T)
 }
@@ -94,20 +121,21 @@ func TestGetTodos(T *testing.T) {
 func TestGetTodoById(T *testing.T) {
 	InitServe()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	expectedTodo := &todo.Todo{
 		Name:        "Task 1",
 		Description: "Description 1",
 	}
 	todoID := uint(1)
 	expectedTodo.ID = todoID
-	mt.On("GetTodoById", mock.Anything, todoID).Return(expectedTodo, nil)
+	mt.On("GetTodoById", mock.Anything, testUserID, todoID).Return(expectedTodo, nil)
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/%d", todoID), nil)
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	r := server.GetRouter()
-	r.Get("/{id}", todoHandlers.GetTodoById)
+	r.With(middlewares.WithIdentity).Get("/{id}", todoHandlers.GetTodoById)
 
 	res := MakeRequest(req)
 
@@ -127,7 +155,7 @@ func TestGetTodoById(T *testing.T) {
 func TestDeleteTodoById(T *testing.T) {
 	InitServe()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	expectedTodo := &todo.Todo{
 		Name:        "Task 1",
 		Description: "Description 1",
@@ -135,13 +163,14 @@ func TestDeleteTodoById(T *testing.T) {
 	todoID := uint(1)
 
 	expectedTodo.ID = todoID
-	mt.On("DeleteTodoById", mock.Anything, todoID).Return(expectedTodo, nil)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, todoID, todo.DeleteOptions{}).Return(expectedTodo, nil)
 
 	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/%d", todoID), nil)
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	r := server.GetRouter()
-	r.Delete("/{id}", todoHandlers.DeleteTodoById)
+	r.With(middlewares.WithIdentity).Delete("/{id}", todoHandlers.DeleteTodoById)
 
 	res := MakeRequest(req)
 
@@ -161,7 +190,7 @@ func TestDeleteTodoById(T *testing.T) {
 func TestUpdateTodo(T *testing.T) {
 	InitServe()
 	mt := new(todo.MockTodo)
-	todoHandlers := handlers.NewTodoHandlers(mt)
+	todoHandlers := handlers.NewTodoHandlers(mt, newItemMock(), newTagMock())
 	todoID := uint(1)
 
 	existingTodo := &todo.Todo{
@@ -174,20 +203,21 @@ func TestUpdateTodo(T *testing.T) {
 	}
 	expectedTodo := *existingTodo
 	expectedTodo.Name = "Updated Todo"
-	mt.On("GetTodoById", mock.Anything, todoID).Return(existingTodo, nil)
+	mt.On("GetTodoById", mock.Anything, testUserID, todoID).Return(existingTodo, nil)
 
-	mt.On("UpdateTodo", mock.Anything, existingTodo, updatedFields).Return(&expectedTodo, nil).Run(func(args mock.Arguments) {
-		t := args.Get(1).(*todo.Todo)
-		fields := args.Get(2).(map[string]interface{})
+	mt.On("UpdateTodo", mock.Anything, testUserID, existingTodo, updatedFields).Return(&expectedTodo, nil).Run(func(args mock.Arguments) {
+		t := args.Get(2).(*todo.Todo)
+		fields := args.Get(3).(map[string]interface{})
 		t.Name = fields["name"].(string)
 	})
 
 	reqBody, _ := json.Marshal(updatedFields)
 	req, _ := http.NewRequest("PUT", fmt.Sprintf("/%d", todoID), bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	SetUser(req, testUserID)
 
 	r := server.GetRouter()
-	r.Put("/{id}", todoHandlers.UpdateTodo)
+	r.With(middlewares.WithIdentity).Put("/{id}", todoHandlers.UpdateTodo)
 
 	res := MakeRequest(req)
 
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/pkg/ical"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var transferDue = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// exportedTodo is a todo with every field a file holds.
+func exportedTodo() *todo.Todo {
+	externalID := "task-1"
+	due := transferDue
+	exported := &todo.Todo{
+		Name:        "Buy milk, eggs",
+		Description: "Two lines;\nof description",
+		Date:        transferDue.Add(-24 * time.Hour),
+		UserID:      testUserID,
+		Status:      todo.StatusInProgress,
+		Priority:    todo.PriorityHigh,
+		DueAt:       &due,
+		Recurrence:  "FREQ=WEEKLY",
+		ExternalID:  &externalID,
+		Version:     1,
+	}
+	exported.ID = 1
+	return exported
+}
+
+func export(format string) (int, http.Header, string) {
+	req, _ := http.NewRequest("GET", "/export?format="+format, nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	return res.Code, res.Header(), res.Body.String()
+}
+
+func TestExport(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	other := &todo.Todo{Name: "Other", UserID: testUserID, Status: todo.StatusOpen}
+	other.ID = 2
+	mt.On("ExportTodos", mock.Anything, testUserID).Return([]*todo.Todo{exportedTodo(), other}, nil)
+
+	T.Run("JSON", func(T *testing.T) {
+		status, header, body := export("json")
+		assert.Equal(T, http.StatusOK, status)
+		assert.Equal(T, "application/json", header.Get("Content-Type"))
+		assert.Equal(T, `attachment; filename="todos.json"`, header.Get("Content-Disposition"))
+		var todos []*todo.Todo
+		assert.Nil(T, json.Unmarshal([]byte(body), &todos))
+		assert.Len(T, todos, 2)
+		assert.Equal(T, "task-1", *todos[0].ExternalID)
+		assert.Equal(T, "Other", todos[1].Name)
+	})
+
+	T.Run("CSV", func(T *testing.T) {
+		status, header, body := export("csv")
+		assert.Equal(T, http.StatusOK, status)
+		assert.Equal(T, "text/csv", header.Get("Content-Type"))
+		records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		assert.Nil(T, err)
+		assert.Len(T, records, 3)
+		assert.Equal(T, "external_id", records[0][1])
+		assert.Equal(T, []string{"1", "task-1", "Buy milk, eggs", "Two lines;\nof description", "2024-02-29T09:00:00Z", "in_progress", "3", "2024-03-01T09:00:00Z", "", "FREQ=WEEKLY", ""}, records[1])
+		assert.Equal(T, "", records[2][4], "a todo without a date")
+	})
+
+	T.Run("ICS", func(T *testing.T) {
+		status, header, body := export("ics")
+		assert.Equal(T, http.StatusOK, status)
+		assert.Equal(T, "text/calendar", header.Get("Content-Type"))
+		calendar, err := ical.Parse(strings.NewReader(body))
+		assert.Nil(T, err)
+		assert.Len(T, calendar.Components, 2)
+		vtodo := calendar.Components[0]
+		assert.Equal(T, "VTODO", vtodo.Name)
+		assert.Equal(T, "task-1", vtodo.Get("UID").Text())
+		assert.Equal(T, "Buy milk, eggs", vtodo.Get("SUMMARY").Text())
+		assert.Equal(T, "Two lines;\nof description", vtodo.Get("DESCRIPTION").Text())
+		assert.Equal(T, "20240229T090000Z", vtodo.Get("DTSTART").Value)
+		assert.Equal(T, "20240301T090000Z", vtodo.Get("DUE").Value)
+		assert.Equal(T, "IN-PROCESS", vtodo.Get("STATUS").Value)
+		assert.Equal(T, "1", vtodo.Get("PRIORITY").Value)
+		assert.Equal(T, "FREQ=WEEKLY", vtodo.Get("RRULE").Value)
+		assert.Equal(T, "todo-2", calendar.Components[1].Get("UID").Value)
+		assert.Nil(T, calendar.Components[1].Get("DTSTART"))
+	})
+}
+
+func TestExportEmpty(T *testing.T) {
+	tt := []struct {
+		format   string
+		expected string
+	}{
+		{"json", "[]\n"},
+		{"csv", "id,external_id,name,description,date,status,priority,due_at,completed_at,recurrence,list_id\n"},
+		{"ics", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ennemli//todo//EN\r\nEND:VCALENDAR\r\n"},
+	}
+	for _, tc := range tt {
+		T.Run(tc.format, func(T *testing.T) {
+			InitServe()
+			mt := new(todo.MockTodo)
+			route(mocks{todos: mt})
+			mt.On("ExportTodos", mock.Anything, testUserID).Return([]*todo.Todo{}, nil)
+
+			status, _, body := export(tc.format)
+
+			assert.Equal(T, http.StatusOK, status)
+			assert.Equal(T, tc.expected, body)
+		})
+	}
+}
+
+func TestExportErrors(T *testing.T) {
+	tt := []struct {
+		name     string
+		format   string
+		err      error
+		expected int
+	}{
+		{"InvalidFormat", "xml", nil, http.StatusBadRequest},
+		{"StoreFails", "json", fmt.Errorf("connection lost"), http.StatusInternalServerError},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			mt := new(todo.MockTodo)
+			route(mocks{todos: mt})
+			mt.On("ExportTodos", mock.Anything, testUserID).Return([]*todo.Todo(nil), tc.err)
+
+			status, _, _ := export(tc.format)
+
+			assert.Equal(T, tc.expected, status)
+		})
+	}
+}
+
+func postImport(query string, contentType string, body string) (int, *handlers.ImportResult) {
+	req, _ := http.NewRequest("POST", "/import"+query, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+	result := new(handlers.ImportResult)
+	json.NewDecoder(res.Body).Decode(result)
+	return res.Code, result
+}
+
+func importStatuses(result *handlers.ImportResult) []string {
+	statuses := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		statuses[i] = row.Status
+	}
+	return statuses
+}
+
+func TestImport(T *testing.T) {
+	tt := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+	}{
+		{"JSON", "", "application/json", `[
+			{"id": 40, "userid": 9, "version": 4, "external_id": "task-1", "name": "Buy milk, eggs", "description": "Two lines;\nof description", "date": "2024-02-29T09:00:00Z", "status": "in_progress", "priority": 3, "due_at": "2024-03-01T09:00:00Z", "recurrence": "FREQ=WEEKLY"},
+			{"external_id": "taken", "name": "Duplicate"}
+		]`},
+		{"CSV", "?format=csv", "text/plain", "\xef\xbb\xbfName,External_ID,Description,Date,Status,Priority,Due_At,Recurrence,Unknown\n" +
+			"\"Buy milk, eggs\",task-1,\"Two lines;\nof description\",2024-02-29T09:00:00Z,in_progress,3,2024-03-01T09:00:00Z,FREQ=WEEKLY,x\n" +
+			"Duplicate,taken\n"},
+		{"ICS", "", "text/calendar; charset=utf-8", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+			"BEGIN:VTIMEZONE\r\nTZID:Europe/Paris\r\nEND:VTIMEZONE\r\n" +
+			"BEGIN:VTODO\r\nUID:task-1\r\nSUMMARY:Buy milk\\, eggs\r\nDESCRIPTION:Two lines\\;\\nof descr\r\n iption\r\n" +
+			"DTSTART;TZID=Europe/Paris:20240229T100000\r\nDUE:20240301T090000Z\r\nSTATUS:IN-PROCESS\r\nPRIORITY:2\r\nRRULE:FREQ=WEEKLY\r\nEND:VTODO\r\n" +
+			"BEGIN:VTODO\r\nUID:taken\r\nSUMMARY:Duplicate\r\nEND:VTODO\r\n" +
+			"END:VCALENDAR\r\n"},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			var created []*todo.Todo
+			mt := importMock(&created)
+			route(mocks{todos: mt})
+
+			status, result := postImport(tc.query, tc.contentType, tc.body)
+
+			assert.Equal(T, http.StatusOK, status)
+			assert.Equal(T, []string{handlers.ImportCreated, handlers.ImportSkipped}, importStatuses(result))
+			assert.Equal(T, 1, result.Created)
+			assert.Equal(T, 1, result.Skipped)
+			assert.Equal(T, uint(7), result.Rows[0].ID)
+			assert.Equal(T, "taken", result.Rows[1].ExternalID)
+			if assert.Len(T, created, 1) {
+				imported := created[0]
+				assert.Zero(T, imported.ID)
+				assert.Equal(T, testUserID, imported.UserID)
+				assert.Equal(T, "task-1", *imported.ExternalID)
+				assert.Equal(T, "Buy milk, eggs", imported.Name)
+				assert.Equal(T, "Two lines;\nof description", imported.Description)
+				assert.True(T, imported.Date.Equal(transferDue.Add(-24*time.Hour)))
+				assert.Equal(T, todo.StatusInProgress, imported.Status)
+				assert.Equal(T, todo.PriorityHigh, imported.Priority)
+				assert.True(T, imported.DueAt.Equal(transferDue))
+				assert.Equal(T, "FREQ=WEEKLY", imported.Recurrence)
+			}
+		})
+	}
+}
+
+func TestImportInvalidRows(T *testing.T) {
+	body := "name,status,priority,date\n" +
+		"Fine,open,1,2024-03-01\n" +
+		",done,high,yesterday\n" +
+		"Bad status,closed,0,\n"
+	tt := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"Import", "?format=csv", http.StatusUnprocessableEntity},
+		{"DryRun", "?format=csv&dry_run=true", http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			var created []*todo.Todo
+			mt := importMock(&created)
+			route(mocks{todos: mt})
+
+			status, result := postImport(tc.query, "text/csv", body)
+
+			assert.Equal(T, tc.status, status)
+			assert.Equal(T, []string{handlers.ImportCreated, handlers.ImportInvalid, handlers.ImportInvalid}, importStatuses(result))
+			assert.Zero(T, result.Rows[0].ID, "nothing is created")
+			assert.Equal(T, []string{`Invalid date "yesterday"`, `Invalid priority "high"`, "A todo needs a name"}, result.Rows[1].Errors)
+			assert.Equal(T, []string{`Invalid status "closed"`}, result.Rows[2].Errors)
+			assert.Equal(T, 2, result.Invalid)
+			assert.Equal(T, 3, result.Rows[2].Row)
+		})
+	}
+}
+
+func TestImportErrors(T *testing.T) {
+	tt := []struct {
+		name     string
+		query    string
+		body     string
+		maxSize  string
+		expected int
+	}{
+		{"InvalidFormat", "?format=xml", `[]`, "", http.StatusBadRequest},
+		{"InvalidDryRun", "?dry_run=maybe", `[]`, "", http.StatusBadRequest},
+		{"InvalidJSON", "", `{"name": "Not an array"}`, "", http.StatusBadRequest},
+		{"CSVWithoutName", "?format=csv", "title\nTask\n", "", http.StatusBadRequest},
+		{"InvalidICS", "?format=ics", "BEGIN:VCALENDAR\r\n", "", http.StatusBadRequest},
+		{"NotACalendar", "?format=ics", "BEGIN:VTODO\r\nEND:VTODO\r\n", "", http.StatusBadRequest},
+		{"TooLarge", "", `[{"name": "Task"}]`, "10B", http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			InitServe()
+			var created []*todo.Todo
+			mt := importMock(&created)
+			route(mocks{todos: mt})
+			if tc.maxSize != "" {
+				viper.Set("MAX_IMPORT_SIZE", tc.maxSize)
+				T.Cleanup(func() { viper.Set("MAX_IMPORT_SIZE", "") })
+			}
+
+			status, _ := postImport(tc.query, "application/json", tc.body)
+
+			assert.Equal(T, tc.expected, status)
+			mt.AssertNotCalled(T, "Transaction", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestGetTrash(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("GetTrash", mock.Anything, testUserID).Return([]*todo.Todo{deletedTodo(2), deletedTodo(1)}, nil)
+
+	req, _ := http.NewRequest("GET", "/trash", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var todos []*todo.Todo
+	assert.Nil(T, json.NewDecoder(res.Body).Decode(&todos))
+	assert.Len(T, todos, 2)
+	assert.Equal(T, uint(2), todos[0].ID)
+	assert.True(T, todos[0].DeletedAt.Valid)
+}
+
+func TestDeleteReturnsTheDeletedTodo(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{}).Return(deletedTodo(1), nil)
+
+	req, _ := http.NewRequest("DELETE", "/1", nil)
+	SetUser(req, testUserID)
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	var deleted todo.Todo
+	assert.Nil(T, json.NewDecoder(res.Body).Decode(&deleted))
+	assert.Equal(T, "Task", deleted.Name)
+	assert.True(T, deleted.DeletedAt.Valid)
+}
+
+func TestRestoreAndPurge(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	restored := deletedTodo(1)
+	restored.DeletedAt = gorm.DeletedAt{}
+	mt.On("RestoreTodoById", mock.Anything, testUserID, uint(1)).Return(restored, nil)
+	mt.On("RestoreTodoById", mock.Anything, testUserID, uint(2)).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+	mt.On("PurgeTodoById", mock.Anything, testUserID, uint(1)).Return(deletedTodo(1), nil)
+	mt.On("PurgeTodoById", mock.Anything, testUserID, uint(2)).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{"Restore", "POST", "/1/restore", http.StatusOK},
+		{"RestoreNotInTrash", "POST", "/2/restore", http.StatusNotFound},
+		{"RestoreInvalidID", "POST", "/x/restore", http.StatusBadRequest},
+		{"Purge", "DELETE", "/trash/1", http.StatusOK},
+		{"PurgeNotInTrash", "DELETE", "/trash/2", http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
+
+func TestPurge(T *testing.T) {
+	mt := new(todo.MockTodo)
+	ctx, cancel := context.WithCancel(context.Background())
+	retention := 24 * time.Hour
+	start := time.Now()
+	expired := mock.MatchedBy(func(before time.Time) bool {
+		return !before.Before(start.Add(-retention)) && !before.After(time.Now().Add(-retention))
+	})
+	mt.On("PurgeTrash", mock.Anything, expired).Return(int64(3), nil)
+	mt.On("PurgeUsers", mock.Anything, expired).Return(int64(1), nil).Run(func(args mock.Arguments) { cancel() })
+
+	todo.Purge(ctx, mt, retention, time.Hour)
+
+	mt.AssertNumberOfCalls(T, "PurgeTrash", 1)
+	mt.AssertNumberOfCalls(T, "PurgeUsers", 1)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAssignTodo(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mu := new(users.MockClient)
+	route(mocks{todos: mt, users: mu})
+	mine := ownTodo(mt, 1)
+	me := testUserID
+	todoWithRole(mt, 2, list.RoleViewer, nil)
+	todoWithRole(mt, 3, todo.RoleAssignee, &me)
+	mu.On("GetUser", mock.Anything, uint(2)).Return(&users.User{ID: 2, Name: "Casca"}, nil)
+	mu.On("GetUser", mock.Anything, uint(4)).Return((*users.User)(nil), users.ErrUserNotFound)
+	mu.On("GetUser", mock.Anything, uint(5)).Return((*users.User)(nil), users.ErrUnavailable)
+	mt.On("UpdateTodo", mock.Anything, testUserID, mine, map[string]interface{}{"assignee_id": uint(2)}).Return(mine, nil)
+
+	tt := []struct {
+		name     string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Assigned", "/1/assignee", `{"assignee_id":2}`, http.StatusOK},
+		{"UnknownUser", "/1/assignee", `{"assignee_id":4}`, http.StatusNotFound},
+		{"UserServiceDown", "/1/assignee", `{"assignee_id":5}`, http.StatusServiceUnavailable},
+		{"NoAssignee", "/1/assignee", `{}`, http.StatusBadRequest},
+		{"Viewer", "/2/assignee", `{"assignee_id":2}`, http.StatusForbidden},
+		{"Assignee", "/3/assignee", `{"assignee_id":2}`, http.StatusForbidden},
+		{"NotVisible", "/1/assignee", `{"assignee_id":2}`, http.StatusNotFound},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("PUT", tc.path, bytes.NewBufferString(tc.body))
+			userID := testUserID
+			if tc.name == "NotVisible" {
+				userID = otherUserID
+			}
+			SetUser(req, userID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "UpdateTodo", 1)
+	mu.AssertNumberOfCalls(T, "GetUser", 3)
+}
+
+func TestUnassignTodo(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	mu := new(users.MockClient)
+	route(mocks{todos: mt, users: mu})
+	me, someone := testUserID, uint(4)
+	mine := ownTodo(mt, 1)
+	assigned := todoWithRole(mt, 2, todo.RoleAssignee, &me)
+	todoWithRole(mt, 3, list.RoleViewer, &someone)
+	mt.On("UpdateTodo", mock.Anything, testUserID, mine, map[string]interface{}{"assignee_id": nil}).Return(mine, nil)
+	mt.On("UpdateTodo", mock.Anything, testUserID, assigned, map[string]interface{}{"assignee_id": nil}).Return(assigned, nil)
+
+	tt := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"Owner", "/1/assignee", http.StatusOK},
+		{"AssigneeHandsBack", "/2/assignee", http.StatusOK},
+		{"Viewer", "/3/assignee", http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("DELETE", tc.path, nil)
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mu.AssertNotCalled(T, "GetUser", mock.Anything, mock.Anything)
+}
+
+func TestAssigneesCannotMoveOrDelete(T *testing.T) {
+	InitServe()
+	mt := new(todo.MockTodo)
+	route(mocks{todos: mt})
+	me := testUserID
+	assigned := todoWithRole(mt, 1, todo.RoleAssignee, &me)
+	mt.On("UpdateTodo", mock.Anything, testUserID, assigned, map[string]interface{}{"status": todo.StatusInProgress}).Return(assigned, nil)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(1), todo.DeleteOptions{}).Return((*todo.Todo)(nil), todo.ErrAssignee)
+
+	tt := []struct {
+		name     string
+		method   string
+		body     string
+		expected int
+	}{
+		{"ChangeStatus", "PUT", `{"status":"in_progress"}`, http.StatusOK},
+		{"Move", "PUT", `{"list_id":3}`, http.StatusForbidden},
+		{"Delete", "DELETE", "", http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(tc.body))
+			SetUser(req, testUserID)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "UpdateTodo", 1)
+}
+
+func TestUsersClient(T *testing.T) {
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users/internal/1":
+			w.Write([]byte(`{"id":1,"name":"Guts"}`))
+		case "/api/users/internal/2":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	client := users.NewClient(userService.URL + "/")
+
+	userItem, err := client.GetUser(context.Background(), 1)
+	assert.Nil(T, err)
+	assert.Equal(T, &users.User{ID: 1, Name: "Guts"}, userItem)
+
+	_, err = client.GetUser(context.Background(), 2)
+	assert.Equal(T, users.ErrUserNotFound, err)
+
+	_, err = client.GetUser(context.Background(), 3)
+	assert.Equal(T, users.ErrUnavailable, err)
+
+	userService.Close()
+	_, err = client.GetUser(context.Background(), 1)
+	assert.Equal(T, users.ErrUnavailable, err)
+}
+
+func TestAssigneeFilter(T *testing.T) {
+	opts, err := todo.ParseListOptions(url.Values{"assignee": {"me"}}, 100)
+	assert.Nil(T, err)
+	assert.True(T, opts.AssignedToMe)
+	assert.Nil(T, opts.AssigneeID)
+
+	opts, err = todo.ParseListOptions(url.Values{"assignee": {"none"}}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, uint(0), *opts.AssigneeID)
+
+	opts, err = todo.ParseListOptions(url.Values{"assignee": {"3"}}, 100)
+	assert.Nil(T, err)
+	assert.Equal(T, uint(3), *opts.AssigneeID)
+
+	for _, raw := range []string{"0", "you", "-2"} {
+		_, err = todo.ParseListOptions(url.Values{"assignee": {raw}}, 100)
+		assert.NotNil(T, err, raw)
+	}
+}
+
+func TestTodoRoles(T *testing.T) {
+	for role, canEdit := range map[string]bool{
+		"":                true,
+		list.RoleOwner:    true,
+		list.RoleEditor:   true,
+		todo.RoleAssignee: true,
+		list.RoleViewer:   false,
+	} {
+		assert.Equal(T, canEdit, (&todo.Todo{Role: role}).CanEdit(), role)
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"time"
+
+	assigneehandlers "github.com/ennemli/todo/todo/internal/handlers/assignee"
+	itemhandlers "github.com/ennemli/todo/todo/internal/handlers/item"
+	listhandlers "github.com/ennemli/todo/todo/internal/handlers/list"
+	taghandlers "github.com/ennemli/todo/todo/internal/handlers/tag"
+	handlers "github.com/ennemli/todo/todo/internal/handlers/todo"
+	"github.com/ennemli/todo/todo/internal/middlewares"
+	"github.com/ennemli/todo/todo/internal/models/item"
+	"github.com/ennemli/todo/todo/internal/models/list"
+	"github.com/ennemli/todo/todo/internal/models/tag"
+	"github.com/ennemli/todo/todo/internal/models/todo"
+	"github.com/ennemli/todo/todo/internal/server"
+	"github.com/ennemli/todo/todo/internal/users"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// mocks are the stores and user service the routes of a test work with.
+type mocks struct {
+	todos *todo.MockTodo
+	items *item.MockItem
+	tags  *tag.MockTag
+	lists *list.MockList
+	users *users.MockClient
+}
+
+// route serves the routes of the service, without its API prefix, from m.
+// The mocks m leaves out are new ones, in which no todo has a checklist or
+// tags.
+func route(m mocks) mocks {
+	if m.todos == nil {
+		m.todos = new(todo.MockTodo)
+	}
+	if m.items == nil {
+		m.items = newItemMock()
+	}
+	if m.tags == nil {
+		m.tags = newTagMock()
+	}
+	if m.lists == nil {
+		m.lists = new(list.MockList)
+	}
+	if m.users == nil {
+		m.users = new(users.MockClient)
+	}
+	todoHandlers := handlers.NewTodoHandlers(m.todos, m.items, m.tags)
+	itemHandlers := itemhandlers.NewItemHandlers(m.todos, m.items)
+	tagHandlers := taghandlers.NewTagHandlers(m.todos, m.tags)
+	listHandlers := listhandlers.NewListHandlers(m.lists, m.users)
+	assigneeHandlers := assigneehandlers.NewAssigneeHandlers(m.todos, m.users)
+	r := server.GetRouter().With(middlewares.WithIdentity)
+	r.Get("/", todoHandlers.GetTodos)
+	r.Post("/", todoHandlers.CreateTodo)
+	r.Post("/batch", todoHandlers.Batch)
+	r.Get("/changes", todoHandlers.GetChanges)
+	r.Get("/export", todoHandlers.Export)
+	r.Post("/import", todoHandlers.Import)
+	r.Get("/trash", todoHandlers.GetTrash)
+	r.Delete("/trash/{id}", todoHandlers.PurgeTodoById)
+	r.Get("/tags", tagHandlers.GetTags)
+	r.Post("/tags", tagHandlers.CreateTag)
+	r.Put("/tags/{tagId}", tagHandlers.UpdateTag)
+	r.Delete("/tags/{tagId}", tagHandlers.DeleteTagById)
+	r.Post("/lists", listHandlers.CreateList)
+	r.Get("/lists/invitations", listHandlers.GetMyInvitations)
+	r.Post("/lists/invitations/{invitationId}/accept", listHandlers.AcceptInvitation)
+	r.Post("/lists/invitations/{invitationId}/decline", listHandlers.DeclineInvitation)
+	r.Get("/lists/{listId}", listHandlers.GetListById)
+	r.Put("/lists/{listId}", listHandlers.UpdateList)
+	r.Delete("/lists/{listId}", listHandlers.DeleteListById)
+	r.Put("/lists/{listId}/members/{userId}", listHandlers.SetRole)
+	r.Delete("/lists/{listId}/members/{userId}", listHandlers.RemoveMember)
+	r.Post("/lists/{listId}/invitations", listHandlers.CreateInvitation)
+	r.Delete("/lists/{listId}/invitations/{invitationId}", listHandlers.DeleteInvitationById)
+	r.Get("/{id}", todoHandlers.GetTodoById)
+	r.Put("/{id}", todoHandlers.UpdateTodo)
+	r.Delete("/{id}", todoHandlers.DeleteTodoById)
+	r.Get("/{id}/occurrences", todoHandlers.GetOccurrences)
+	r.Post("/{id}/restore", todoHandlers.RestoreTodoById)
+	r.Get("/{id}/items", itemHandlers.GetItems)
+	r.Post("/{id}/items", itemHandlers.CreateItem)
+	r.Put("/{id}/items/order", itemHandlers.ReorderItems)
+	r.Put("/{id}/items/{itemId}", itemHandlers.UpdateItem)
+	r.Post("/{id}/items/{itemId}/toggle", itemHandlers.ToggleItem)
+	r.Delete("/{id}/items/{itemId}", itemHandlers.DeleteItemById)
+	r.Post("/{id}/tags/{tagId}", tagHandlers.AttachTag)
+	r.Delete("/{id}/tags/{tagId}", tagHandlers.DetachTag)
+	r.Put("/{id}/assignee", assigneeHandlers.Assign)
+	r.Delete("/{id}/assignee", assigneeHandlers.Unassign)
+	return m
+}
+
+// newTodo returns the open todo id of testUserID, at its first version.
+func newTodo(id uint) *todo.Todo {
+	todoItem := &todo.Todo{Name: "Task", UserID: testUserID, Status: todo.StatusOpen, Version: 1}
+	todoItem.ID = id
+	return todoItem
+}
+
+// ownTodo makes the todo id one testUserID sees and otherUserID does not.
+func ownTodo(mt *todo.MockTodo, id uint) *todo.Todo {
+	existing := newTodo(id)
+	mt.On("GetTodoById", mock.Anything, testUserID, id).Return(existing, nil)
+	mt.On("GetTodoById", mock.Anything, otherUserID, id).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+	return existing
+}
+
+// ownTag makes the tag id one of testUserID.
+func ownTag(mg *tag.MockTag, id uint) *tag.Tag {
+	existing := &tag.Tag{ID: id, UserID: testUserID, Name: "work"}
+	mg.On("GetTagById", mock.Anything, testUserID, id).Return(existing, nil)
+	mg.On("GetTagById", mock.Anything, otherUserID, id).Return((*tag.Tag)(nil), gorm.ErrRecordNotFound)
+	return existing
+}
+
+// memberOf makes testUserID a member of the list id with role.
+func memberOf(ml *list.MockList, id uint, role string) *list.List {
+	existing := &list.List{ID: id, Name: "Groceries", Role: role}
+	ml.On("GetListById", mock.Anything, testUserID, id).Return(existing, nil)
+	ml.On("GetListById", mock.Anything, otherUserID, id).Return((*list.List)(nil), gorm.ErrRecordNotFound)
+	return existing
+}
+
+// todoWithRole makes the todo id of otherUserID one testUserID sees with
+// role.
+func todoWithRole(mt *todo.MockTodo, id uint, role string, assigneeID *uint) *todo.Todo {
+	existing := newTodo(id)
+	existing.UserID = otherUserID
+	existing.Role = role
+	existing.AssigneeID = assigneeID
+	mt.On("GetTodoById", mock.Anything, testUserID, id).Return(existing, nil)
+	return existing
+}
+
+// deletedTodo returns the todo id in the trash.
+func deletedTodo(id uint) *todo.Todo {
+	deleted := newTodo(id)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return deleted
+}
+
+// versionedTodo returns the todo 1 at version.
+func versionedTodo(version uint) *todo.Todo {
+	todoItem := newTodo(1)
+	todoItem.Version = version
+	return todoItem
+}
+
+// batchMock returns a todo store in which todo 1 can be updated, todo 2
+// deleted and todo 9 does not exist.
+func batchMock() *todo.MockTodo {
+	mt := new(todo.MockTodo)
+	created := &todo.Todo{Name: "New", UserID: testUserID}
+	created.ID = 3
+	mt.On("Transaction", mock.Anything, mock.Anything).Return(nil)
+	mt.On("CreateTodo", mock.Anything, mock.Anything).Return(created, nil)
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(1)).Return(versionedTodo(1), nil)
+	mt.On("GetTodoById", mock.Anything, testUserID, uint(9)).Return((*todo.Todo)(nil), gorm.ErrRecordNotFound)
+	mt.On("UpdateTodo", mock.Anything, testUserID, mock.Anything, map[string]interface{}{"name": "Renamed"}).Return(versionedTodo(2), nil)
+	mt.On("DeleteTodoById", mock.Anything, testUserID, uint(2), mock.Anything).Return(deletedTodo(2), nil)
+	mt.On("Decorate", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return mt
+}
+
+// importMock returns a todo store in which the external ID taken is
+// already used, and that records the todos it creates in created.
+func importMock(created *[]*todo.Todo) *todo.MockTodo {
+	mt := new(todo.MockTodo)
+	saved := &todo.Todo{Name: "Saved", UserID: testUserID}
+	saved.ID = 7
+	mt.On("Transaction", mock.Anything, mock.Anything).Return(nil)
+	mt.On("CreateTodo", mock.Anything, mock.MatchedBy(func(t *todo.Todo) bool {
+		return t.ExternalID != nil && *t.ExternalID == "taken"
+	})).Return((*todo.Todo)(nil), todo.ErrExternalID)
+	mt.On("CreateTodo", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*created = append(*created, args.Get(1).(*todo.Todo))
+	}).Return(saved, nil)
+	return mt
+}
@@ -0,0 +1,74 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound = errors.New("User not found")
+	ErrUnavailable  = errors.New("The user service is unavailable")
+)
+
+// Client reads users from the user service.
+type Client interface {
+	GetUser(ctx context.Context, id uint) (*User, error)
+}
+
+type User struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type client struct {
+	endpoint string
+	http     *http.Client
+}
+
+// NewClient returns a client of the user service at endpoint, such as
+// http://user:8002. Its timeout is shorter than the one of the requests it
+// is called from so that a slow user service is reported as unavailable.
+func NewClient(endpoint string) Client {
+	return &client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		http:     &http.Client{Timeout: time.Second},
+	}
+}
+
+// GetUser fails with ErrUserNotFound when there is no user with this id,
+// and with ErrUnavailable, after logging why, when the user service could
+// not tell.
+func (c *client) GetUser(ctx context.Context, id uint) (*User, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/users/internal/%d", c.endpoint, id), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrUserNotFound
+	default:
+		return nil, unavailable(fmt.Errorf("unexpected status %d", res.StatusCode))
+	}
+	userItem := new(User)
+	if err := json.NewDecoder(res.Body).Decode(userItem); err != nil {
+		return nil, unavailable(err)
+	}
+	return userItem, nil
+}
+
+func unavailable(err error) error {
+	log.Printf("users: %v", err)
+	return ErrUnavailable
+}
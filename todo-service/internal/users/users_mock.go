@@ -0,0 +1,16 @@
+package users
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockClient struct {
+	mock.Mock
+}
+
+func (m *MockClient) GetUser(ctx context.Context, id uint) (*User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*User), args.Error(1)
+}
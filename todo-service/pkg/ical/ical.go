@@ -0,0 +1,264 @@
+// Package ical reads and writes iCalendar (RFC 5545) components: their
+// content lines, folded at 75 octets, their parameters and the TEXT and
+// DATE-TIME values todos are made of.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeUTC = "20060102T150405Z"
+	dateTime    = "20060102T150405"
+	date        = "20060102"
+	lineLength  = 75
+)
+
+// Property is a content line. Params holds the first value of each
+// parameter.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a BEGIN/END block with its properties and the components
+// nested in it.
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Get returns the first property named name, or nil.
+func (c *Component) Get(name string) *Property {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Text returns the unescaped TEXT value of p.
+func (p *Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Time returns the DATE or DATE-TIME value of p. A time with a TZID is read
+// in that time zone; a floating time and a date are read in UTC.
+func (p *Property) Time() (time.Time, error) {
+	loc := time.UTC
+	if tzid, ok := p.Params["TZID"]; ok {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("ical: unknown TZID %q", tzid)
+		}
+	}
+	layouts := []string{dateTimeUTC, dateTime, date}
+	if p.Params["VALUE"] == "DATE" {
+		layouts = []string{date}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, p.Value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("ical: invalid %s %q", p.Name, p.Value)
+}
+
+// Parse reads the component r holds, such as a VCALENDAR.
+func Parse(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var root *Component
+	var open []*Component
+	for i, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %v", i+1, err)
+		}
+		switch {
+		case p.Name == "BEGIN":
+			c := &Component{Name: strings.ToUpper(p.Value)}
+			if len(open) > 0 {
+				parent := open[len(open)-1]
+				parent.Components = append(parent.Components, c)
+			} else if root == nil {
+				root = c
+			} else {
+				return nil, fmt.Errorf("ical: line %d: content after END:%s", i+1, root.Name)
+			}
+			open = append(open, c)
+		case p.Name == "END":
+			if len(open) == 0 || open[len(open)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("ical: line %d: unexpected END:%s", i+1, p.Value)
+			}
+			open = open[:len(open)-1]
+		case len(open) == 0:
+			return nil, fmt.Errorf("ical: line %d: %s outside of a component", i+1, p.Name)
+		default:
+			c := open[len(open)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("ical: no component")
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("ical: missing END:%s", open[len(open)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold returns the content lines of r, joining the lines that start with
+// a space or a tab to the one before them.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseLine reads a content line: a name, parameters after semicolons, then
+// the value after a colon. Parameter values may be quoted.
+func parseLine(line string) (*Property, error) {
+	p := &Property{Params: map[string]string{}}
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return nil, fmt.Errorf("invalid content line %q", line)
+	}
+	p.Name = strings.ToUpper(line[:end])
+	line = line[end:]
+	for line[0] == ';' {
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid parameter in %s", p.Name)
+		}
+		name := strings.ToUpper(line[1:eq])
+		line = line[eq+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			quote := strings.IndexByte(line[1:], '"')
+			if quote < 0 {
+				return nil, fmt.Errorf("unterminated parameter %s of %s", name, p.Name)
+			}
+			value, line = line[1:quote+1], line[quote+2:]
+		} else {
+			end := strings.IndexAny(line, ";:")
+			if end < 0 {
+				return nil, fmt.Errorf("missing value of %s", p.Name)
+			}
+			value, line = line[:end], line[end:]
+		}
+		if _, ok := p.Params[name]; !ok {
+			p.Params[name], _, _ = strings.Cut(value, ",")
+		}
+		if line == "" {
+			return nil, fmt.Errorf("missing value of %s", p.Name)
+		}
+	}
+	if line[0] != ':' {
+		return nil, fmt.Errorf("invalid parameter in %s", p.Name)
+	}
+	p.Value = line[1:]
+	return p, nil
+}
+
+// Writer writes components as folded content lines. Its first error is kept
+// and returned by Flush.
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+func (w *Writer) Begin(name string) {
+	w.line("BEGIN:" + name)
+}
+
+func (w *Writer) End(name string) {
+	w.line("END:" + name)
+}
+
+// Text writes a property with a TEXT value, which it escapes.
+func (w *Writer) Text(name string, value string) {
+	w.line(name + ":" + EscapeText(value))
+}
+
+// Time writes a property with a DATE-TIME value, in UTC.
+func (w *Writer) Time(name string, t time.Time) {
+	w.line(name + ":" + t.UTC().Format(dateTimeUTC))
+}
+
+// Raw writes a property whose value is written as it is, such as an RRULE.
+func (w *Writer) Raw(name string, value string) {
+	w.line(name + ":" + value)
+}
+
+func (w *Writer) Flush() error {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return w.err
+}
+
+// line writes line folded in lines of at most 75 octets, without splitting
+// a UTF-8 sequence.
+func (w *Writer) line(line string) {
+	if w.err != nil {
+		return
+	}
+	limit := lineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		_, w.err = w.w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = lineLength - 1
+		if w.err != nil {
+			return
+		}
+	}
+	_, w.err = w.w.WriteString(line + "\r\n")
+}
+
+var (
+	textEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+// EscapeText escapes a TEXT value.
+func EscapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// UnescapeText reads an escaped TEXT value.
+func UnescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
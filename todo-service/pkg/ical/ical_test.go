@@ -0,0 +1,137 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(T *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:42\r\n" +
+		"SUMMARY:Buy milk\\, eggs\r\n" +
+		"DESCRIPTION:A long\r\n" +
+		"  description\\non two lines\r\n" +
+		"DUE;TZID=\"Europe/Paris\";X-NOTE=\"a:b;c\":20240301T090000\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	calendar, err := Parse(strings.NewReader(input))
+	if err != nil {
+		T.Fatal(err)
+	}
+	if calendar.Name != "VCALENDAR" || len(calendar.Components) != 1 || calendar.Get("VERSION").Value != "2.0" {
+		T.Fatalf("unexpected calendar %+v", calendar)
+	}
+	todo := calendar.Components[0]
+	if got := todo.Get("SUMMARY").Text(); got != "Buy milk, eggs" {
+		T.Errorf("SUMMARY = %q", got)
+	}
+	if got := todo.Get("DESCRIPTION").Text(); got != "A long description\non two lines" {
+		T.Errorf("DESCRIPTION = %q", got)
+	}
+	due := todo.Get("DUE")
+	if due.Params["X-NOTE"] != "a:b;c" {
+		T.Errorf("X-NOTE = %q", due.Params["X-NOTE"])
+	}
+	t, err := due.Time()
+	if err != nil || !t.Equal(time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)) {
+		T.Errorf("DUE = %v, %v", t, err)
+	}
+	if todo.Get("DTSTART") != nil {
+		T.Error("DTSTART should be missing")
+	}
+}
+
+func TestParseErrors(T *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Empty", ""},
+		{"NoColon", "BEGIN:VCALENDAR\nVERSION\nEND:VCALENDAR\n"},
+		{"Unclosed", "BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VCALENDAR\n"},
+		{"Missing END", "BEGIN:VCALENDAR\n"},
+		{"Outside", "VERSION:2.0\n"},
+		{"Unterminated quote", "BEGIN:VCALENDAR\nX;A=\"b:c\nEND:VCALENDAR\n"},
+		{"Two roots", "BEGIN:VCALENDAR\nEND:VCALENDAR\nBEGIN:VCALENDAR\nEND:VCALENDAR\n"},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			if _, err := Parse(strings.NewReader(tc.input)); err == nil {
+				T.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestTime(T *testing.T) {
+	testCases := []struct {
+		name     string
+		params   map[string]string
+		value    string
+		expected time.Time
+		err      bool
+	}{
+		{"UTC", nil, "20240301T090000Z", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), false},
+		{"Floating", nil, "20240301T090000", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), false},
+		{"Date", map[string]string{"VALUE": "DATE"}, "20240301", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"TZID", map[string]string{"TZID": "America/New_York"}, "20240301T090000", time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), false},
+		{"Unknown TZID", map[string]string{"TZID": "Nowhere"}, "20240301T090000", time.Time{}, true},
+		{"Invalid", nil, "tomorrow", time.Time{}, true},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			p := &Property{Name: "DUE", Params: tc.params, Value: tc.value}
+			t, err := p.Time()
+			if (err != nil) != tc.err || !t.Equal(tc.expected) {
+				T.Errorf("got %v, %v; expected %v", t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestWriter(T *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Begin("VTODO")
+	w.Text("SUMMARY", strings.Repeat("é", 60)+"; done")
+	w.Time("DUE", time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("", 3600)))
+	w.Raw("RRULE", "FREQ=DAILY;COUNT=2")
+	w.End("VTODO")
+	if err := w.Flush(); err != nil {
+		T.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > lineLength {
+			T.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+	todo, err := Parse(&buf)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if got := todo.Get("SUMMARY").Text(); got != strings.Repeat("é", 60)+"; done" {
+		T.Errorf("SUMMARY = %q", got)
+	}
+	if got := todo.Get("DUE").Value; got != "20240301T080000Z" {
+		T.Errorf("DUE = %q", got)
+	}
+	if got := todo.Get("RRULE").Value; got != "FREQ=DAILY;COUNT=2" {
+		T.Errorf("RRULE = %q", got)
+	}
+}
+
+func TestEscapeText(T *testing.T) {
+	testCases := []string{"", "plain", `a\b`, "a;b,c", "two\nlines", `\n`}
+	for _, value := range testCases {
+		if got := UnescapeText(EscapeText(value)); got != value {
+			T.Errorf("round trip of %q gave %q", value, got)
+		}
+	}
+}
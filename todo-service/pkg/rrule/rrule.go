@@ -0,0 +1,428 @@
+// Package rrule expands iCalendar (RFC 5545) recurrence rules. It supports
+// the DAILY, WEEKLY and MONTHLY frequencies with INTERVAL, BYDAY,
+// BYMONTHDAY, COUNT, UNTIL and WKST.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+)
+
+var frequencies = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+}
+
+func (f Frequency) String() string {
+	for name, freq := range frequencies {
+		if freq == f {
+			return name
+		}
+	}
+	return ""
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// maxEmptyPeriods bounds the search for the next occurrence of rules such as
+// BYMONTHDAY=31;INTERVAL=2 that can skip many periods in a row.
+const maxEmptyPeriods = 1000
+
+// Weekday is a BYDAY entry. N picks the Nth such day of the month, counting
+// from the end when negative, and is only allowed with MONTHLY. Zero means
+// every such day.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+func (w Weekday) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Day]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Day]
+}
+
+// Rule is a parsed RRULE. A zero Until and a zero Count leave the rule
+// unbounded.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []Weekday
+	ByMonthDay []int
+	Count      int
+	Until      time.Time
+	WeekStart  time.Weekday
+}
+
+// Parse reads an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10",
+// with or without the "RRULE:" prefix. An UNTIL without a time zone is read
+// in UTC.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("rrule: empty rule")
+	}
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("rrule: invalid part %q", part)
+		}
+		name = strings.ToUpper(name)
+		if seen[name] {
+			return nil, fmt.Errorf("rrule: %s given twice", name)
+		}
+		seen[name] = true
+		var err error
+		switch name {
+		case "FREQ":
+			if r.Freq, ok = frequencies[strings.ToUpper(val)]; !ok {
+				return nil, fmt.Errorf("rrule: unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(val); err != nil || r.Interval < 1 {
+				return nil, fmt.Errorf("rrule: invalid INTERVAL %q", val)
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(val); err != nil || r.Count < 1 {
+				return nil, fmt.Errorf("rrule: invalid COUNT %q", val)
+			}
+		case "UNTIL":
+			if r.Until, err = parseUntil(val); err != nil {
+				return nil, err
+			}
+		case "BYDAY":
+			if r.ByDay, err = parseByDay(val); err != nil {
+				return nil, err
+			}
+		case "BYMONTHDAY":
+			if r.ByMonthDay, err = parseByMonthDay(val); err != nil {
+				return nil, err
+			}
+		case "WKST":
+			if r.WeekStart, ok = weekdays[strings.ToUpper(val)]; !ok {
+				return nil, fmt.Errorf("rrule: invalid WKST %q", val)
+			}
+		default:
+			return nil, fmt.Errorf("rrule: unsupported part %s", name)
+		}
+	}
+	if r.Freq == 0 {
+		return nil, errors.New("rrule: FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("rrule: COUNT and UNTIL cannot be used together")
+	}
+	for _, day := range r.ByDay {
+		if day.N != 0 && r.Freq != Monthly {
+			return nil, fmt.Errorf("rrule: BYDAY=%s needs FREQ=MONTHLY", day)
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Weekly {
+		return nil, errors.New("rrule: BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+// String formats the rule in a canonical form that Parse reads back.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Iterator walks the occurrences of a rule in order. The start of the
+// series is always its first occurrence, as DTSTART is in RFC 5545.
+type Iterator struct {
+	rule    *Rule
+	start   time.Time
+	after   time.Time
+	period  int
+	pending []time.Time
+	emitted int
+	done    bool
+}
+
+func (r *Rule) Iterator(start time.Time) *Iterator {
+	return &Iterator{rule: r, start: start, pending: []time.Time{start}}
+}
+
+// IteratorAfter walks the occurrences of the series starting at start that
+// are strictly after t. Unless the rule has a COUNT, which counts from
+// start, it jumps to the period containing t instead of walking the ones
+// before it.
+func (r *Rule) IteratorAfter(start time.Time, t time.Time) *Iterator {
+	it := r.Iterator(start)
+	it.after = t
+	if r.Count == 0 && t.After(start) {
+		it.pending = nil
+		it.period = r.periodOf(start, t)
+	}
+	return it
+}
+
+// Next returns the next occurrence, or false once the rule is exhausted.
+func (it *Iterator) Next() (time.Time, bool) {
+	for {
+		next, ok := it.next()
+		if !ok || next.After(it.after) {
+			return next, ok
+		}
+	}
+}
+
+func (it *Iterator) next() (time.Time, bool) {
+	if it.done {
+		return time.Time{}, false
+	}
+	empty := 0
+	for len(it.pending) == 0 {
+		if empty == maxEmptyPeriods {
+			it.done = true
+			return time.Time{}, false
+		}
+		for _, t := range it.rule.period(it.start, it.period) {
+			if t.After(it.start) {
+				it.pending = append(it.pending, t)
+			}
+		}
+		it.period++
+		empty++
+	}
+	next := it.pending[0]
+	it.pending = it.pending[1:]
+	if (it.rule.Count > 0 && it.emitted >= it.rule.Count) || (!it.rule.Until.IsZero() && next.After(it.rule.Until)) {
+		it.done = true
+		return time.Time{}, false
+	}
+	it.emitted++
+	return next, true
+}
+
+// Occurrences returns up to n occurrences of the series starting at start.
+func (r *Rule) Occurrences(start time.Time, n int) []time.Time {
+	var occurrences []time.Time
+	it := r.Iterator(start)
+	for len(occurrences) < n {
+		t, ok := it.Next()
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, t)
+	}
+	return occurrences
+}
+
+// After returns the first occurrence of the series starting at start that
+// is strictly after t.
+func (r *Rule) After(start time.Time, t time.Time) (time.Time, bool) {
+	return r.IteratorAfter(start, t).Next()
+}
+
+// period returns the candidate occurrences of the index-th period after the
+// one containing start, in order. They keep the wall clock time of start.
+func (r *Rule) period(start time.Time, index int) []time.Time {
+	y, m, d := start.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+	step := index * r.Interval
+	var candidates []time.Time
+	switch r.Freq {
+	case Daily:
+		t := at(y, m, d+step)
+		if r.matchesDay(t) && r.matchesMonthDay(t) {
+			candidates = append(candidates, t)
+		}
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		for i := 0; i < 7; i++ {
+			t := at(y, m, d-offset+7*step+i)
+			if len(r.ByDay) == 0 && t.Weekday() == start.Weekday() || len(r.ByDay) > 0 && r.matchesDay(t) {
+				candidates = append(candidates, t)
+			}
+		}
+	case Monthly:
+		first := time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, start.Location())
+		days := daysIn(first.Year(), first.Month())
+		for day := 1; day <= days; day++ {
+			t := at(first.Year(), first.Month(), day)
+			if r.matchesMonthly(t, start, days) {
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return candidates
+}
+
+// periodOf returns the index of the period containing t, which is not
+// before start, counted like the index of period.
+func (r *Rule) periodOf(start time.Time, t time.Time) int {
+	sy, sm, sd := start.Date()
+	ty, tm, td := t.In(start.Location()).Date()
+	days := int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	var elapsed int
+	switch r.Freq {
+	case Daily:
+		elapsed = days
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		elapsed = (days + offset) / 7
+	case Monthly:
+		elapsed = (ty-sy)*12 + int(tm-sm)
+	}
+	return elapsed / r.Interval
+}
+
+func (r *Rule) matchesDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	days := daysIn(t.Year(), t.Month())
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || day < 0 && days+day+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthly(t time.Time, start time.Time, days int) bool {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		return t.Day() == start.Day()
+	}
+	if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(t) {
+		return false
+	}
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Day != t.Weekday() {
+			continue
+		}
+		switch {
+		case day.N == 0:
+			return true
+		case day.N > 0 && (t.Day()-1)/7+1 == day.N:
+			return true
+		case day.N < 0 && (days-t.Day())/7+1 == -day.N:
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			if layout == "20060102" {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("rrule: invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var days []Weekday
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("rrule: invalid BYDAY %q", item)
+		}
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("rrule: invalid BYDAY %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("rrule: invalid BYDAY %q", item)
+			}
+		}
+		days = append(days, Weekday{Day: day, N: n})
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return nil, fmt.Errorf("rrule: invalid BYMONTHDAY %q", item)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
@@ -0,0 +1,323 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+const layout = "2006-01-02T15:04"
+
+func format(times []time.Time) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format(layout)
+	}
+	return formatted
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOccurrences(T *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		T.Fatal(err)
+	}
+	testCases := []struct {
+		name     string
+		rule     string
+		start    string
+		loc      *time.Location
+		n        int
+		expected []string
+	}{
+		{
+			name:     "daily count",
+			rule:     "FREQ=DAILY;COUNT=3",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-02T09:00", "2024-01-03T09:00"},
+		},
+		{
+			name:     "daily interval",
+			rule:     "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-03T09:00", "2024-01-05T09:00"},
+		},
+		{
+			name:     "daily until is inclusive",
+			rule:     "FREQ=DAILY;UNTIL=20240103T090000Z",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-02T09:00", "2024-01-03T09:00"},
+		},
+		{
+			name:     "until date covers the whole day",
+			rule:     "FREQ=DAILY;UNTIL=20240103",
+			start:    "2024-01-01T18:00",
+			expected: []string{"2024-01-01T18:00", "2024-01-02T18:00", "2024-01-03T18:00"},
+		},
+		{
+			name:     "daily on weekdays",
+			rule:     "FREQ=DAILY;BYDAY=MO,WE,FR;COUNT=4",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-03T09:00", "2024-01-05T09:00", "2024-01-08T09:00"},
+		},
+		{
+			name:     "unbounded rule stops at n",
+			rule:     "FREQ=DAILY",
+			start:    "2024-01-01T09:00",
+			n:        2,
+			expected: []string{"2024-01-01T09:00", "2024-01-02T09:00"},
+		},
+		{
+			name:     "weekly on the start weekday",
+			rule:     "FREQ=WEEKLY;COUNT=3",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-08T09:00", "2024-01-15T09:00"},
+		},
+		{
+			name:     "start counts even off the listed days",
+			rule:     "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-02T09:00", "2024-01-04T09:00", "2024-01-09T09:00"},
+		},
+		{
+			name:     "every other week",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=4",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-05T09:00", "2024-01-15T09:00", "2024-01-19T09:00"},
+		},
+		{
+			name:     "week starting on monday",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			start:    "1997-08-05T09:00",
+			expected: []string{"1997-08-05T09:00", "1997-08-10T09:00", "1997-08-19T09:00", "1997-08-24T09:00"},
+		},
+		{
+			name:     "week starting on sunday",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			start:    "1997-08-05T09:00",
+			expected: []string{"1997-08-05T09:00", "1997-08-17T09:00", "1997-08-19T09:00", "1997-08-31T09:00"},
+		},
+		{
+			name:     "monthly skips short months",
+			rule:     "FREQ=MONTHLY;COUNT=3",
+			start:    "2024-01-31T09:00",
+			expected: []string{"2024-01-31T09:00", "2024-03-31T09:00", "2024-05-31T09:00"},
+		},
+		{
+			name:     "last friday of the month",
+			rule:     "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start:    "2024-01-26T09:00",
+			expected: []string{"2024-01-26T09:00", "2024-02-23T09:00", "2024-03-29T09:00"},
+		},
+		{
+			name:     "first monday of the month",
+			rule:     "FREQ=MONTHLY;BYDAY=1MO;COUNT=3",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-02-05T09:00", "2024-03-04T09:00"},
+		},
+		{
+			name:     "last day of the month",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			start:    "2024-01-31T09:00",
+			expected: []string{"2024-01-31T09:00", "2024-02-29T09:00", "2024-03-31T09:00"},
+		},
+		{
+			name:     "several days every other month",
+			rule:     "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1,15;COUNT=4",
+			start:    "2024-01-01T09:00",
+			expected: []string{"2024-01-01T09:00", "2024-01-15T09:00", "2024-03-01T09:00", "2024-03-15T09:00"},
+		},
+		{
+			name:     "friday the thirteenth",
+			rule:     "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3",
+			start:    "2023-01-13T09:00",
+			expected: []string{"2023-01-13T09:00", "2023-10-13T09:00", "2024-09-13T09:00"},
+		},
+		{
+			name:     "rule that never matches again",
+			rule:     "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30",
+			start:    "2024-02-01T09:00",
+			n:        5,
+			expected: []string{"2024-02-01T09:00"},
+		},
+		{
+			name:     "start after until",
+			rule:     "FREQ=DAILY;UNTIL=20231231",
+			start:    "2024-01-01T09:00",
+			expected: []string{},
+		},
+		{
+			name:     "wall clock kept across daylight saving",
+			rule:     "FREQ=DAILY;COUNT=3",
+			start:    "2024-03-09T09:00",
+			loc:      newYork,
+			expected: []string{"2024-03-09T09:00", "2024-03-10T09:00", "2024-03-11T09:00"},
+		},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			rule, err := Parse(tc.rule)
+			if err != nil {
+				T.Fatalf("Parse(%q) failed: %v", tc.rule, err)
+			}
+			loc := tc.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			start, _ := time.ParseInLocation(layout, tc.start, loc)
+			n := tc.n
+			if n == 0 {
+				n = 100
+			}
+			got := format(rule.Occurrences(start, n))
+			if !equal(got, tc.expected) {
+				T.Errorf("Occurrences() = %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAfter(T *testing.T) {
+	rule, _ := Parse("FREQ=WEEKLY;COUNT=3")
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		after    time.Time
+		expected string
+		ok       bool
+	}{
+		{"before the start", start.Add(-time.Hour), "2024-01-01T09:00", true},
+		{"at the start", start, "2024-01-08T09:00", true},
+		{"between occurrences", start.AddDate(0, 0, 9), "2024-01-15T09:00", true},
+		{"at the last occurrence", start.AddDate(0, 0, 14), "", false},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			next, ok := rule.After(start, tc.after)
+			if ok != tc.ok {
+				T.Fatalf("After() ok = %v, expected %v", ok, tc.ok)
+			}
+			if ok && next.Format(layout) != tc.expected {
+				T.Errorf("After() = %s, expected %s", next.Format(layout), tc.expected)
+			}
+		})
+	}
+}
+
+func TestIteratorAfter(T *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		T.Fatal(err)
+	}
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, newYork)
+	rules := []string{
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU;WKST=SU",
+		"FREQ=WEEKLY;UNTIL=20260101T000000Z",
+		"FREQ=MONTHLY",
+		"FREQ=MONTHLY;INTERVAL=5;BYDAY=-1FR",
+		"FREQ=MONTHLY;BYMONTHDAY=-1,15;COUNT=40",
+	}
+	for _, value := range rules {
+		T.Run(value, func(T *testing.T) {
+			rule, err := Parse(value)
+			if err != nil {
+				T.Fatal(err)
+			}
+			for _, t := range []time.Time{start.Add(-time.Hour), start, start.AddDate(0, 0, 40), start.AddDate(1, 3, 2).UTC(), start.AddDate(2, 0, 0)} {
+				walked := rule.Iterator(start)
+				var expected []time.Time
+				for len(expected) < 5 {
+					next, ok := walked.Next()
+					if !ok {
+						break
+					}
+					if next.After(t) {
+						expected = append(expected, next)
+					}
+				}
+				var got []time.Time
+				it := rule.IteratorAfter(start, t)
+				for len(got) < 5 {
+					next, ok := it.Next()
+					if !ok {
+						break
+					}
+					got = append(got, next)
+				}
+				if !equal(format(got), format(expected)) {
+					T.Errorf("after %s: got %v, expected %v", t.Format(layout), format(got), format(expected))
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(T *testing.T) {
+	testCases := []struct {
+		name string
+		rule string
+	}{
+		{"empty", ""},
+		{"missing freq", "INTERVAL=2"},
+		{"unsupported freq", "FREQ=YEARLY"},
+		{"part without value", "FREQ"},
+		{"repeated part", "FREQ=DAILY;FREQ=WEEKLY"},
+		{"unsupported part", "FREQ=DAILY;BYHOUR=9"},
+		{"zero count", "FREQ=DAILY;COUNT=0"},
+		{"negative interval", "FREQ=DAILY;INTERVAL=-1"},
+		{"count and until", "FREQ=DAILY;COUNT=2;UNTIL=20240101"},
+		{"bad until", "FREQ=DAILY;UNTIL=tomorrow"},
+		{"bad weekday", "FREQ=DAILY;BYDAY=XX"},
+		{"ordinal out of range", "FREQ=MONTHLY;BYDAY=6MO"},
+		{"ordinal outside monthly", "FREQ=WEEKLY;BYDAY=1MO"},
+		{"month day out of range", "FREQ=MONTHLY;BYMONTHDAY=32"},
+		{"month day with weekly", "FREQ=WEEKLY;BYMONTHDAY=1"},
+		{"bad week start", "FREQ=WEEKLY;WKST=XX"},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			if _, err := Parse(tc.rule); err == nil {
+				T.Errorf("Parse(%q) succeeded, expected an error", tc.rule)
+			}
+		})
+	}
+}
+
+func TestString(T *testing.T) {
+	testCases := []struct {
+		rule     string
+		expected string
+	}{
+		{"RRULE:FREQ=DAILY", "FREQ=DAILY"},
+		{"freq=weekly;byday=mo,we;interval=1", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20241231", "FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20241231T235959Z"},
+		{"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1,-1;COUNT=4", "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1,-1;COUNT=4"},
+		{"FREQ=WEEKLY;WKST=SU", "FREQ=WEEKLY;WKST=SU"},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.rule, func(T *testing.T) {
+			rule, err := Parse(tc.rule)
+			if err != nil {
+				T.Fatalf("Parse(%q) failed: %v", tc.rule, err)
+			}
+			if got := rule.String(); got != tc.expected {
+				T.Errorf("String() = %q, expected %q", got, tc.expected)
+			}
+			if _, err := Parse(rule.String()); err != nil {
+				T.Errorf("Parse(String()) failed: %v", err)
+			}
+		})
+	}
+}
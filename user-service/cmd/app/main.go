@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
 	"time"
 
+	"github.com/ennemli/todo/user/configs"
 	"github.com/ennemli/todo/user/internal/db"
+	handlers "github.com/ennemli/todo/user/internal/handlers/user"
 	"github.com/ennemli/todo/user/internal/middlewares"
 	"github.com/ennemli/todo/user/internal/models/user"
 	"github.com/ennemli/todo/user/internal/routing"
 	"github.com/ennemli/todo/user/internal/server"
+	"github.com/ennemli/todo/user/pkg/crypto"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 )
@@ -20,7 +26,21 @@ func main() {
 	r.Use(middleware.Recoverer)
 	r.Use(middlewares.SetTimeOut(time.Second * 2))
 	r.Use(render.SetContentType(render.ContentTypeJSON))
-	db.GetDB().AutoMigrate(&user.User{})
-	routing.InitRouting(user.NewStore())
+	if err := db.GetDB().AutoMigrate(&user.User{}, &user.UserRevocation{}); err != nil {
+		panic(err)
+	}
+	config := configs.GetConfig().Service
+	if err := crypto.SetHashParams(crypto.HashParams{Algorithm: config.PASSWORD_HASH, BcryptCost: config.BCRYPT_COST}); err != nil {
+		panic(err)
+	}
+	store := user.NewStore()
+	err := user.EnsureAdmin(context.Background(), store, config.ADMIN_NAME, config.ADMIN_PASSWORD, handlers.PasswordPolicy())
+	if errors.Is(err, user.ErrNoAdmin) {
+		log.Println(err)
+	} else if err != nil {
+		panic(err)
+	}
+	routing.InitRouting(store)
+	go user.Purge(context.Background(), store, config.TRASH_RETENTION, config.PURGE_INTERVAL)
 	s.ListenAndServe()
 }
@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -18,9 +20,17 @@ type dbConfig struct {
 }
 
 type serviceConfig struct {
-	APP_PORT     int
-	APP_DEBUG    bool
-	API_ENDPOINT string
+	APP_PORT             int
+	APP_DEBUG            bool
+	API_ENDPOINT         string
+	ADMIN_NAME           string
+	ADMIN_PASSWORD       string
+	PASSWORD_HASH        string
+	BCRYPT_COST          int
+	PASSWORD_MIN_LENGTH  int
+	PASSWORD_MIN_CLASSES int
+	TRASH_RETENTION      time.Duration
+	PURGE_INTERVAL       time.Duration
 }
 
 func Initialize(filename string, filepath string, filetype string) {
@@ -42,9 +52,17 @@ func GetConfig() *Config {
 		DB_USER:     viper.GetString("DB_USER"),
 	}
 	APP := serviceConfig{
-		APP_DEBUG:    viper.GetBool("APP_DEBUG"),
-		APP_PORT:     viper.GetInt("APP_PORT"),
-		API_ENDPOINT: viper.GetString("API_ENDPOINT"),
+		APP_DEBUG:            viper.GetBool("APP_DEBUG"),
+		APP_PORT:             viper.GetInt("APP_PORT"),
+		API_ENDPOINT:         viper.GetString("API_ENDPOINT"),
+		ADMIN_NAME:           viper.GetString("ADMIN_NAME"),
+		ADMIN_PASSWORD:       viper.GetString("ADMIN_PASSWORD"),
+		PASSWORD_HASH:        viper.GetString("PASSWORD_HASH"),
+		BCRYPT_COST:          viper.GetInt("BCRYPT_COST"),
+		PASSWORD_MIN_LENGTH:  viper.GetInt("PASSWORD_MIN_LENGTH"),
+		PASSWORD_MIN_CLASSES: viper.GetInt("PASSWORD_MIN_CLASSES"),
+		TRASH_RETENTION:      viper.GetDuration("TRASH_RETENTION"),
+		PURGE_INTERVAL:       viper.GetDuration("PURGE_INTERVAL"),
 	}
 
 	return &Config{
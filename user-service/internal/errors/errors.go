@@ -10,6 +10,19 @@ var ResponseRequestTimeout ErrorResponse = ErrorResponse{
 	Message: "Request timeout",
 }
 
+var ResponseUnauthorized ErrorResponse = ErrorResponse{
+	Message: "Unauthorized",
+}
+
+var ResponseForbidden ErrorResponse = ErrorResponse{
+	Message: "Forbidden",
+}
+
 type ErrorResponse struct {
 	Message string `json:"message"`
 }
+
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 
+	"github.com/ennemli/todo/user/configs"
 	"github.com/ennemli/todo/user/internal/errors"
 	"github.com/ennemli/todo/user/internal/models/user"
 	"github.com/ennemli/todo/user/pkg/crypto"
+	"github.com/ennemli/todo/user/pkg/etag"
+	"github.com/ennemli/todo/user/pkg/identity"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
@@ -17,10 +22,16 @@ import (
 type Handlers interface {
 	GetUsers(w http.ResponseWriter, r *http.Request)
 	GetUserById(w http.ResponseWriter, r *http.Request)
+	GetSummaryById(w http.ResponseWriter, r *http.Request)
 	GetUserByName(w http.ResponseWriter, r *http.Request)
 	CreateUser(w http.ResponseWriter, r *http.Request)
 	DeleteUserById(w http.ResponseWriter, r *http.Request)
 	UpdateUser(w http.ResponseWriter, r *http.Request)
+	PatchUser(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	GetTrash(w http.ResponseWriter, r *http.Request)
+	RestoreUserById(w http.ResponseWriter, r *http.Request)
+	PurgeUserById(w http.ResponseWriter, r *http.Request)
 }
 
 type userHandler struct {
@@ -37,7 +48,7 @@ func (h *userHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		renderError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
-	render.JSON(w, r, users)
+	renderTagged(w, r, users)
 }
 
 func (h *userHandler) GetUserById(w http.ResponseWriter, r *http.Request) {
@@ -51,7 +62,23 @@ func (h *userHandler) GetUserById(w http.ResponseWriter, r *http.Request) {
 		renderError(w, r, http.StatusNotFound, fmt.Sprintf("user with id %d not found", id))
 		return
 	}
-	render.JSON(w, r, user)
+	renderTagged(w, r, user)
+}
+
+// GetSummaryById answers other services, which call it without an identity.
+// The gateway does not expose it.
+func (h *userHandler) GetSummaryById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	summary, err := h.store.GetSummaryById(r.Context(), uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", id))
+		return
+	}
+	render.JSON(w, r, summary)
 }
 
 func (h *userHandler) GetUserByName(w http.ResponseWriter, r *http.Request) {
@@ -70,20 +97,76 @@ func (h *userHandler) GetUserByName(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *userHandler) DeleteUserById(w http.ResponseWriter, r *http.Request) {
-	user := &user.User{}
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
-	user, err = h.store.DeleteUserById(r.Context(), uint(id))
+	var version uint
+	if r.Header.Get("If-Match") != "" {
+		existingUser, err := h.store.GetUserById(r.Context(), uint(id))
+		if err != nil {
+			renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", id))
+			return
+		}
+		if !checkIfMatch(w, r, existingUser) {
+			return
+		}
+		version = existingUser.Version
+	}
+	deleted, err := h.store.DeleteUserById(r.Context(), uint(id), version)
+	if err == user.ErrStale {
+		renderError(w, r, http.StatusPreconditionFailed, err.Error())
+		return
+	}
 	if err != nil {
 		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", id))
 		return
 	}
+	render.JSON(w, r, deleted)
+}
+
+func (h *userHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	users, err := h.store.GetTrash(r.Context())
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	render.JSON(w, r, users)
+}
+
+func (h *userHandler) RestoreUserById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	user, err := h.store.RestoreUserById(r.Context(), uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d is not in the trash", id))
+		return
+	}
 	render.JSON(w, r, user)
 }
 
+func (h *userHandler) PurgeUserById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	purged, err := h.store.PurgeUserById(r.Context(), uint(id))
+	if err == user.ErrHasTodos {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d is not in the trash", id))
+		return
+	}
+	render.JSON(w, r, purged)
+}
+
 func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var credential user.Credential
 	err := json.NewDecoder(r.Body).Decode(&credential)
@@ -91,8 +174,22 @@ func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
-	if len(credential.Password) < 8 {
-		renderError(w, r, http.StatusBadRequest, "Password must at least contaians 8 charachters")
+	if credential.Role == "" {
+		credential.Role = user.RoleUser
+	}
+	if !user.ValidRole(credential.Role) {
+		renderError(w, r, http.StatusBadRequest, "Invalid role")
+		return
+	}
+	if credential.Role != user.RoleUser {
+		caller, ok := identity.FromContext(r.Context())
+		if !ok || !caller.HasRole(user.RoleAdmin) {
+			renderError(w, r, http.StatusForbidden, "Only admins can change roles")
+			return
+		}
+	}
+	if err := PasswordPolicy().Validate(credential.Password); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 	ep, err := crypto.HashPassword(credential.Password)
@@ -103,8 +200,13 @@ func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	userItem := &user.User{
 		Name:     credential.Name,
 		Password: ep,
+		Role:     credential.Role,
 	}
 	userItem, err = h.store.CreateUser(r.Context(), userItem)
+	if err == user.ErrNameTaken {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		renderError(w, r, http.StatusInternalServerError, "Something Went Wrong")
 		return
@@ -112,7 +214,24 @@ func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, userItem)
 }
 
+// UpdateUser replaces the user's writable fields.
 func (h *userHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	h.updateUser(w, r, user.ParseReplacement)
+}
+
+// PatchUser applies a JSON Merge Patch to the user's writable fields.
+func (h *userHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "application/json", "application/merge-patch+json":
+	default:
+		renderError(w, r, http.StatusUnsupportedMediaType, "Use application/merge-patch+json")
+		return
+	}
+	h.updateUser(w, r, user.ParseMergePatch)
+}
+
+func (h *userHandler) updateUser(w http.ResponseWriter, r *http.Request, parse func([]byte) (*user.UserUpdate, user.FieldErrors)) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid ID")
@@ -124,19 +243,154 @@ func (h *userHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", id))
 		return
 	}
+	if !checkIfMatch(w, r, existingUser) {
+		return
+	}
 
-	updatedFields := make(map[string]interface{})
-	if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
 		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
-	existingUser, err = h.store.UpdateUser(r.Context(), existingUser, updatedFields)
+	update, fieldErrors := parse(body)
+	if fieldErrors != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errors.ValidationErrorResponse{Message: "Invalid request payload", Fields: fieldErrors})
+		return
+	}
+	if update.Role != nil && *update.Role == existingUser.Role {
+		update.Role = nil
+	}
+	if update.Role != nil {
+		caller, ok := identity.FromContext(r.Context())
+		if !ok || !caller.HasRole(user.RoleAdmin) {
+			renderError(w, r, http.StatusForbidden, "Only admins can change roles")
+			return
+		}
+	}
+	fields := update.Fields()
+	if len(fields) == 0 {
+		renderTagged(w, r, existingUser)
+		return
+	}
+	existingUser, err = h.store.UpdateUser(r.Context(), existingUser, fields)
+	if err == user.ErrStale {
+		status := http.StatusConflict
+		if r.Header.Get("If-Match") != "" {
+			status = http.StatusPreconditionFailed
+		}
+		renderError(w, r, status, err.Error())
+		return
+	}
+	if err == user.ErrNameTaken {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
-		renderError(w, r, http.StatusInternalServerError, err.Error())
+		renderError(w, r, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
 
-	render.JSON(w, r, existingUser)
+	renderTagged(w, r, existingUser)
+}
+
+// ChangePassword lets users change their own password given the current one.
+// Their existing sessions are revoked.
+func (h *userHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	caller, ok := identity.FromContext(r.Context())
+	if !ok || caller.ID != uint(id) {
+		renderError(w, r, http.StatusForbidden, "You can only change your own password")
+		return
+	}
+	var change user.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	existingUser, err := h.store.GetUserById(r.Context(), uint(id))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("User with ID %d not found", id))
+		return
+	}
+	if ok, _, err := crypto.VerifyPassword(existingUser.Password, change.CurrentPassword); err != nil || !ok {
+		renderError(w, r, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+	if change.NewPassword == change.CurrentPassword {
+		renderError(w, r, http.StatusBadRequest, "The new password must differ from the current one")
+		return
+	}
+	if err := PasswordPolicy().Validate(change.NewPassword); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash, err := crypto.HashPassword(change.NewPassword)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if err := h.store.UpdatePassword(r.Context(), uint(id), hash); err != nil {
+		renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	render.NoContent(w, r)
+}
+
+// PasswordPolicy is crypto.DefaultPasswordPolicy adjusted by the config.
+func PasswordPolicy() crypto.PasswordPolicy {
+	config := configs.GetConfig().Service
+	policy := crypto.DefaultPasswordPolicy
+	if config.PASSWORD_MIN_LENGTH > 0 {
+		policy.MinLength = config.PASSWORD_MIN_LENGTH
+	}
+	if config.PASSWORD_MIN_CLASSES > 0 {
+		policy.MinClasses = config.PASSWORD_MIN_CLASSES
+	}
+	return policy
+}
+
+// checkIfMatch answers 412 Precondition Failed and returns false when the
+// request's If-Match does not list the ETag of userItem.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, userItem *user.User) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	if !etag.Match(header, tagOf(userItem), false) {
+		renderError(w, r, http.StatusPreconditionFailed, fmt.Sprintf("User with ID %d has changed", userItem.ID))
+		return false
+	}
+	return true
+}
+
+// tagOf returns the ETag of userItem, which follows its version.
+func tagOf(userItem *user.User) string {
+	return etag.Version(userItem.ID, userItem.Version)
+}
+
+// renderTagged renders v with its ETag, the one of its version for a user
+// and one derived from its JSON otherwise. A GET whose If-None-Match lists
+// that tag gets 304 Not Modified instead.
+func renderTagged(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var tag string
+	var err error
+	if userItem, ok := v.(*user.User); ok {
+		tag = tagOf(userItem)
+	} else if tag, err = etag.Of(v); err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("ETag", tag)
+	if r.Method == http.MethodGet && etag.Match(r.Header.Get("If-None-Match"), tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	render.JSON(w, r, v)
 }
 
 func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
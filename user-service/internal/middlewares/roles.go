@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ennemli/todo/user/internal/errors"
+	"github.com/ennemli/todo/user/pkg/identity"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller, ok := identity.FromContext(r.Context())
+			if !ok || !caller.HasRole(role) {
+				renderForbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SelfOrRole lets callers through when the {id} URL param is their own id or
+// when they have role.
+func SelfOrRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller, ok := identity.FromContext(r.Context())
+			if !ok {
+				renderForbidden(w, r)
+				return
+			}
+			id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+			if !caller.HasRole(role) && (err != nil || uint(id) != caller.ID) {
+				renderForbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func renderForbidden(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusForbidden)
+	render.JSON(w, r, errors.ResponseForbidden)
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRetention is how long deleted users stay in the trash when no
+// retention is configured.
+const DefaultRetention = 30 * 24 * time.Hour
+
+func trashed(tx *gorm.DB) *gorm.DB {
+	return tx.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// GetTrash returns the deleted users, the most recently deleted first.
+func (s *store) GetTrash(ctx context.Context) ([]*User, error) {
+	userItems := []*User{}
+	if err := trashed(s.db.WithContext(ctx)).Order("deleted_at DESC, id DESC").Find(&userItems).Error; err != nil {
+		return nil, err
+	}
+	return userItems, nil
+}
+
+func (s *store) RestoreUserById(ctx context.Context, id uint) (*User, error) {
+	userItem := new(User)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := trashed(tx).First(userItem, id).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(userItem).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	userItem.DeletedAt = gorm.DeletedAt{}
+	if err := withTodos(s.db.WithContext(ctx), userItem); err != nil {
+		return nil, err
+	}
+	return userItem, nil
+}
+
+// hasTodos matches the users the todo service still holds data of: todos
+// they created, deleted ones included, todos assigned to them, list
+// memberships and invitations, tags or a change feed. Purging them would
+// leave that data behind; the todo service deletes it once they have been
+// in the trash for its retention.
+const hasTodos = "(EXISTS (SELECT 1 FROM todos WHERE todos.user_id = users.id OR todos.assignee_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM list_members WHERE list_members.user_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM list_invitations WHERE list_invitations.user_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM tags WHERE tags.user_id = users.id)" +
+	" OR EXISTS (SELECT 1 FROM feed_counters WHERE feed_counters.user_id = users.id))"
+
+// PurgeUserById permanently deletes a user of the trash. It fails with
+// ErrHasTodos while the todo service holds data of theirs.
+func (s *store) PurgeUserById(ctx context.Context, id uint) (*User, error) {
+	userItem := new(User)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := trashed(tx).First(userItem, id).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Unscoped().Model(&User{}).Where("id = ?", id).Where(hasTodos).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrHasTodos
+		}
+		return tx.Unscoped().Delete(userItem).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userItem, nil
+}
+
+// PurgeTrash permanently deletes the users deleted before before, and
+// returns how many it deleted and how many it kept in the trash until the
+// todo service has deleted the data it holds of theirs.
+func (s *store) PurgeTrash(ctx context.Context, before time.Time) (int64, int64, error) {
+	var purged, held int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := func() *gorm.DB {
+			return tx.Unscoped().Model(&User{}).Where("deleted_at < ?", before)
+		}
+		if err := expired().Where(hasTodos).Count(&held).Error; err != nil {
+			return err
+		}
+		result := expired().Where("NOT " + hasTodos).Delete(&User{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, held, err
+}
+
+// Purge purges the users that have been in the trash for longer than
+// retention, 30 days when it is not set, every interval, an hour when it is
+// not set, until ctx is done.
+func Purge(ctx context.Context, store Store, retention time.Duration, interval time.Duration) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, held, err := store.PurgeTrash(ctx, time.Now().Add(-retention))
+		switch {
+		case err != nil:
+			log.Printf("trash: %v", err)
+		case purged > 0 || held > 0:
+			log.Printf("trash: purged %d users, kept %d whose data the todo service still holds", purged, held)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package user
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const maxNameLength = 64
+
+// Columns that can never be written through UpdateUser.
+var protectedFields = map[string]string{
+	"id":         "cannot be modified",
+	"created_at": "cannot be modified",
+	"updated_at": "cannot be modified",
+	"deleted_at": "cannot be modified",
+	"todos":      "cannot be modified",
+	"password":   "use PUT /{id}/password to change the password",
+}
+
+// FieldErrors maps a JSON field to what is wrong with it.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field, message := range e {
+		fields = append(fields, field+": "+message)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
+
+// UserUpdate holds the fields clients may change. Nil fields are left alone.
+type UserUpdate struct {
+	Name *string `json:"name"`
+	Role *string `json:"role"`
+}
+
+// ParseMergePatch reads a JSON Merge Patch (RFC 7396) document. Only the
+// whitelisted fields may appear and none of them can be removed with null.
+func ParseMergePatch(data []byte) (*UserUpdate, FieldErrors) {
+	return parseUpdate(data, false)
+}
+
+// ParseReplacement reads a full replacement of the user. The name is
+// required; the role is managed by admins and stays as it is when omitted.
+func ParseReplacement(data []byte) (*UserUpdate, FieldErrors) {
+	return parseUpdate(data, true)
+}
+
+func parseUpdate(data []byte, replace bool) (*UserUpdate, FieldErrors) {
+	doc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &doc); err != nil || doc == nil {
+		return nil, FieldErrors{"": "the body must be a JSON object"}
+	}
+	update := new(UserUpdate)
+	errs := FieldErrors{}
+	for field, raw := range doc {
+		if message, ok := protectedFields[field]; ok {
+			errs[field] = message
+			continue
+		}
+		var target **string
+		switch field {
+		case "name":
+			target = &update.Name
+		case "role":
+			target = &update.Role
+		default:
+			errs[field] = "unknown field"
+			continue
+		}
+		if string(raw) == "null" {
+			errs[field] = "cannot be null"
+			continue
+		}
+		value := new(string)
+		if err := json.Unmarshal(raw, value); err != nil {
+			errs[field] = "must be a string"
+			continue
+		}
+		*target = value
+	}
+	if replace && update.Name == nil {
+		if _, ok := errs["name"]; !ok {
+			errs["name"] = "is required"
+		}
+	}
+	if update.Name != nil {
+		if message := validateName(*update.Name); message != "" {
+			errs["name"] = message
+		}
+	}
+	if update.Role != nil && !ValidRole(*update.Role) {
+		errs["role"] = "must be admin or user"
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return update, nil
+}
+
+// validateName returns what is wrong with a user name, or "".
+func validateName(name string) string {
+	if strings.TrimSpace(name) == "" {
+		return "cannot be empty"
+	}
+	if name != strings.TrimSpace(name) {
+		return "cannot start or end with spaces"
+	}
+	if len([]rune(name)) > maxNameLength {
+		return "is too long"
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "cannot contain control characters"
+		}
+	}
+	return ""
+}
+
+// Fields returns the columns to update.
+func (u *UserUpdate) Fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	if u.Name != nil {
+		fields["name"] = *u.Name
+	}
+	if u.Role != nil {
+		fields["role"] = *u.Role
+	}
+	return fields
+}
@@ -2,27 +2,61 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ennemli/todo/user/internal/db"
+	"github.com/ennemli/todo/user/pkg/crypto"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+var ErrStale = errors.New("The user was changed by someone else")
+
+var ErrNameTaken = errors.New("The name is already taken")
+
+var ErrHasTodos = errors.New("The user still has todos, assigned todos, lists or tags")
+
+// ErrNoAdmin is returned by EnsureAdmin when there is no admin and none is
+// configured.
+var ErrNoAdmin = errors.New("there is no admin, set ADMIN_NAME and ADMIN_PASSWORD to create one")
+
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser
+}
+
 type Store interface {
 	CreateUser(ctx context.Context, userItem *User) (*User, error)
 	GetUsers(ctx context.Context) ([]*User, error)
 	GetUserById(ctx context.Context, id uint) (*User, error)
+	GetSummaryById(ctx context.Context, id uint) (*Summary, error)
 	GetUserByName(ctx context.Context, name string) (*User, error)
-	DeleteUserById(ctx context.Context, id uint) (*User, error)
+	DeleteUserById(ctx context.Context, id uint, version uint) (*User, error)
 	UpdateUser(ctx context.Context, userItem *User, fields map[string]interface{}) (*User, error)
+	HasUserWithRole(ctx context.Context, role string) (bool, error)
+	UpdatePassword(ctx context.Context, id uint, hash string) error
+	GetTrash(ctx context.Context) ([]*User, error)
+	RestoreUserById(ctx context.Context, id uint) (*User, error)
+	PurgeUserById(ctx context.Context, id uint) (*User, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, int64, error)
 }
 
-// User model
+// User model. Todos are the todos the user can see: the ones they created
+// outside lists, those of the lists they are a member of and those assigned
+// to them. Version goes up with every update.
 type User struct {
 	gorm.Model
 	Name     string `json:"name" gorm:"uniqueIndex;not null"`
 	Password string `json:"-" gorm:"not null"`
-	Todos    []Todo `json:"todos" gorm:"foreignKey:UserID"`
+	Role     string `json:"role" gorm:"not null;default:user"`
+	Todos    []Todo `json:"todos" gorm:"-"`
+	Version  uint   `json:"version" gorm:"not null;default:1"`
 }
 type Todo struct {
 	gorm.Model
@@ -30,15 +64,40 @@ type Todo struct {
 	Name        string
 	Description string
 	UserID      uint
+	Status      string
+	Priority    int
+	DueAt       *time.Time
+	CompletedAt *time.Time
+	ListID      *uint
+	AssigneeID  *uint
+}
+
+// Summary is what other services are told about a user.
+type Summary struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserRevocation mirrors the auth service's table of the same name: every
+// token issued to the user before RevokedAt stops being accepted.
+type UserRevocation struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false"`
+	RevokedAt time.Time `gorm:"index;not null"`
 }
 
 type store struct {
 	db *gorm.DB
 }
 
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
 type Credential struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
 }
 
 func NewStore() Store {
@@ -47,16 +106,28 @@ func NewStore() Store {
 	}
 }
 
+// CreateUser fails with ErrNameTaken when another user, in the trash or not,
+// has the name.
 func (s *store) CreateUser(ctx context.Context, userItem *User) (*User, error) {
-	if err := s.db.WithContext(ctx).Create(userItem).Error; err != nil {
-		return nil, err
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(userItem)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNameTaken
 	}
 	return userItem, nil
 }
 
 func (s *store) GetUsers(ctx context.Context) ([]*User, error) {
 	userItems := []*User{}
-	if err := s.db.WithContext(ctx).Model(&User{}).Preload("Todos").Find(&userItems).Error; err != nil {
+	if err := s.db.WithContext(ctx).Model(&User{}).Find(&userItems).Error; err != nil {
+		return nil, err
+	}
+	if err := withTodos(s.db.WithContext(ctx), userItems...); err != nil {
 		return nil, err
 	}
 	return userItems, nil
@@ -64,31 +135,194 @@ func (s *store) GetUsers(ctx context.Context) ([]*User, error) {
 
 func (s *store) GetUserById(ctx context.Context, id uint) (*User, error) {
 	userItem := new(User)
-	if err := s.db.WithContext(ctx).Preload("Todos").First(userItem, id).Error; err != nil {
+	if err := s.db.WithContext(ctx).First(userItem, id).Error; err != nil {
+		return nil, err
+	}
+	if err := withTodos(s.db.WithContext(ctx), userItem); err != nil {
 		return nil, err
 	}
 	return userItem, nil
 }
 
+func (s *store) GetSummaryById(ctx context.Context, id uint) (*Summary, error) {
+	summary := new(Summary)
+	if err := s.db.WithContext(ctx).Model(&User{}).Select("id", "name").First(summary, id).Error; err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
 func (s *store) GetUserByName(ctx context.Context, name string) (*User, error) {
 	userItem := new(User)
-	if err := s.db.WithContext(ctx).Preload("Todos").Where("name = ?", name).First(userItem).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(userItem).Error; err != nil {
+		return nil, err
+	}
+	if err := withTodos(s.db.WithContext(ctx), userItem); err != nil {
 		return nil, err
 	}
 	return userItem, nil
 }
 
-func (s *store) DeleteUserById(ctx context.Context, id uint) (*User, error) {
+// withTodos loads the Todos of users in one query.
+func withTodos(tx *gorm.DB, users ...*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	byID := make(map[uint]*User, len(users))
+	ids := make([]uint, len(users))
+	for i, userItem := range users {
+		userItem.Todos = []Todo{}
+		byID[userItem.ID] = userItem
+		ids[i] = userItem.ID
+	}
+	var rows []struct {
+		Todo
+		ViewerID uint
+	}
+	err := tx.Model(&Todo{}).
+		Select("todos.*, viewers.id AS viewer_id").
+		Joins("JOIN users AS viewers ON (todos.list_id IS NULL AND todos.user_id = viewers.id) OR todos.list_id IN (SELECT list_id FROM list_members WHERE list_members.user_id = viewers.id) OR todos.assignee_id = viewers.id").
+		Where("viewers.id IN ?", ids).
+		Order("todos.id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		byID[row.ViewerID].Todos = append(byID[row.ViewerID].Todos, row.Todo)
+	}
+	return nil
+}
+
+// DeleteUserById moves a user to the trash, revokes their sessions and
+// returns them. A version other than 0 only deletes the user at that
+// version, failing with ErrStale otherwise.
+func (s *store) DeleteUserById(ctx context.Context, id uint, version uint) (*User, error) {
 	userItem := new(User)
-	if err := s.db.WithContext(ctx).Delete(userItem, id).Error; err != nil {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(userItem, id).Error; err != nil {
+			return err
+		}
+		deleted := tx
+		if version != 0 {
+			deleted = deleted.Where("version = ?", version)
+		}
+		result := deleted.Delete(userItem)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStale
+		}
+		return revokeSessions(tx, id)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return userItem, nil
 }
 
+// UpdateUser revokes the user's sessions when the role changes so tokens
+// carrying the old role stop being accepted. The update only applies to the
+// version of userItem, failing with ErrStale when it was changed since it
+// was read. userItem is then reloaded, so that it reads as it was stored.
 func (s *store) UpdateUser(ctx context.Context, userItem *User, fields map[string]interface{}) (*User, error) {
-	if err := s.db.WithContext(ctx).Model(userItem).Updates(fields).Error; err != nil {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if name, ok := fields["name"]; ok {
+			var count int64
+			// Users in the trash keep their name until they are purged.
+			err := tx.Unscoped().Model(&User{}).Where("name = ? AND id <> ?", name, userItem.ID).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrNameTaken
+			}
+		}
+		fields["version"] = gorm.Expr("version + 1")
+		result := tx.Model(userItem).Where("version = ?", userItem.Version).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&User{}).Where("id = ?", userItem.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrStale
+			}
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.First(userItem, userItem.ID).Error; err != nil {
+			return err
+		}
+		if _, ok := fields["role"]; ok {
+			return revokeSessions(tx, userItem.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	return userItem, nil
 }
+
+// UpdatePassword also revokes the user's sessions.
+func (s *store) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", id).Update("password", hash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return revokeSessions(tx, id)
+	})
+}
+
+func (s *store) HasUserWithRole(ctx context.Context, role string) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&User{}).Where("role = ?", role).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// EnsureAdmin bootstraps the first admin. It does nothing once an admin
+// exists; otherwise the user called name is promoted, or created with
+// password when there is no such user.
+func EnsureAdmin(ctx context.Context, s Store, name string, password string, policy crypto.PasswordPolicy) error {
+	exists, err := s.HasUserWithRole(ctx, RoleAdmin)
+	if err != nil || exists {
+		return err
+	}
+	if name == "" {
+		return ErrNoAdmin
+	}
+	existing, err := s.GetUserByName(ctx, name)
+	if err == nil {
+		_, err = s.UpdateUser(ctx, existing, map[string]interface{}{"role": RoleAdmin})
+		return err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err := policy.Validate(password); err != nil {
+		return fmt.Errorf("ADMIN_PASSWORD: %w", err)
+	}
+	hash, err := crypto.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = s.CreateUser(ctx, &User{Name: name, Password: hash, Role: RoleAdmin})
+	return err
+}
+
+func revokeSessions(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+	}).Create(&UserRevocation{UserID: userID, RevokedAt: time.Now()}).Error
+}
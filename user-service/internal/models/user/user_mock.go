@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -25,13 +26,18 @@ func (m *MockUser) GetUserById(ctx context.Context, id uint) (*User, error) {
 	return args.Get(0).(*User), args.Error(1)
 }
 
+func (m *MockUser) GetSummaryById(ctx context.Context, id uint) (*Summary, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*Summary), args.Error(1)
+}
+
 func (m *MockUser) GetUserByName(ctx context.Context, name string) (*User, error) {
 	args := m.Called(ctx, name)
 	return args.Get(0).(*User), args.Error(1)
 }
 
-func (m *MockUser) DeleteUserById(ctx context.Context, id uint) (*User, error) {
-	args := m.Called(ctx, id)
+func (m *MockUser) DeleteUserById(ctx context.Context, id uint, version uint) (*User, error) {
+	args := m.Called(ctx, id, version)
 	return args.Get(0).(*User), args.Error(1)
 }
 
@@ -39,3 +45,33 @@ func (m *MockUser) UpdateUser(ctx context.Context, userItem *User, fields map[st
 	args := m.Called(ctx, userItem, fields)
 	return args.Get(0).(*User), args.Error(1)
 }
+
+func (m *MockUser) HasUserWithRole(ctx context.Context, role string) (bool, error) {
+	args := m.Called(ctx, role)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUser) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	args := m.Called(ctx, id, hash)
+	return args.Error(0)
+}
+
+func (m *MockUser) GetTrash(ctx context.Context) ([]*User, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*User), args.Error(1)
+}
+
+func (m *MockUser) RestoreUserById(ctx context.Context, id uint) (*User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUser) PurgeUserById(ctx context.Context, id uint) (*User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUser) PurgeTrash(ctx context.Context, before time.Time) (int64, int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
@@ -3,6 +3,7 @@ package routing
 import (
 	"github.com/ennemli/todo/user/configs"
 	handlers "github.com/ennemli/todo/user/internal/handlers/user"
+	"github.com/ennemli/todo/user/internal/middlewares"
 	"github.com/ennemli/todo/user/internal/models/user"
 	"github.com/ennemli/todo/user/internal/server"
 	"github.com/go-chi/chi/v5"
@@ -12,13 +13,26 @@ func InitRouting(store user.Store) {
 	r := server.GetRouter()
 	userHandler := handlers.NewUserHandler(store)
 	r.Route(configs.GetConfig().Service.API_ENDPOINT, func(r chi.Router) {
-		r.Get("/", userHandler.GetUsers)
-		r.Post("/", userHandler.CreateUser)
-		r.Route("/{id:^[0-9]+$}", func(r chi.Router) {
-			r.Get("/", userHandler.GetUserById)
-			r.Delete("/", userHandler.DeleteUserById)
-			r.Put("/", userHandler.UpdateUser)
+		r.Get("/internal/{id:^[0-9]+$}", userHandler.GetSummaryById)
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.WithIdentity)
+			r.With(middlewares.RequireRole(user.RoleAdmin)).Get("/", userHandler.GetUsers)
+			r.Post("/", userHandler.CreateUser)
+			r.Route("/trash", func(r chi.Router) {
+				r.Use(middlewares.RequireRole(user.RoleAdmin))
+				r.Get("/", userHandler.GetTrash)
+				r.Delete("/{id:^[0-9]+$}", userHandler.PurgeUserById)
+			})
+			r.Route("/{id:^[0-9]+$}", func(r chi.Router) {
+				r.Use(middlewares.SelfOrRole(user.RoleAdmin))
+				r.Get("/", userHandler.GetUserById)
+				r.Delete("/", userHandler.DeleteUserById)
+				r.Put("/", userHandler.UpdateUser)
+				r.Patch("/", userHandler.PatchUser)
+				r.Put("/password", userHandler.ChangePassword)
+				r.With(middlewares.RequireRole(user.RoleAdmin)).Post("/restore", userHandler.RestoreUserById)
+			})
+			r.With(middlewares.RequireRole(user.RoleAdmin)).Get("/{name:^[a-zA-Z][a-zA-Z-]+$}", userHandler.GetUserByName)
 		})
-		r.Get("/{name:^[a-zA-Z][a-zA-Z-]+$}", userHandler.GetUserByName)
 	})
 }
@@ -65,5 +65,5 @@ func TestPasswordStrength(T *testing.T) {
 	err := json.NewDecoder(res.Body).Decode(&errorRes)
 	assert.Nil(T, err)
 
-	assert.Equal(T, "Password must at least contaians 8 charachters", errorRes.Message)
+	assert.Equal(T, "Password must contain at least 8 characters", errorRes.Message)
 }
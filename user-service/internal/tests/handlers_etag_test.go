@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	handlers "github.com/ennemli/todo/user/internal/handlers/user"
+	"github.com/ennemli/todo/user/internal/middlewares"
+	"github.com/ennemli/todo/user/internal/models/user"
+	"github.com/ennemli/todo/user/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func routeETag(mt *user.MockUser) {
+	userHandlers := handlers.NewUserHandler(mt)
+	r := server.GetRouter()
+	self := r.With(middlewares.WithIdentity, middlewares.SelfOrRole(user.RoleAdmin))
+	self.Get("/{id}", userHandlers.GetUserById)
+	self.Put("/{id}", userHandlers.UpdateUser)
+	self.Patch("/{id}", userHandlers.PatchUser)
+	self.Delete("/{id}", userHandlers.DeleteUserById)
+}
+
+func versionedUser(name string, version uint) *user.User {
+	userItem := &user.User{Name: name, Role: user.RoleUser, Todos: []user.Todo{}, Version: version}
+	userItem.ID = 1
+	return userItem
+}
+
+// currentUserETag returns the ETag GET /1 answers with.
+func currentUserETag(T *testing.T) string {
+	req, _ := http.NewRequest("GET", "/1", nil)
+	SetCaller(req, 1, user.RoleUser)
+	res := MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code)
+	tag := res.Header().Get("ETag")
+	assert.NotEmpty(T, tag)
+	return tag
+}
+
+func TestUserIfNoneMatch(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	routeETag(mt)
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(versionedUser("User 1", 1), nil)
+	tag := currentUserETag(T)
+	assert.Equal(T, `"1.1"`, tag, "the ETag follows the version")
+
+	tt := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{"Current", tag, http.StatusNotModified},
+		{"Weak", "W/" + tag, http.StatusNotModified},
+		{"Other", `"other"`, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("GET", "/1", nil)
+			SetCaller(req, 1, user.RoleUser)
+			req.Header.Set("If-None-Match", tc.header)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+			assert.Equal(T, tag, res.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestUserIfMatch(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	routeETag(mt)
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(versionedUser("User 1", 1), nil)
+	mt.On("UpdateUser", mock.Anything, mock.Anything, map[string]interface{}{"name": "Renamed"}).Return(versionedUser("Renamed", 2), nil)
+	mt.On("DeleteUserById", mock.Anything, uint(1), uint(1)).Return(versionedUser("User 1", 1), nil)
+	tag := currentUserETag(T)
+
+	tt := []struct {
+		name     string
+		method   string
+		header   string
+		expected int
+	}{
+		{"ReplaceStale", "PUT", `"stale"`, http.StatusPreconditionFailed},
+		{"PatchStale", "PATCH", `"stale"`, http.StatusPreconditionFailed},
+		{"DeleteStale", "DELETE", `"stale"`, http.StatusPreconditionFailed},
+		{"Replace", "PUT", tag, http.StatusOK},
+		{"Patch", "PATCH", tag, http.StatusOK},
+		{"Delete", "DELETE", tag, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(`{"name":"Renamed"}`))
+			SetCaller(req, 1, user.RoleUser)
+			req.Header.Set("If-Match", tc.header)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+			if tc.method != "DELETE" && tc.expected == http.StatusOK {
+				assert.NotEqual(T, tag, res.Header().Get("ETag"))
+			}
+		})
+	}
+	mt.AssertNumberOfCalls(T, "UpdateUser", 2)
+	mt.AssertNumberOfCalls(T, "DeleteUserById", 1)
+}
+
+func TestConcurrentUserUpdate(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	routeETag(mt)
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(versionedUser("User 1", 1), nil)
+	mt.On("UpdateUser", mock.Anything, mock.Anything, mock.Anything).Return((*user.User)(nil), user.ErrStale)
+	mt.On("DeleteUserById", mock.Anything, uint(1), uint(1)).Return((*user.User)(nil), user.ErrStale)
+	tag := currentUserETag(T)
+
+	tt := []struct {
+		name     string
+		method   string
+		header   string
+		expected int
+	}{
+		{"WithoutPrecondition", "PATCH", "", http.StatusConflict},
+		{"WithPrecondition", "PATCH", tag, http.StatusPreconditionFailed},
+		{"DeleteWithPrecondition", "DELETE", tag, http.StatusPreconditionFailed},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(`{"name":"Renamed"}`))
+			SetCaller(req, 1, user.RoleUser)
+			if tc.header != "" {
+				req.Header.Set("If-Match", tc.header)
+			}
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+}
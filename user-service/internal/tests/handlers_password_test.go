@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	handlers "github.com/ennemli/todo/user/internal/handlers/user"
+	"github.com/ennemli/todo/user/internal/middlewares"
+	"github.com/ennemli/todo/user/internal/models/user"
+	"github.com/ennemli/todo/user/internal/server"
+	"github.com/ennemli/todo/user/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChangePassword(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	hash, _ := crypto.HashPassword("Griffith-1")
+	existing := &user.User{Name: "Guts", Password: hash}
+	existing.ID = 1
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(existing, nil)
+	mt.On("UpdatePassword", mock.Anything, uint(1), mock.MatchedBy(func(hash string) bool {
+		ok, _, _ := crypto.VerifyPassword(hash, "Dragon-Slayer-2")
+		return ok
+	})).Return(nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Put("/{id}/password", userHandlers.ChangePassword)
+
+	tt := []struct {
+		name     string
+		caller   uint
+		roles    []string
+		current  string
+		next     string
+		expected int
+	}{
+		{"OtherUser", 2, []string{user.RoleUser}, "Griffith-1", "Dragon-Slayer-2", http.StatusForbidden},
+		{"Admin", 2, []string{user.RoleAdmin}, "Griffith-1", "Dragon-Slayer-2", http.StatusForbidden},
+		{"WrongCurrent", 1, nil, "Griffith-2", "Dragon-Slayer-2", http.StatusForbidden},
+		{"Unchanged", 1, nil, "Griffith-1", "Griffith-1", http.StatusBadRequest},
+		{"Weak", 1, nil, "Griffith-1", "password", http.StatusBadRequest},
+		{"Valid", 1, nil, "Griffith-1", "Dragon-Slayer-2", http.StatusNoContent},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			body, _ := json.Marshal(user.PasswordChange{CurrentPassword: tc.current, NewPassword: tc.next})
+			req, _ := http.NewRequest("PUT", "/1/password", bytes.NewBuffer(body))
+			SetCaller(req, tc.caller, tc.roles...)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.expected, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "UpdatePassword", 1)
+}
+
+func TestUpdateUserRejectsPassword(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	existing := &user.User{Name: "Guts"}
+	existing.ID = 1
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(existing, nil)
+
+	r := server.GetRouter()
+	r.Put("/{id}", userHandlers.UpdateUser)
+
+	req, _ := http.NewRequest("PUT", "/1", bytes.NewBufferString(`{"password":"Griffith-1"}`))
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusBadRequest, res.Code)
+	mt.AssertNotCalled(T, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	handlers "github.com/ennemli/todo/user/internal/handlers/user"
+	"github.com/ennemli/todo/user/internal/middlewares"
+	"github.com/ennemli/todo/user/internal/models/user"
+	"github.com/ennemli/todo/user/internal/server"
+	"github.com/ennemli/todo/user/pkg/crypto"
+	"github.com/ennemli/todo/user/pkg/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func SetCaller(req *http.Request, userID uint, roles ...string) {
+	req.Header.Set(identity.HeaderUserID, strconv.FormatUint(uint64(userID), 10))
+	req.Header.Set(identity.HeaderUserRoles, strings.Join(roles, ","))
+}
+
+func TestAdminOnlyRoutes(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	mt.On("GetUsers", mock.Anything).Return([]*user.User{}, nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity, middlewares.RequireRole(user.RoleAdmin)).Get("/", userHandlers.GetUsers)
+
+	tt := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{"User", []string{user.RoleUser}, http.StatusForbidden},
+		{"NoRoles", nil, http.StatusForbidden},
+		{"Admin", []string{user.RoleAdmin}, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			SetCaller(req, 2, tc.roles...)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.status, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "GetUsers", 1)
+}
+
+func TestSelfOrAdmin(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	target := &user.User{Name: "User 1"}
+	target.ID = 1
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(target, nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity, middlewares.SelfOrRole(user.RoleAdmin)).Get("/{id}", userHandlers.GetUserById)
+
+	tt := []struct {
+		name   string
+		caller uint
+		roles  []string
+		status int
+	}{
+		{"Self", 1, []string{user.RoleUser}, http.StatusOK},
+		{"OtherUser", 2, []string{user.RoleUser}, http.StatusForbidden},
+		{"Admin", 2, []string{user.RoleAdmin}, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("GET", "/1", nil)
+			SetCaller(req, tc.caller, tc.roles...)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.status, res.Code)
+		})
+	}
+}
+
+func TestCreateUserRole(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	mt.On("CreateUser", mock.Anything, mock.Anything).Return(&user.User{}, nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity).Post("/", userHandlers.CreateUser)
+
+	tt := []struct {
+		name   string
+		roles  []string
+		body   string
+		status int
+	}{
+		{"User", []string{user.RoleUser}, `{"name":"Casca","password":"Griffith-1"}`, http.StatusOK},
+		{"UserCreatesAdmin", []string{user.RoleUser}, `{"name":"Casca","password":"Griffith-1","role":"admin"}`, http.StatusForbidden},
+		{"InvalidRole", []string{user.RoleAdmin}, `{"name":"Casca","password":"short","role":"root"}`, http.StatusBadRequest},
+		{"AdminCreatesAdmin", []string{user.RoleAdmin}, `{"name":"Casca","password":"Griffith-1","role":"admin"}`, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(tc.body))
+			SetCaller(req, 2, tc.roles...)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.status, res.Code)
+			if tc.name == "InvalidRole" {
+				assert.Contains(T, res.Body.String(), "Invalid role")
+			}
+		})
+	}
+	mt.AssertNumberOfCalls(T, "CreateUser", 2)
+}
+
+func TestUpdateRole(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	existing := &user.User{Name: "User 1", Role: user.RoleUser}
+	existing.ID = 1
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(existing, nil)
+	mt.On("UpdateUser", mock.Anything, existing, map[string]interface{}{"name": "User 1", "role": user.RoleAdmin}).Return(existing, nil)
+	mt.On("UpdateUser", mock.Anything, existing, map[string]interface{}{"role": user.RoleAdmin}).Return(existing, nil)
+
+	r := server.GetRouter()
+	r.With(middlewares.WithIdentity, middlewares.SelfOrRole(user.RoleAdmin)).Put("/{id}", userHandlers.UpdateUser)
+	r.With(middlewares.WithIdentity, middlewares.SelfOrRole(user.RoleAdmin)).Patch("/{id}", userHandlers.PatchUser)
+
+	tt := []struct {
+		name   string
+		method string
+		caller uint
+		roles  []string
+		body   string
+		status int
+	}{
+		{"SelfPromotion", "PUT", 1, []string{user.RoleUser}, `{"name":"User 1","role":"admin"}`, http.StatusForbidden},
+		{"InvalidRole", "PUT", 2, []string{user.RoleAdmin}, `{"name":"User 1","role":"root"}`, http.StatusBadRequest},
+		{"AdminPromotes", "PUT", 2, []string{user.RoleAdmin}, `{"name":"User 1","role":"admin"}`, http.StatusOK},
+		{"SelfPromotionPatch", "PATCH", 1, []string{user.RoleUser}, `{"role":"admin"}`, http.StatusForbidden},
+		{"InvalidRolePatch", "PATCH", 2, []string{user.RoleAdmin}, `{"role":"root"}`, http.StatusBadRequest},
+		{"AdminPromotesPatch", "PATCH", 2, []string{user.RoleAdmin}, `{"role":"admin"}`, http.StatusOK},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(tc.body))
+			SetCaller(req, tc.caller, tc.roles...)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.status, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "UpdateUser", 2)
+}
+
+func TestEnsureAdmin(T *testing.T) {
+	T.Run("AdminExists", func(T *testing.T) {
+		mt := new(user.MockUser)
+		mt.On("HasUserWithRole", mock.Anything, user.RoleAdmin).Return(true, nil)
+		assert.Nil(T, user.EnsureAdmin(context.Background(), mt, "root", "Griffith-1", crypto.DefaultPasswordPolicy))
+		mt.AssertNotCalled(T, "CreateUser", mock.Anything, mock.Anything)
+	})
+	T.Run("PromotesExistingUser", func(T *testing.T) {
+		mt := new(user.MockUser)
+		existing := &user.User{Name: "root", Role: user.RoleUser}
+		mt.On("HasUserWithRole", mock.Anything, user.RoleAdmin).Return(false, nil)
+		mt.On("GetUserByName", mock.Anything, "root").Return(existing, nil)
+		mt.On("UpdateUser", mock.Anything, existing, map[string]interface{}{"role": user.RoleAdmin}).Return(existing, nil)
+		assert.Nil(T, user.EnsureAdmin(context.Background(), mt, "root", "", crypto.DefaultPasswordPolicy))
+		mt.AssertExpectations(T)
+	})
+	T.Run("CreatesAdmin", func(T *testing.T) {
+		mt := new(user.MockUser)
+		mt.On("HasUserWithRole", mock.Anything, user.RoleAdmin).Return(false, nil)
+		mt.On("GetUserByName", mock.Anything, "root").Return((*user.User)(nil), gorm.ErrRecordNotFound)
+		mt.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *user.User) bool {
+			return u.Name == "root" && u.Role == user.RoleAdmin && u.Password != "Griffith-1"
+		})).Return(&user.User{}, nil)
+		assert.Nil(T, user.EnsureAdmin(context.Background(), mt, "root", "Griffith-1", crypto.DefaultPasswordPolicy))
+		mt.AssertExpectations(T)
+	})
+	T.Run("NotConfigured", func(T *testing.T) {
+		mt := new(user.MockUser)
+		mt.On("HasUserWithRole", mock.Anything, user.RoleAdmin).Return(false, nil)
+		assert.ErrorIs(T, user.EnsureAdmin(context.Background(), mt, "", "", crypto.DefaultPasswordPolicy), user.ErrNoAdmin)
+	})
+}
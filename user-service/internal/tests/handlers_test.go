@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func MakeRequest(req *http.Request) *httptest.ResponseRecorder {
@@ -33,7 +34,7 @@ func TestCreateUser(T *testing.T) {
 	userHandlers := handlers.NewUserHandler(mt)
 	userReq := &user.Credential{
 		Name:     "User1",
-		Password: "123456789",
+		Password: "Griffith-1",
 	}
 	expectedUser := &user.User{
 		Name: userReq.Name,
@@ -63,6 +64,25 @@ func TestCreateUser(T *testing.T) {
 	mt.AssertExpectations(T)
 }
 
+func TestCreateUserNameTaken(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	mt.On("CreateUser", mock.Anything, mock.Anything).Return((*user.User)(nil), user.ErrNameTaken)
+
+	reqBody, _ := json.Marshal(&user.Credential{Name: "User1", Password: "Griffith-1"})
+	req, _ := http.NewRequest("POST", "/", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	r := server.GetRouter()
+	r.Post("/", userHandlers.CreateUser)
+
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusConflict, res.Code)
+	assert.Contains(T, res.Body.String(), user.ErrNameTaken.Error())
+}
+
 func TestGetUsers(T *testing.T) {
 	InitServe()
 	r := server.GetRouter()
@@ -125,6 +145,28 @@ func TestGetUserById(T *testing.T) {
 	mt.AssertExpectations(T)
 }
 
+func TestGetSummaryById(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	mt.On("GetSummaryById", mock.Anything, uint(1)).Return(&user.Summary{ID: 1, Name: "User 1"}, nil)
+	mt.On("GetSummaryById", mock.Anything, uint(2)).Return((*user.Summary)(nil), gorm.ErrRecordNotFound)
+
+	r := server.GetRouter()
+	r.Get("/internal/{id:^[0-9]+$}", userHandlers.GetSummaryById)
+
+	req, _ := http.NewRequest("GET", "/internal/1", nil)
+	res := MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code)
+	assert.JSONEq(T, `{"id":1,"name":"User 1"}`, res.Body.String())
+
+	req, _ = http.NewRequest("GET", "/internal/2", nil)
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusNotFound, res.Code)
+
+	mt.AssertExpectations(T)
+}
+
 func TestGetUserByName(T *testing.T) {
 	InitServe()
 	mt := new(user.MockUser)
@@ -163,7 +205,7 @@ func TestDeleteUserById(T *testing.T) {
 	userID := uint(1)
 
 	expectedUser.ID = userID
-	mt.On("DeleteUserById", mock.Anything, userID).Return(expectedUser, nil)
+	mt.On("DeleteUserById", mock.Anything, userID, uint(0)).Return(expectedUser, nil)
 
 	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/%d", userID), nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	handlers "github.com/ennemli/todo/user/internal/handlers/user"
+	"github.com/ennemli/todo/user/internal/middlewares"
+	"github.com/ennemli/todo/user/internal/models/user"
+	"github.com/ennemli/todo/user/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestUserTrash(T *testing.T) {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	deleted := &user.User{Name: "User 3"}
+	deleted.ID = 3
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	restored := &user.User{Name: "User 3"}
+	restored.ID = 3
+	mt.On("GetTrash", mock.Anything).Return([]*user.User{deleted}, nil)
+	mt.On("RestoreUserById", mock.Anything, uint(3)).Return(restored, nil)
+	mt.On("RestoreUserById", mock.Anything, uint(4)).Return((*user.User)(nil), gorm.ErrRecordNotFound)
+	mt.On("PurgeUserById", mock.Anything, uint(3)).Return(deleted, nil)
+	mt.On("PurgeUserById", mock.Anything, uint(4)).Return((*user.User)(nil), gorm.ErrRecordNotFound)
+	mt.On("PurgeUserById", mock.Anything, uint(5)).Return((*user.User)(nil), user.ErrHasTodos)
+
+	r := server.GetRouter()
+	admin := r.With(middlewares.WithIdentity, middlewares.RequireRole(user.RoleAdmin))
+	admin.Get("/trash", userHandlers.GetTrash)
+	admin.Delete("/trash/{id}", userHandlers.PurgeUserById)
+	admin.Post("/{id}/restore", userHandlers.RestoreUserById)
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		roles  []string
+		status int
+	}{
+		{"List", "GET", "/trash", []string{user.RoleAdmin}, http.StatusOK},
+		{"ListAsUser", "GET", "/trash", []string{user.RoleUser}, http.StatusForbidden},
+		{"Restore", "POST", "/3/restore", []string{user.RoleAdmin}, http.StatusOK},
+		{"RestoreNotInTrash", "POST", "/4/restore", []string{user.RoleAdmin}, http.StatusNotFound},
+		{"RestoreAsUser", "POST", "/3/restore", []string{user.RoleUser}, http.StatusForbidden},
+		{"Purge", "DELETE", "/trash/3", []string{user.RoleAdmin}, http.StatusOK},
+		{"PurgeNotInTrash", "DELETE", "/trash/4", []string{user.RoleAdmin}, http.StatusNotFound},
+		{"PurgeWithTodos", "DELETE", "/trash/5", []string{user.RoleAdmin}, http.StatusConflict},
+		{"PurgeInvalidID", "DELETE", "/trash/x", []string{user.RoleAdmin}, http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			SetCaller(req, 1, tc.roles...)
+			res := MakeRequest(req)
+			assert.Equal(T, tc.status, res.Code)
+		})
+	}
+	mt.AssertNumberOfCalls(T, "GetTrash", 1)
+	mt.AssertNumberOfCalls(T, "RestoreUserById", 2)
+}
+
+func TestPurgeUsers(T *testing.T) {
+	mt := new(user.MockUser)
+	ctx, cancel := context.WithCancel(context.Background())
+	retention := time.Hour
+	start := time.Now()
+	mt.On("PurgeTrash", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return !before.Before(start.Add(-retention)) && !before.After(time.Now().Add(-retention))
+	})).Return(int64(0), int64(0), nil).Run(func(args mock.Arguments) { cancel() })
+
+	user.Purge(ctx, mt, retention, time.Hour)
+
+	mt.AssertNumberOfCalls(T, "PurgeTrash", 1)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ennemli/todo/user/internal/errors"
+	handlers "github.com/ennemli/todo/user/internal/handlers/user"
+	"github.com/ennemli/todo/user/internal/models/user"
+	"github.com/ennemli/todo/user/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupUpdate(T *testing.T) *user.MockUser {
+	InitServe()
+	mt := new(user.MockUser)
+	userHandlers := handlers.NewUserHandler(mt)
+	existing := &user.User{Name: "Guts", Role: user.RoleUser}
+	existing.ID = 1
+	mt.On("GetUserById", mock.Anything, uint(1)).Return(existing, nil)
+	r := server.GetRouter()
+	r.Put("/{id}", userHandlers.UpdateUser)
+	r.Patch("/{id}", userHandlers.PatchUser)
+	return mt
+}
+
+func TestProtectedFieldsCannotBeModified(T *testing.T) {
+	mt := setupUpdate(T)
+
+	for _, method := range []string{"PUT", "PATCH"} {
+		for _, field := range []string{"id", "created_at", "updated_at", "deleted_at", "password", "todos"} {
+			T.Run(method+"/"+field, func(T *testing.T) {
+				body, _ := json.Marshal(map[string]interface{}{"name": "Guts", field: "2020-01-01T00:00:00Z"})
+				req, _ := http.NewRequest(method, "/1", bytes.NewBuffer(body))
+				res := MakeRequest(req)
+
+				assert.Equal(T, http.StatusBadRequest, res.Code)
+				var errorResponse errors.ValidationErrorResponse
+				assert.Nil(T, json.NewDecoder(res.Body).Decode(&errorResponse))
+				assert.Contains(T, errorResponse.Fields, field)
+			})
+		}
+	}
+	mt.AssertNotCalled(T, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateFieldErrors(T *testing.T) {
+	mt := setupUpdate(T)
+
+	tt := []struct {
+		name   string
+		method string
+		body   string
+		fields []string
+	}{
+		{"NotAnObject", "PATCH", `["name"]`, []string{""}},
+		{"UnknownField", "PATCH", `{"nickname":"Black Swordsman"}`, []string{"nickname"}},
+		{"NullName", "PATCH", `{"name":null}`, []string{"name"}},
+		{"NameNotAString", "PATCH", `{"name":7}`, []string{"name"}},
+		{"EmptyName", "PATCH", `{"name":"  "}`, []string{"name"}},
+		{"InvalidRole", "PATCH", `{"role":"root"}`, []string{"role"}},
+		{"Several", "PATCH", `{"name":"","role":"root","id":3}`, []string{"name", "role", "id"}},
+		{"PutWithoutName", "PUT", `{"role":"user"}`, []string{"name"}},
+	}
+	for _, tc := range tt {
+		T.Run(tc.name, func(T *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/1", bytes.NewBufferString(tc.body))
+			res := MakeRequest(req)
+
+			assert.Equal(T, http.StatusBadRequest, res.Code)
+			var errorResponse errors.ValidationErrorResponse
+			assert.Nil(T, json.NewDecoder(res.Body).Decode(&errorResponse))
+			assert.Len(T, errorResponse.Fields, len(tc.fields))
+			for _, field := range tc.fields {
+				assert.Contains(T, errorResponse.Fields, field)
+			}
+		})
+	}
+	mt.AssertNotCalled(T, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchUser(T *testing.T) {
+	mt := setupUpdate(T)
+	mt.On("UpdateUser", mock.Anything, mock.Anything, map[string]interface{}{"name": "Black Swordsman"}).Return(&user.User{Name: "Black Swordsman"}, nil)
+
+	req, _ := http.NewRequest("PATCH", "/1", bytes.NewBufferString(`{"name":"Black Swordsman","role":"user"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	mt.AssertExpectations(T)
+
+	req, _ = http.NewRequest("PATCH", "/1", bytes.NewBufferString(`{"name":"Black Swordsman"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json; charset=utf-8")
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusOK, res.Code, "parameters of the media type are ignored")
+
+	req, _ = http.NewRequest("PATCH", "/1", bytes.NewBufferString(`name=Griffith`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res = MakeRequest(req)
+	assert.Equal(T, http.StatusUnsupportedMediaType, res.Code)
+}
+
+func TestEmptyPatchChangesNothing(T *testing.T) {
+	mt := setupUpdate(T)
+
+	req, _ := http.NewRequest("PATCH", "/1", bytes.NewBufferString(`{}`))
+	res := MakeRequest(req)
+
+	assert.Equal(T, http.StatusOK, res.Code)
+	mt.AssertNotCalled(T, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateNameTaken(T *testing.T) {
+	mt := setupUpdate(T)
+	mt.On("UpdateUser", mock.Anything, mock.Anything, mock.Anything).Return((*user.User)(nil), user.ErrNameTaken)
+
+	for _, method := range []string{"PUT", "PATCH"} {
+		req, _ := http.NewRequest(method, "/1", bytes.NewBufferString(`{"name":"Griffith"}`))
+		res := MakeRequest(req)
+		assert.Equal(T, http.StatusConflict, res.Code, method)
+	}
+}
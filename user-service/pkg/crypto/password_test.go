@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy(T *testing.T) {
+	testCases := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"TooShort", "Ab1!", false},
+		{"TooLong", strings.Repeat("Ab1!", 19), false},
+		{"SingleClass", "abcdefghij", false},
+		{"Common", "Password1", false},
+		{"CommonOtherCase", "QWERTY123", false},
+		{"TwoClasses", "falcon-hawk", true},
+		{"Mixed", "Griffith-1", true},
+		{"Unicode", "çaÉtéFête9", true},
+		{"ShortUnicode", "éÉ1éÉ1é", false},
+		{"LongUnicode", strings.Repeat("é", 40) + "A1", false},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			err := DefaultPasswordPolicy.Validate(tc.password)
+			if tc.valid != (err == nil) {
+				T.Errorf("Validate(%q) = %v, expected valid=%v", tc.password, err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyArgon2id(T *testing.T) {
+	setHashParams(T, HashParams{Algorithm: AlgorithmArgon2id})
+	testCases := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"PastBcryptLimit", strings.Repeat("Ab1!", 19), true},
+		{"LongUnicode", strings.Repeat("é", 40) + "A1", true},
+		{"TooLong", strings.Repeat("Ab1!", 33), false},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			err := DefaultPasswordPolicy.Validate(tc.password)
+			if tc.valid != (err == nil) {
+				T.Errorf("Validate(%q) = %v, expected valid=%v", tc.password, err, tc.valid)
+			}
+		})
+	}
+}
+
+func setHashParams(T *testing.T, p HashParams) {
+	T.Helper()
+	if err := SetHashParams(p); err != nil {
+		T.Fatal(err)
+	}
+	T.Cleanup(func() { SetHashParams(DefaultHashParams) })
+}
+
+func TestHashAndVerify(T *testing.T) {
+	testCases := []struct {
+		name   string
+		params HashParams
+		prefix string
+	}{
+		{"Bcrypt", HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, "$2a$"},
+		{"Argon2id", HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024, Argon2Time: 1}, "$argon2id$v=19$m=1024,t=1,p=4$"},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			setHashParams(T, tc.params)
+			hash, err := HashPassword("Griffith-1")
+			if err != nil {
+				T.Fatal(err)
+			}
+			if !strings.HasPrefix(hash, tc.prefix) {
+				T.Errorf("hash %q does not start with %q", hash, tc.prefix)
+			}
+			if ok, rehash, err := VerifyPassword(hash, "Griffith-1"); !ok || rehash || err != nil {
+				T.Errorf("VerifyPassword() = %v, %v, %v, expected true, false, nil", ok, rehash, err)
+			}
+			if ok, _, err := VerifyPassword(hash, "Griffith-2"); ok || err != nil {
+				T.Errorf("wrong password: VerifyPassword() = %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(T *testing.T) {
+	setHashParams(T, HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	oldBcrypt, _ := HashPassword("Griffith-1")
+	setHashParams(T, HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024, Argon2Time: 1})
+	oldArgon2, _ := HashPassword("Griffith-1")
+
+	testCases := []struct {
+		name   string
+		hash   string
+		params HashParams
+		rehash bool
+	}{
+		{"SameBcrypt", oldBcrypt, HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, false},
+		{"BcryptCost", oldBcrypt, HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}, true},
+		{"BcryptToArgon2id", oldBcrypt, HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024, Argon2Time: 1}, true},
+		{"Argon2idTime", oldArgon2, HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024, Argon2Time: 2}, true},
+		{"Argon2idToBcrypt", oldArgon2, HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, true},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			setHashParams(T, tc.params)
+			ok, rehash, err := VerifyPassword(tc.hash, "Griffith-1")
+			if !ok || err != nil {
+				T.Fatalf("VerifyPassword() = %v, %v", ok, err)
+			}
+			if rehash != tc.rehash {
+				T.Errorf("needsRehash=%v, expected %v", rehash, tc.rehash)
+			}
+		})
+	}
+}
+
+func TestInvalidHash(T *testing.T) {
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=1024,t=1,p=4$c2FsdA", "$argon2id$v=18$m=1024,t=1,p=4$c2FsdA$a2V5"} {
+		if ok, _, err := VerifyPassword(hash, "Griffith-1"); ok || err == nil {
+			T.Errorf("VerifyPassword(%q) = %v, %v, expected an error", hash, ok, err)
+		}
+	}
+}
+
+func TestSetHashParams(T *testing.T) {
+	if err := SetHashParams(HashParams{Algorithm: "md5"}); err == nil {
+		T.Errorf("unknown algorithm accepted")
+	}
+	if err := SetHashParams(HashParams{BcryptCost: 99}); err == nil {
+		T.Errorf("out of range bcrypt cost accepted")
+	}
+}
@@ -0,0 +1,52 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Of returns a strong entity tag for v, derived from its JSON encoding.
+func Of(v interface{}) (string, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// Version returns a strong entity tag for the entity id at version. It
+// suits entities whose version goes up with every change to them.
+func Version(id uint, version uint) string {
+	return fmt.Sprintf(`"%d.%d"`, id, version)
+}
+
+// Match reports whether header, the value of an If-Match or If-None-Match
+// header, lists tag or is "*". If-Match needs the strong comparison, where
+// weak tags never match; If-None-Match uses the weak one, which ignores the
+// W/ prefix.
+func Match(header string, tag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if weak {
+		tag = strings.TrimPrefix(tag, "W/")
+	} else if strings.HasPrefix(tag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
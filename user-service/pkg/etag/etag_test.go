@@ -0,0 +1,69 @@
+package etag
+
+import "testing"
+
+func TestOf(T *testing.T) {
+	type resource struct {
+		Name    string `json:"name"`
+		Version uint   `json:"version"`
+	}
+	first, err := Of(resource{"Task", 1})
+	if err != nil {
+		T.Fatal(err)
+	}
+	same, _ := Of(resource{"Task", 1})
+	changed, _ := Of(resource{"Task", 2})
+	if first != same {
+		T.Errorf("expected equal values to get the same tag, got %s and %s", first, same)
+	}
+	if first == changed {
+		T.Errorf("expected a new version to get a new tag, got %s twice", first)
+	}
+	if first[0] != '"' || first[len(first)-1] != '"' {
+		T.Errorf("expected a quoted tag, got %s", first)
+	}
+	if _, err := Of(func() {}); err == nil {
+		T.Error("expected an error for a value JSON cannot encode")
+	}
+}
+
+func TestVersion(T *testing.T) {
+	tag := Version(3, 2)
+	if tag != `"3.2"` {
+		T.Errorf("expected \"3.2\", got %s", tag)
+	}
+	if tag == Version(3, 3) || tag == Version(32, 0) {
+		T.Errorf("expected another version or entity to get another tag, got %s", tag)
+	}
+	if !Match(tag, Version(3, 2), false) {
+		T.Errorf("expected %s to match itself", tag)
+	}
+}
+
+func TestMatch(T *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		tag      string
+		weak     bool
+		expected bool
+	}{
+		{"same tag", `"abc"`, `"abc"`, false, true},
+		{"other tag", `"abc"`, `"def"`, false, false},
+		{"listed tag", `"abc", "def"`, `"def"`, false, true},
+		{"any", "*", `"abc"`, false, true},
+		{"empty header", "", `"abc"`, false, false},
+		{"weak header, strong comparison", `W/"abc"`, `"abc"`, false, false},
+		{"weak tag, strong comparison", `"abc"`, `W/"abc"`, false, false},
+		{"weak header, weak comparison", `W/"abc"`, `"abc"`, true, true},
+		{"weak tag, weak comparison", `"abc"`, `W/"abc"`, true, true},
+		{"unquoted", `abc`, `"abc"`, true, false},
+	}
+	for _, tc := range testCases {
+		T.Run(tc.name, func(T *testing.T) {
+			if got := Match(tc.header, tc.tag, tc.weak); got != tc.expected {
+				T.Errorf("Match(%q, %q, %v) = %v, expected %v", tc.header, tc.tag, tc.weak, got, tc.expected)
+			}
+		})
+	}
+}
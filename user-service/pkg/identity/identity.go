@@ -0,0 +1,73 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set by the api gateway once it has verified the caller's token.
+const (
+	HeaderUserID        = "X-User-Id"
+	HeaderUserName      = "X-User-Name"
+	HeaderUserExpiresAt = "X-User-Expires"
+	HeaderUserRoles     = "X-User-Roles"
+)
+
+type Identity struct {
+	ID        uint
+	Name      string
+	ExpiresAt time.Time
+	Roles     []string
+}
+
+func (i *Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok && identity != nil && identity.ID != 0
+}
+
+func UserID(ctx context.Context) (uint, bool) {
+	identity, ok := FromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	return identity.ID, true
+}
+
+func FromRequest(r *http.Request) (*Identity, bool) {
+	userID, err := strconv.ParseUint(r.Header.Get(HeaderUserID), 10, 64)
+	if err != nil || userID == 0 {
+		return nil, false
+	}
+	identity := &Identity{ID: uint(userID)}
+	if name, err := url.QueryUnescape(r.Header.Get(HeaderUserName)); err == nil {
+		identity.Name = name
+	}
+	if exp, err := strconv.ParseInt(r.Header.Get(HeaderUserExpiresAt), 10, 64); err == nil {
+		identity.ExpiresAt = time.Unix(exp, 0)
+	}
+	for _, role := range strings.Split(r.Header.Get(HeaderUserRoles), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			identity.Roles = append(identity.Roles, role)
+		}
+	}
+	return identity, true
+}